@@ -30,7 +30,7 @@ import (
 )
 
 // check matching with interface
-var _ adminservice.AdminService = RightClient{}
+var _ adminservice.RightService = RightClient{}
 
 type RightClient struct {
 	grpcclient.Client
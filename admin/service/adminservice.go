@@ -57,3 +57,9 @@ type AdminService interface {
 	ViewUserRoles(ctx context.Context, adminId uint64, userId uint64) (bool, []Group, error)
 	EditUserRoles(ctx context.Context, adminId uint64, userId uint64) ([]Group, []Group, error)
 }
+
+// AdminService where the groups are declared during the init phase.
+type RightService interface {
+	AdminService
+	RegisterGroup(groupId uint64, groupName string) bool
+}
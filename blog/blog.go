@@ -19,6 +19,7 @@
 package blog
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"strconv"
@@ -29,6 +30,7 @@ import (
 	"github.com/dvaumoron/puzzleweb/common"
 	"github.com/dvaumoron/puzzleweb/common/config"
 	puzzleweb "github.com/dvaumoron/puzzleweb/core"
+	"github.com/dvaumoron/puzzleweb/ratelimit"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/feeds"
 	"go.uber.org/zap"
@@ -48,29 +50,47 @@ var errFeedFormat = errors.New("unrecognized feed format")
 // TODO draft with modify until publish ?
 // TODO use forum service for blog storage ?
 type blogWidget struct {
+	blogService          blogservice.BlogService
 	listHandler          gin.HandlerFunc
 	viewHandler          gin.HandlerFunc
 	saveCommentHandler   gin.HandlerFunc
+	confirmDeleteComment gin.HandlerFunc
 	deleteCommentHandler gin.HandlerFunc
 	createHandler        gin.HandlerFunc
 	previewHandler       gin.HandlerFunc
 	saveHandler          gin.HandlerFunc
+	confirmDelete        gin.HandlerFunc
 	deleteHandler        gin.HandlerFunc
 	rssHandler           gin.HandlerFunc
 }
 
 func (w blogWidget) LoadInto(router gin.IRouter) {
-	router.GET("/", w.listHandler)
-	router.GET("/view/:postId", w.viewHandler)
-	router.POST("/comment/save/:postId", w.saveCommentHandler)
-	router.GET("/comment/delete/:postId/:commentId", w.deleteCommentHandler)
+	cachePage := puzzleweb.CachePage(config.BlogPageKind)
+	router.GET("/", cachePage, w.listHandler)
+	router.GET("/view/:postId", cachePage, w.viewHandler)
+	router.POST("/comment/save/:postId", puzzleweb.RateLimit(ratelimit.ContentClass), w.saveCommentHandler)
+	puzzleweb.AddDeleteRoutes(router, "/comment/delete/:postId/:commentId", w.confirmDeleteComment, w.deleteCommentHandler)
 	router.GET("/create", w.createHandler)
 	router.POST("/preview", w.previewHandler)
-	router.POST("/save", w.saveHandler)
-	router.GET("/delete/:postId", w.deleteHandler)
+	router.POST("/save", puzzleweb.RateLimit(ratelimit.ContentClass), w.saveHandler)
+	puzzleweb.AddDeleteRoutes(router, "/delete/:postId", w.confirmDelete, w.deleteHandler)
 	router.GET("/rss", w.rssHandler)
 }
 
+func (w blogWidget) SitemapEntries(ctx context.Context, baseUrl string, allLang []string) ([]puzzleweb.SitemapEntry, error) {
+	_, posts, err := w.blogService.GetPosts(ctx, 0, 0, puzzleweb.SitemapMaxUrls-1, "")
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]puzzleweb.SitemapEntry, 0, len(posts)+1)
+	entries = append(entries, puzzleweb.SitemapEntry{Url: baseUrl})
+	for _, post := range posts {
+		entries = append(entries, puzzleweb.SitemapEntry{Url: postUrlBuilder(baseUrl, post.PostId).String()})
+	}
+	return entries, nil
+}
+
 func MakeBlogPage(blogName string, blogConfig config.BlogConfig) puzzleweb.Page {
 	blogService := blogConfig.Service
 	commentService := blogConfig.CommentService
@@ -79,7 +99,6 @@ func MakeBlogPage(blogName string, blogConfig config.BlogConfig) puzzleweb.Page
 	if port := common.CheckPort(blogConfig.Port); port != ":80" {
 		host += port
 	}
-	dateFormat := blogConfig.DateFormat
 	defaultPageSize := blogConfig.PageSize
 	extractSize := blogConfig.ExtractSize
 	feedFormat := blogConfig.FeedFormat
@@ -117,8 +136,8 @@ func MakeBlogPage(blogName string, blogConfig config.BlogConfig) puzzleweb.Page
 
 	p := puzzleweb.MakePage(blogName)
 	p.Widget = blogWidget{
+		blogService: blogService,
 		listHandler: puzzleweb.CreateTemplate(func(data gin.H, c *gin.Context) (string, string) {
-			logger := puzzleweb.GetLogger(c)
 			userId, _ := data[common.UserIdName].(uint64)
 
 			pageNumber, start, end, filter := common.GetPagination(defaultPageSize, c)
@@ -126,7 +145,7 @@ func MakeBlogPage(blogName string, blogConfig config.BlogConfig) puzzleweb.Page
 			ctx := c.Request.Context()
 			total, posts, err := blogService.GetPosts(ctx, userId, start, end, filter)
 			if err != nil {
-				return "", common.DefaultErrorRedirect(logger, err.Error())
+				return common.ErrorPage(c, err)
 			}
 
 			filterPostsExtract(posts, extractSize)
@@ -146,19 +165,19 @@ func MakeBlogPage(blogName string, blogConfig config.BlogConfig) puzzleweb.Page
 
 			postId, err := strconv.ParseUint(c.Param(postIdName), 10, 64)
 			if err != nil {
-				logger.Warn(parsingPostIdErrorMsg, zap.Error(err))
-				return "", common.DefaultErrorRedirect(logger, common.ErrorTechnicalKey)
+				logger.Debug(parsingPostIdErrorMsg, zap.Error(err))
+				return common.ErrorPage(c, common.ErrNotFound)
 			}
 
 			ctx := c.Request.Context()
 			post, err := blogService.GetPost(ctx, userId, postId)
 			if err != nil {
-				return "", common.DefaultErrorRedirect(logger, err.Error())
+				return common.ErrorPage(c, err)
 			}
 
 			total, comments, err := commentService.GetCommentThread(ctx, userId, post.Title, start, end)
 			if err != nil {
-				return "", common.DefaultErrorRedirect(logger, err.Error())
+				return common.ErrorPage(c, err)
 			}
 
 			common.InitPagination(data, "", pageNumber, end, total)
@@ -182,8 +201,8 @@ func MakeBlogPage(blogName string, blogConfig config.BlogConfig) puzzleweb.Page
 
 			postId, err := strconv.ParseUint(c.Param(postIdName), 10, 64)
 			if err != nil {
-				logger.Warn(parsingPostIdErrorMsg, zap.Error(err))
-				return common.DefaultErrorRedirect(logger, common.ErrorTechnicalKey)
+				logger.Debug(parsingPostIdErrorMsg, zap.Error(err))
+				return common.DefaultErrorRedirect(logger, common.ErrorNotFoundKey)
 			}
 			comment := c.PostForm("comment")
 
@@ -199,25 +218,31 @@ func MakeBlogPage(blogName string, blogConfig config.BlogConfig) puzzleweb.Page
 				err = commentService.CreateComment(ctx, userId, post.Title, comment)
 			}
 
-			targetBuilder := postUrlBuilder(common.GetBaseUrl(3, c), postId)
-			if err != nil {
+			baseUrl := common.GetBaseUrl(3, c)
+			targetBuilder := postUrlBuilder(baseUrl, postId)
+			if err == nil {
+				puzzleweb.InvalidatePages(c, baseUrl)
+			} else {
 				common.WriteError(targetBuilder, logger, err.Error())
 			}
 			return targetBuilder.String()
 		}),
+		confirmDeleteComment: puzzleweb.CreateConfirmTemplate("ConfirmDeleteComment", func(c *gin.Context) string {
+			return common.GetBaseUrl(4, c) + "view/" + c.Param(postIdName)
+		}),
 		deleteCommentHandler: common.CreateRedirect(func(c *gin.Context) string {
 			logger := puzzleweb.GetLogger(c)
 			userId := puzzleweb.GetSessionUserId(c)
 
 			postId, err := strconv.ParseUint(c.Param(postIdName), 10, 64)
 			if err != nil {
-				logger.Warn(parsingPostIdErrorMsg, zap.Error(err))
-				return common.DefaultErrorRedirect(logger, common.ErrorTechnicalKey)
+				logger.Debug(parsingPostIdErrorMsg, zap.Error(err))
+				return common.DefaultErrorRedirect(logger, common.ErrorNotFoundKey)
 			}
 			commentId, err := strconv.ParseUint(c.Param("commentId"), 10, 64)
 			if err != nil {
-				logger.Warn("Failed to parse commentId", zap.Error(err))
-				return common.DefaultErrorRedirect(logger, common.ErrorTechnicalKey)
+				logger.Debug("Failed to parse commentId", zap.Error(err))
+				return common.DefaultErrorRedirect(logger, common.ErrorNotFoundKey)
 			}
 
 			ctx := c.Request.Context()
@@ -227,8 +252,11 @@ func MakeBlogPage(blogName string, blogConfig config.BlogConfig) puzzleweb.Page
 			}
 
 			err = commentService.DeleteComment(ctx, userId, post.Title, commentId)
-			targetBuilder := postUrlBuilder(common.GetBaseUrl(4, c), postId)
-			if err != nil {
+			baseUrl := common.GetBaseUrl(4, c)
+			targetBuilder := postUrlBuilder(baseUrl, postId)
+			if err == nil {
+				puzzleweb.InvalidatePages(c, baseUrl)
+			} else {
 				common.WriteError(targetBuilder, logger, err.Error())
 			}
 			return targetBuilder.String()
@@ -289,17 +317,24 @@ func MakeBlogPage(blogName string, blogConfig config.BlogConfig) puzzleweb.Page
 			if err != nil {
 				return common.DefaultErrorRedirect(logger, err.Error())
 			}
-			return postUrlBuilder(common.GetBaseUrl(1, c), postId).String()
+
+			baseUrl := common.GetBaseUrl(1, c)
+			puzzleweb.InvalidatePages(c, baseUrl)
+			return postUrlBuilder(baseUrl, postId).String()
+		}),
+		confirmDelete: puzzleweb.CreateConfirmTemplate("ConfirmDeletePost", func(c *gin.Context) string {
+			return common.GetBaseUrl(2, c) + "view/" + c.Param(postIdName)
 		}),
 		deleteHandler: common.CreateRedirect(func(c *gin.Context) string {
 			logger := puzzleweb.GetLogger(c)
+			baseUrl := common.GetBaseUrl(2, c)
 			var targetBuilder strings.Builder
-			targetBuilder.WriteString(common.GetBaseUrl(2, c))
+			targetBuilder.WriteString(baseUrl)
 
 			postId, err := strconv.ParseUint(c.Param(postIdName), 10, 64)
 			if err != nil {
-				logger.Warn(parsingPostIdErrorMsg, zap.Error(err))
-				common.WriteError(&targetBuilder, logger, common.ErrorTechnicalKey)
+				logger.Debug(parsingPostIdErrorMsg, zap.Error(err))
+				common.WriteError(&targetBuilder, logger, common.ErrorNotFoundKey)
 				return targetBuilder.String()
 			}
 			userId := puzzleweb.GetSessionUserId(c)
@@ -315,6 +350,8 @@ func MakeBlogPage(blogName string, blogConfig config.BlogConfig) puzzleweb.Page
 				common.WriteError(&targetBuilder, logger, err.Error())
 				return targetBuilder.String()
 			}
+			// the post is deleted even if the comments are not
+			puzzleweb.InvalidatePages(c, baseUrl)
 
 			if err = commentService.DeleteCommentThread(ctx, userId, post.Title); err != nil {
 				common.WriteError(&targetBuilder, logger, err.Error())
@@ -327,17 +364,14 @@ func MakeBlogPage(blogName string, blogConfig config.BlogConfig) puzzleweb.Page
 
 			_, posts, err := blogService.GetPosts(c.Request.Context(), userId, 0, feedSize, "")
 			if err != nil {
-				status := http.StatusInternalServerError
-				if err == common.ErrNotAuthorized {
-					status = http.StatusForbidden
-				}
+				status, _ := common.ExtractStatusAndKey(logger, err)
 				c.AbortWithStatus(status)
 				return
 			}
 
 			baseUrl := host + common.GetBaseUrl(1, c)
 			// TODO improve blog title ?
-			data, err := buildFeed(posts, blogName, baseUrl, dateFormat, extractSize, feedFormat)
+			data, err := buildFeed(posts, blogName, baseUrl, extractSize, feedFormat)
 			if err != nil {
 				common.LogOriginalError(logger, err)
 				c.AbortWithStatus(http.StatusInternalServerError)
@@ -363,7 +397,7 @@ func filterPostsExtract(posts []blogservice.BlogPost, extractSize uint64) {
 	}
 }
 
-func buildFeed(posts []blogservice.BlogPost, blogTitle string, baseUrl string, dateFormat string, extractSize uint64, feedFormat string) ([]byte, error) {
+func buildFeed(posts []blogservice.BlogPost, blogTitle string, baseUrl string, extractSize uint64, feedFormat string) ([]byte, error) {
 	feedData := feeds.Feed{
 		Title:   blogTitle,
 		Link:    &feeds.Link{Href: baseUrl},
@@ -371,17 +405,12 @@ func buildFeed(posts []blogservice.BlogPost, blogTitle string, baseUrl string, d
 	}
 
 	for _, post := range posts {
-		date, err := time.Parse(dateFormat, post.Date)
-		if err != nil {
-			return nil, err
-		}
-
 		feedData.Items = append(feedData.Items, &feeds.Item{
 			Title:       post.Title,
 			Link:        &feeds.Link{Href: postUrlBuilder(baseUrl, post.PostId).String()},
 			Description: common.FilterExtractHtml(string(post.Content), extractSize),
 			Author:      &feeds.Author{Name: post.Creator.Login},
-			Created:     date,
+			Created:     post.Date,
 		})
 	}
 
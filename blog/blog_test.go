@@ -0,0 +1,162 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package blog_test
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	adminservice "github.com/dvaumoron/puzzleweb/admin/service"
+	blogservice "github.com/dvaumoron/puzzleweb/blog/service"
+	"github.com/dvaumoron/puzzleweb/common"
+	"github.com/dvaumoron/puzzleweb/common/config"
+	"github.com/dvaumoron/puzzleweb/puzzlewebtest"
+)
+
+const blogGroupId = 10
+
+func TestCreateAndViewPost(t *testing.T) {
+	builder := puzzlewebtest.NewSiteBuilder(t)
+	blog := builder.AddBlog("blog", 1, blogGroupId)
+	site := builder.Build()
+	client := site.NewLoggedClient("alice")
+
+	client.Get("/blog/create").AssertTemplate(t, "blog/create")
+
+	form := url.Values{"title": {"First post"}, "markdown": {"Hello *world*"}}
+	response := client.PostForm("/blog/preview", form)
+	response.AssertTemplate(t, "blog/preview")
+	response.AssertData(t, "PreviewTitle", "First post")
+
+	client.PostForm("/blog/save", form).AssertRedirect(t, "/blog/view/1")
+	if calls := blog.CallsTo("CreatePost"); len(calls) != 1 {
+		t.Fatalf("CreatePost called %d times, expected 1", len(calls))
+	}
+	if calls := blog.Comments.CallsTo("CreateCommentThread"); len(calls) != 1 {
+		t.Errorf("CreateCommentThread called %d times, expected 1", len(calls))
+	}
+
+	response = client.Get("/blog/view/1")
+	response.AssertStatus(t, http.StatusOK)
+	response.AssertTemplate(t, "blog/view")
+	post, _ := response.Data()["Post"].(blogservice.BlogPost)
+	if post.Title != "First post" || post.Creator.Login != "alice" {
+		t.Errorf("unexpected post : %+v", post)
+	}
+
+	response = site.NewClient().Get("/blog/")
+	response.AssertTemplate(t, "blog/list")
+	if posts, _ := response.Data()["Posts"].([]blogservice.BlogPost); len(posts) != 1 {
+		t.Errorf("unexpected posts : %v", posts)
+	}
+}
+
+func TestCacheInvalidationInEachLang(t *testing.T) {
+	builder := puzzlewebtest.NewSiteBuilder(t)
+	builder.AllLang = []string{"en", "fr"}
+	builder.LangInUrl = true
+	builder.SiteConfig.PageCacheSize = 1 << 20
+	builder.SiteConfig.PageCacheTimeOuts = map[string]time.Duration{config.BlogPageKind: time.Hour}
+	builder.AddBlog("blog", 1, blogGroupId)
+	site := builder.Build()
+	anonymous := site.NewClient()
+
+	anonymous.Get("/en/blog/").AssertTemplate(t, "blog/list")
+	if response := anonymous.Get("/en/blog/"); len(response.Renders) != 0 {
+		t.Fatal("page not cached")
+	}
+
+	site.NewLoggedClient("alice").PostForm("/fr/blog/save", url.Values{"title": {"Post"}, "markdown": {"Text"}})
+	response := anonymous.Get("/en/blog/")
+	if len(response.Renders) != 1 {
+		t.Fatal("cached page kept after a save under another lang")
+	}
+	if posts, _ := response.Data()["Posts"].([]blogservice.BlogPost); len(posts) != 1 {
+		t.Errorf("unexpected posts : %v", posts)
+	}
+}
+
+func TestComment(t *testing.T) {
+	builder := puzzlewebtest.NewSiteBuilder(t)
+	blog := builder.AddBlog("blog", 1, blogGroupId)
+	site := builder.Build()
+	client := site.NewLoggedClient("alice")
+
+	client.PostForm("/blog/save", url.Values{"title": {"Post"}, "markdown": {"Text"}})
+	client.PostForm("/blog/comment/save/1", url.Values{"comment": {"Nice"}}).AssertRedirect(t, "/blog/view/1")
+	client.PostForm("/blog/comment/save/1", url.Values{}).AssertRedirect(t, "/blog/view/1?error="+common.ErrorEmptyCommentKey)
+
+	if calls := blog.Comments.CallsTo("CreateComment"); len(calls) != 1 {
+		t.Errorf("CreateComment called %d times, expected 1", len(calls))
+	}
+}
+
+func TestMalformedPostId(t *testing.T) {
+	builder := puzzlewebtest.NewSiteBuilder(t)
+	builder.AddBlog("blog", 1, blogGroupId)
+	site := builder.Build()
+	client := site.NewLoggedClient("alice")
+
+	client.Get("/blog/view/abc").AssertError(t, common.ErrNotFound)
+	client.PostForm("/blog/comment/save/abc", url.Values{"comment": {"Nice"}}).AssertRedirect(t, "/?error="+common.ErrorNotFoundKey)
+	client.PostForm("/blog/delete/abc", nil).AssertRedirect(t, "/blog/?error="+common.ErrorNotFoundKey)
+}
+
+func TestPostCreationRight(t *testing.T) {
+	builder := puzzlewebtest.NewSiteBuilder(t)
+	builder.AddBlog("blog", 1, blogGroupId)
+	builder.Rights.Authorize = puzzlewebtest.ReadOnlyExcept()
+	site := builder.Build()
+	client := site.NewLoggedClient("bob")
+
+	client.Get("/blog/").AssertData(t, common.AllowedToCreateName, false)
+
+	form := url.Values{"title": {"Post"}, "markdown": {"Text"}}
+	client.PostForm("/blog/save", form).AssertRedirect(t, "/?error="+common.ErrorNotAuthorizedKey)
+}
+
+func TestServiceFailure(t *testing.T) {
+	builder := puzzlewebtest.NewSiteBuilder(t)
+	blog := builder.AddBlog("blog", 1, adminservice.PublicGroupId)
+	site := builder.Build()
+
+	blog.FailWith("GetPosts", common.ErrUnavailable)
+	site.NewClient().Get("/blog/").AssertError(t, common.ErrUnavailable)
+
+	blog.FailWith("GetPosts", errors.New("unexpected"))
+	site.NewClient().Get("/blog/").AssertStatus(t, http.StatusInternalServerError)
+}
+
+func TestFeed(t *testing.T) {
+	builder := puzzlewebtest.NewSiteBuilder(t)
+	builder.AddBlog("blog", 1, adminservice.PublicGroupId)
+	site := builder.Build()
+
+	site.NewLoggedClient("alice").PostForm("/blog/save", url.Values{"title": {"First post"}, "markdown": {"Hello"}})
+
+	response := site.NewClient().Get("/blog/rss")
+	response.AssertStatus(t, http.StatusOK)
+	if body := response.Body.String(); !strings.Contains(body, "First post") || !strings.Contains(body, "<updated>") {
+		t.Errorf("unexpected feed : %s", body)
+	}
+}
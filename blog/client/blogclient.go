@@ -37,15 +37,14 @@ type blogClient struct {
 	grpcclient.Client
 	blogId         uint64
 	groupId        uint64
-	dateFormat     string
 	authService    adminservice.AuthService
 	profileService profileservice.ProfileService
 }
 
-func New(serviceAddr string, dialOptions []grpc.DialOption, blogId uint64, groupId uint64, dateFormat string, authService adminservice.AuthService, profileService profileservice.ProfileService) blogservice.BlogService {
+func New(serviceAddr string, dialOptions []grpc.DialOption, blogId uint64, groupId uint64, authService adminservice.AuthService, profileService profileservice.ProfileService) blogservice.BlogService {
 	return blogClient{
 		Client: grpcclient.Make(serviceAddr, dialOptions...), blogId: blogId, groupId: groupId,
-		dateFormat: dateFormat, authService: authService, profileService: profileService,
+		authService: authService, profileService: profileService,
 	}
 }
 
@@ -101,7 +100,7 @@ func (client blogClient) GetPost(ctx context.Context, userId uint64, postId uint
 	if err != nil {
 		return blogservice.BlogPost{}, err
 	}
-	return convertPost(response, users[creatorId], client.dateFormat), nil
+	return convertPost(response, users[creatorId]), nil
 }
 
 func (client blogClient) GetPosts(ctx context.Context, userId uint64, start uint64, end uint64, filter string) (uint64, []blogservice.BlogPost, error) {
@@ -185,14 +184,13 @@ func (client blogClient) sortConvertPosts(ctx context.Context, list []*pb.Conten
 
 	contents := make([]blogservice.BlogPost, 0, size)
 	for _, content := range list {
-		contents = append(contents, convertPost(content, users[content.UserId], client.dateFormat))
+		contents = append(contents, convertPost(content, users[content.UserId]))
 	}
 	return contents, nil
 }
 
-func convertPost(post *pb.Content, creator profileservice.UserProfile, dateFormat string) blogservice.BlogPost {
-	createdAt := time.Unix(post.CreatedAt, 0)
+func convertPost(post *pb.Content, creator profileservice.UserProfile) blogservice.BlogPost {
 	return blogservice.BlogPost{
-		PostId: post.PostId, Creator: creator, Date: createdAt.Format(dateFormat), Title: post.Title, Content: post.Text,
+		PostId: post.PostId, Creator: creator, Date: time.Unix(post.CreatedAt, 0), Title: post.Title, Content: post.Text,
 	}
 }
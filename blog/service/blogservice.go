@@ -20,6 +20,7 @@ package blogservice
 
 import (
 	"context"
+	"time"
 
 	profileservice "github.com/dvaumoron/puzzleweb/profile/service"
 )
@@ -27,7 +28,7 @@ import (
 type BlogPost struct {
 	PostId  uint64
 	Creator profileservice.UserProfile
-	Date    string
+	Date    time.Time
 	Title   string
 	Content string
 }
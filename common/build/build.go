@@ -29,8 +29,11 @@ func BuildDefaultSite(configExtracter config.BaseConfigExtracter) (*puzzleweb.Si
 		return nil, false
 	}
 
-	settingsManager := puzzleweb.NewSettingsManager(configExtracter.ExtractSettingsConfig())
-	return puzzleweb.NewSite(configExtracter, localesManager, settingsManager), ok
+	settingsManager, ok := puzzleweb.NewSettingsManager(configExtracter.ExtractSettingsConfig())
+	if !ok {
+		return nil, false
+	}
+	return puzzleweb.NewSite(configExtracter, localesManager, settingsManager), true
 }
 
 func AddWidgetPages(site *puzzleweb.Site, initCtx context.Context, widgetPages []parser.WidgetPageConfig, configBuilder WidgetConfigBuilder, widgets map[string]parser.WidgetConfig) bool {
@@ -48,8 +51,13 @@ func AddWidgetPages(site *puzzleweb.Site, initCtx context.Context, widgetPages [
 			}
 		}
 
-		widgetPage, add := MakeWidgetPage(name, initCtx, configBuilder, widgets[widgetPageConfig.WidgetRef])
+		widgetConfig := widgets[widgetPageConfig.WidgetRef]
+		widgetPage, add := MakeWidgetPage(name, initCtx, configBuilder, widgetConfig)
 		if add {
+			widgetPage.GroupId = widgetConfig.GroupId
+			if menuConfig := widgetPageConfig.Menu; menuConfig != nil {
+				widgetPage.Menu = puzzleweb.MakeMenuInfo(*menuConfig)
+			}
 			if nested {
 				if !parentPage.AddSubPage(widgetPage) {
 					configBuilder.GetLogger().Error("Only static page can have sub page")
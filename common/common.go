@@ -49,8 +49,11 @@ type LocalesManager interface {
 	GetDefaultLang() string
 	GetAllLang() []string
 	GetMultipleLang() bool
+	GetLangInUrl() bool
 	GetLang(*gin.Context) string
 	CheckLang(string, *gin.Context) string
+	GetFallbacks(string) []string
+	GetDateFormat(string) string
 	SetLangCookie(string, *gin.Context) string
 }
 
@@ -74,13 +77,6 @@ func GetBaseUrl(levelToErase uint8, c *gin.Context) string {
 	return res[:i+1]
 }
 
-func checkTarget(target string) string {
-	if target == "" {
-		target = "/"
-	}
-	return target
-}
-
 func CheckPort(port string) string {
 	if port[0] != ':' {
 		port = ":" + port
@@ -90,12 +86,15 @@ func CheckPort(port string) string {
 
 func CreateRedirect(redirecter Redirecter) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.Redirect(http.StatusFound, checkTarget(redirecter(c)))
+		c.Redirect(http.StatusFound, CheckRedirect(c, redirecter(c)))
 	}
 }
 
+// target come from configuration, so it is not checked
 func CreateRedirectString(target string) gin.HandlerFunc {
-	target = checkTarget(target)
+	if target == "" {
+		target = "/"
+	}
 	return func(c *gin.Context) {
 		c.Redirect(http.StatusFound, target)
 	}
@@ -24,11 +24,14 @@ import (
 
 	adminservice "github.com/dvaumoron/puzzleweb/admin/service"
 	blogservice "github.com/dvaumoron/puzzleweb/blog/service"
+	"github.com/dvaumoron/puzzleweb/common/config/parser"
 	"github.com/dvaumoron/puzzleweb/common/log"
 	forumservice "github.com/dvaumoron/puzzleweb/forum/service"
+	"github.com/dvaumoron/puzzleweb/health"
 	loginservice "github.com/dvaumoron/puzzleweb/login/service"
 	markdownservice "github.com/dvaumoron/puzzleweb/markdown/service"
 	profileservice "github.com/dvaumoron/puzzleweb/profile/service"
+	"github.com/dvaumoron/puzzleweb/ratelimit"
 	widgetservice "github.com/dvaumoron/puzzleweb/remotewidget/service"
 	sessionservice "github.com/dvaumoron/puzzleweb/session/service"
 	templateservice "github.com/dvaumoron/puzzleweb/templates/service"
@@ -41,9 +44,16 @@ const (
 	DefaultFavicon = "/favicon.ico"
 )
 
+// page kinds with a configurable time to live in the page cache
+const (
+	StaticPageKind = "static"
+	BlogPageKind   = "blog"
+	ForumPageKind  = "forum"
+	WikiPageKind   = "wiki"
+)
+
 type AuthConfig = ServiceConfig[adminservice.AuthService]
 type LoginConfig = ServiceConfig[loginservice.LoginService]
-type SettingsConfig = ServiceConfig[sessionservice.SessionService]
 type TemplateConfig = ServiceConfig[templateservice.TemplateService]
 type RemoteWidgetConfig = ServiceConfig[widgetservice.WidgetService]
 
@@ -62,12 +72,21 @@ type BaseConfigExtracter interface {
 	ExtractProfileConfig() ProfileConfig
 }
 
+type SettingsConfig struct {
+	ServiceConfig[sessionservice.SessionService]
+	Schema []parser.SettingConfig
+}
+
 type LocalesConfig struct {
 	Logger         log.Logger
 	LoggerGetter   log.LoggerGetter
 	Domain         string
 	SessionTimeOut int
 	AllLang        []string
+	LangInUrl      bool
+	Fallbacks      map[string][]string // configured chains by lang
+	DateFormat     string              // default layout
+	DateFormats    map[string]string   // configured layouts by lang
 }
 
 type ServiceConfig[ServiceType any] struct {
@@ -96,15 +115,32 @@ type SessionConfig struct {
 
 type SiteConfig struct {
 	ServiceConfig[sessionservice.SessionService]
-	TemplateService    templateservice.TemplateService
-	Domain             string
-	Port               string
-	SessionTimeOut     int
-	MaxMultipartMemory int64
-	StaticFileSystem   http.FileSystem
-	FaviconPath        string
-	Page404Url         string
-	LangPicturePaths   map[string]string
+	TemplateService      templateservice.TemplateService
+	Domain               string
+	Aliases              []string // other host names served when running with virtual hosts
+	Port                 string
+	CertPath             string // HTTPS is enabled when not empty
+	KeyPath              string
+	ClientCAPath         string // mutual TLS is enabled when not empty
+	RedirectPort         string // when not empty, listen for HTTP and redirect to HTTPS
+	SessionTimeOut       int
+	ShutdownTimeOut      time.Duration // zero means waiting without limit
+	MaxMultipartMemory   int64
+	Compression          bool
+	CompressionMinSize   int
+	StaticFileSystem     http.FileSystem
+	FaviconPath          string
+	LangPicturePaths     map[string]string
+	HealthProbes         []health.Probe
+	ProbeTimeOut         time.Duration
+	HideHealthDetails    bool         // restrict readiness details to administrators
+	MetricsHandler       http.Handler // nil when the Prometheus endpoint is disabled
+	RateLimiter          *ratelimit.Limiter
+	AllowedRedirectHosts []string
+	TrustedProxies       []string // the headers giving the client IP are ignored when empty
+	RobotsText           string   // a default is used when empty
+	PageCacheSize        int      // page cache is disabled when zero
+	PageCacheTimeOuts    map[string]time.Duration
 }
 
 func (sc *SiteConfig) ExtractSessionConfig() SessionConfig {
@@ -136,7 +172,6 @@ type BlogConfig struct {
 	CommentService  forumservice.CommentService
 	Domain          string
 	Port            string
-	DateFormat      string
 	PageSize        uint64
 	ExtractSize     uint64
 	FeedFormat      string
@@ -150,8 +185,16 @@ type ForumConfig struct {
 	Args     []string
 }
 
+type MarkdownPagesConfig struct {
+	ServiceConfig[markdownservice.MarkdownService]
+	Path    string
+	GroupId uint64
+	Layout  string
+}
+
 type WikiConfig struct {
 	ServiceConfig[wikiservice.WikiService]
 	MarkdownService markdownservice.MarkdownService
+	LangInUrl       bool // the lang is in the site prefix instead of a wiki segment
 	Args            []string
 }
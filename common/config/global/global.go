@@ -31,11 +31,15 @@ import (
 	adminclient "github.com/dvaumoron/puzzleweb/admin/client"
 	adminservice "github.com/dvaumoron/puzzleweb/admin/service"
 	blogclient "github.com/dvaumoron/puzzleweb/blog/client"
+	blogservice "github.com/dvaumoron/puzzleweb/blog/service"
+	"github.com/dvaumoron/puzzleweb/common"
 	"github.com/dvaumoron/puzzleweb/common/config"
 	"github.com/dvaumoron/puzzleweb/common/config/parser"
 	"github.com/dvaumoron/puzzleweb/common/log"
+	"github.com/dvaumoron/puzzleweb/common/metrics"
 	forumclient "github.com/dvaumoron/puzzleweb/forum/client"
 	forumservice "github.com/dvaumoron/puzzleweb/forum/service"
+	"github.com/dvaumoron/puzzleweb/health"
 	loginclient "github.com/dvaumoron/puzzleweb/login/client"
 	loginservice "github.com/dvaumoron/puzzleweb/login/service"
 	markdownclient "github.com/dvaumoron/puzzleweb/markdown/client"
@@ -43,14 +47,19 @@ import (
 	strengthclient "github.com/dvaumoron/puzzleweb/passwordstrength/client"
 	profileclient "github.com/dvaumoron/puzzleweb/profile/client"
 	profileservice "github.com/dvaumoron/puzzleweb/profile/service"
+	"github.com/dvaumoron/puzzleweb/ratelimit"
 	widgetclient "github.com/dvaumoron/puzzleweb/remotewidget/client"
 	sessionclient "github.com/dvaumoron/puzzleweb/session/client"
 	sessionservice "github.com/dvaumoron/puzzleweb/session/service"
+	"github.com/dvaumoron/puzzleweb/standalone"
 	templateclient "github.com/dvaumoron/puzzleweb/templates/client"
+	localtemplate "github.com/dvaumoron/puzzleweb/templates/local"
 	templateservice "github.com/dvaumoron/puzzleweb/templates/service"
 	wikiclient "github.com/dvaumoron/puzzleweb/wiki/client"
+	wikiservice "github.com/dvaumoron/puzzleweb/wiki/service"
 	"github.com/uptrace/opentelemetry-go-extra/otelzap"
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
@@ -59,9 +68,14 @@ import (
 )
 
 const (
-	defaultName           = "default"
-	defaultSessionTimeOut = 1200
-	defaultServiceTimeOut = 5 * time.Second
+	defaultName            = "default"
+	defaultSessionTimeOut  = 1200
+	defaultServiceTimeOut  = 5 * time.Second
+	defaultShutdownTimeOut = 10 * time.Second
+	defaultProbeTimeOut    = 2 * time.Second
+	defaultRateLimitPeriod = time.Minute
+	defaultPageCacheSize   = 64 << 20
+	defaultPageTimeOut     = time.Minute
 )
 
 type loggerWrapper struct {
@@ -73,13 +87,26 @@ func (lg loggerWrapper) Logger(ctx context.Context) log.Logger {
 }
 
 type GlobalConfig struct {
-	Domain string
-	Port   string
+	Domain  string
+	Aliases []string // other host names of the site, when served as virtual host
+	Port    string
+
+	CertPath         string
+	KeyPath          string
+	ClientCAPath     string
+	HttpRedirectPort string
+
+	AllowedRedirectHosts []string
+	TrustedProxies       []string
 
 	AllLang            []string
+	LangInUrl          bool
 	SessionTimeOut     int
 	ServiceTimeOut     time.Duration
+	ShutdownTimeOut    time.Duration
 	MaxMultipartMemory int64
+	Compression        bool
+	CompressionMinSize uint64
 	DateFormat         string
 	PageSize           uint64
 	ExtractSize        uint64
@@ -88,24 +115,42 @@ type GlobalConfig struct {
 
 	StaticFileSystem http.FileSystem
 	FaviconPath      string
-	Page404Url       string
+	RobotsText       string
+
+	HealthProbes      []health.Probe
+	ProbeTimeOut      time.Duration
+	HideHealthDetails bool
+
+	PageCacheSize     uint64
+	PageCacheTimeOuts map[string]time.Duration
+
+	RateLimits     map[string]ratelimit.Limit
+	RateLimitStore ratelimit.Store // could be replaced by a shared store before ExtractSiteConfig
 
 	InitCtx          context.Context
 	Logger           log.Logger // for init phase (have the context)
 	LoggerGetter     log.LoggerGetter
 	TracerProvider   *sdktrace.TracerProvider
 	Tracer           trace.Tracer
+	MeterProvider    *sdkmetric.MeterProvider
+	MetricsHandler   http.Handler
 	LangPicturePaths map[string]string
+	LangFallbacks    map[string][]string
+	LangDateFormats  map[string]string
 
 	DialOptions     []grpc.DialOption
 	SessionService  sessionservice.SessionService
 	TemplateService templateservice.TemplateService
 	SaltService     loginservice.SaltService
 	SettingsService sessionservice.SessionService
+	SettingsSchema  []parser.SettingConfig
 	LoginService    loginservice.FullLoginService
-	RightClient     adminclient.RightClient
+	RightClient     adminservice.RightService
 	ProfileService  profileservice.AdvancedProfileService
 
+	Standalone       *standalone.Services // nil when no service is embedded
+	EmbeddedServices common.Set[string]
+
 	// lazy service
 	MarkdownServiceAddr string
 	MarkdownService     markdownservice.MarkdownService
@@ -127,7 +172,7 @@ func Init(serviceName string, version string, parsedConfig parser.ParsedConfig,
 		ctxLogger.Fatal("Failed to read configuration file", zap.Error(err))
 	}
 
-	var serviceTimeOut time.Duration
+	mp, metricsHandler := initMetrics(ctxLogger, serviceName, version, parsedConfig.PrometheusMetrics)
 
 	domain := retrieveWithDefault(ctxLogger, "domain", parsedConfig.Domain, "localhost")
 	port := retrieveWithDefault(ctxLogger, "port", parsedConfig.Port, "8080")
@@ -138,22 +183,21 @@ func Init(serviceName string, version string, parsedConfig parser.ParsedConfig,
 		sessionTimeOut = defaultSessionTimeOut
 	}
 
-	serviceTimeOutStr := parsedConfig.ServiceTimeOut
-	if serviceTimeOutStr == "" {
-		ctxLogger.Info("serviceTimeOut empty, using default", zap.Duration(defaultName, defaultServiceTimeOut))
-		serviceTimeOut = defaultServiceTimeOut
-	} else if timeOut, _ := strconv.ParseInt(serviceTimeOutStr, 10, 64); timeOut == 0 {
-		ctxLogger.Warn("Failed to parse serviceTimeOut, using default", zap.Duration(defaultName, defaultServiceTimeOut))
-		serviceTimeOut = defaultServiceTimeOut
-	} else {
-		serviceTimeOut = time.Duration(timeOut) * time.Second
-	}
+	serviceTimeOut := retrieveSecondsWithDefault(ctxLogger, "serviceTimeOut", parsedConfig.ServiceTimeOut, defaultServiceTimeOut)
+	shutdownTimeOut := retrieveSecondsWithDefault(ctxLogger, "shutdownTimeOut", parsedConfig.ShutdownTimeOut, defaultShutdownTimeOut)
+	probeTimeOut := retrieveSecondsWithDefault(ctxLogger, "probeTimeOut", parsedConfig.ProbeTimeOut, defaultProbeTimeOut)
 
 	maxMultipartMemory := parsedConfig.MaxMultipartMemory
 	if maxMultipartMemory == 0 {
 		ctxLogger.Warn("maxMultipartMemory empty, using gin default")
 	}
 
+	var compressionMinSize uint64
+	compression := !parsedConfig.DisableCompression
+	if compression {
+		compressionMinSize = retrieveUintWithDefault(ctxLogger, "compressionMinSize", parsedConfig.CompressionMinSize, 1024)
+	}
+
 	dateFormat := retrieveWithDefault(ctxLogger, "dateFormat", parsedConfig.DateFormat, "2/1/2006 15:04:05")
 	pageSize := retrieveUintWithDefault(ctxLogger, "pageSize", parsedConfig.PageSize, 20)
 	extractSize := retrieveUintWithDefault(ctxLogger, "extractSize", parsedConfig.ExtractSize, 200)
@@ -163,6 +207,7 @@ func Init(serviceName string, version string, parsedConfig parser.ParsedConfig,
 	dialOptions := []grpc.DialOption{
 		grpc.WithTransportCredentials(insecure.NewCredentials()),
 		grpc.WithUnaryInterceptor(otelgrpc.UnaryClientInterceptor()),
+		grpc.WithChainUnaryInterceptor(metrics.UnaryClientInterceptor()),
 		grpc.WithStreamInterceptor(otelgrpc.StreamClientInterceptor()),
 	}
 
@@ -171,8 +216,59 @@ func Init(serviceName string, version string, parsedConfig parser.ParsedConfig,
 	settingsService := sessionclient.New(parsedConfig.SettingsServiceAddr, dialOptions)
 	strengthService := strengthclient.New(parsedConfig.PasswordStrengthServiceAddr, dialOptions)
 	saltService := puzzlesaltclient.Make(parsedConfig.SaltServiceAddr, dialOptions)
-	loginService := loginclient.New(parsedConfig.LoginServiceAddr, dialOptions, dateFormat, saltService, strengthService)
-	rightClient := adminclient.Make(parsedConfig.RightServiceAddr, dialOptions, logger)
+	loginService := loginclient.New(parsedConfig.LoginServiceAddr, dialOptions, saltService, strengthService)
+	var rightClient adminservice.RightService = adminclient.Make(parsedConfig.RightServiceAddr, dialOptions, logger)
+
+	standaloneServices, embedded := initStandalone(ctxLogger, parsedConfig.Standalone, loggerGetter)
+	if embedded.Contains(sessionName) {
+		sessionService, err = standaloneServices.SessionService(sessionName, time.Duration(sessionTimeOut)*time.Second)
+		checkEmbedded(ctxLogger, sessionName, err)
+	}
+	if embedded.Contains(templateName) {
+		templatesPath := parsedConfig.Standalone.TemplatesPath
+		if templatesPath == "" {
+			ctxLogger.Fatal("standalone templatesPath is required with the embedded template service")
+		}
+		templateService, err = standaloneServices.TemplateService(templatesPath)
+		checkEmbedded(ctxLogger, templateName, err)
+	}
+	if embedded.Contains(settingsName) {
+		// settings never expire
+		settingsService, err = standaloneServices.SessionService(settingsName, 0)
+		checkEmbedded(ctxLogger, settingsName, err)
+	}
+	if embedded.Contains(passwordStrengthName) {
+		strengthService = standaloneServices.PasswordStrengthService()
+	}
+	if embedded.Contains(loginName) {
+		loginService, err = standaloneServices.LoginService(strengthService)
+		checkEmbedded(ctxLogger, loginName, err)
+	} else if embedded.Contains(passwordStrengthName) {
+		loginService = loginclient.New(parsedConfig.LoginServiceAddr, dialOptions, saltService, strengthService)
+	}
+	if embedded.Contains(rightName) {
+		adminUserId := parsedConfig.Standalone.AdminUserId
+		if adminUserId == 0 {
+			ctxLogger.Warn("standalone adminUserId empty, no user can give roles with the embedded right service")
+		} else {
+			ctxLogger.Info("Embedded right service administrator", zap.Uint64("userId", adminUserId))
+		}
+		rightClient, err = standaloneServices.RightService(ctxLogger, adminUserId)
+		checkEmbedded(ctxLogger, rightName, err)
+	}
+
+	var markdownService markdownservice.MarkdownService
+	if embedded.Contains(markdownName) {
+		markdownService = standaloneServices.MarkdownService()
+	}
+
+	if parsedConfig.CertPath != "" && parsedConfig.KeyPath == "" {
+		ctxLogger.Fatal("keyPath is required when certPath is set")
+	}
+
+	if parsedConfig.Page404Url != "" {
+		ctxLogger.Warn("page404Url is ignored, unknown pages are rendered with the error template and a 404 status")
+	}
 
 	staticPath := retrievePath(ctxLogger, "staticPath", parsedConfig.StaticPath, "static")
 	faviconPath := retrieveWithDefault(ctxLogger, "faviconPath", parsedConfig.FaviconPath, config.DefaultFavicon)
@@ -185,49 +281,103 @@ func Init(serviceName string, version string, parsedConfig parser.ParsedConfig,
 		ctxLogger.Fatal("Can not read", zap.String("filepath", defaultPicturePath), zap.Error(err))
 	}
 
-	locales := parsedConfig.Locales
-	langNumber := len(locales)
-	allLang := make([]string, 0, langNumber)
-	langPicturePaths := make(map[string]string, langNumber)
-	for _, locale := range locales {
-		allLang = append(allLang, locale.Lang)
-		langPicturePaths[locale.Lang] = locale.PicturePath
+	robotsText, err := readRobots(parsedConfig.RobotsPath)
+	if err != nil {
+		ctxLogger.Fatal("Can not read", zap.String("filepath", parsedConfig.RobotsPath), zap.Error(err))
+	}
+	allLang, langPicturePaths, langFallbacks, langDateFormats := buildLocales(ctxLogger, parsedConfig.Locales)
+
+	if localTemplatesConfig := parsedConfig.LocalTemplates; localTemplatesConfig != nil {
+		var defaultLang string
+		if len(allLang) != 0 {
+			defaultLang = allLang[0]
+		}
+		templateService, err = localtemplate.New(
+			localTemplatesConfig.Path, localTemplatesConfig.LocalesPath, defaultLang, localTemplatesConfig.Watch, loggerGetter,
+		)
+		if err != nil {
+			ctxLogger.Fatal("Failed to load local templates", zap.String("filepath", localTemplatesConfig.Path), zap.Error(err))
+		}
+		if embedded.Contains(templateName) {
+			ctxLogger.Warn("localTemplates replaces the standalone template service")
+		}
+		// not remote
+		embedded.Add(templateName)
 	}
-	ctxLogger.Info("Declared locales", zap.Strings("locales", allLang))
 
 	// if not setted in configuration, profile are public
 	profileGroupId := retrieveUintWithDefault(ctxLogger, "profileGroupId", parsedConfig.ProfileGroupId, adminservice.PublicGroupId)
-	profileService := profileclient.New(
-		parsedConfig.ProfileServiceAddr, dialOptions, profileGroupId, defaultPicture, loginService, rightClient, loggerGetter,
-	)
+	var profileService profileservice.AdvancedProfileService
+	if embedded.Contains(profileName) {
+		profileService, err = standaloneServices.ProfileService(profileGroupId, defaultPicture, loginService, rightClient)
+		checkEmbedded(ctxLogger, profileName, err)
+	} else {
+		profileService = profileclient.New(
+			parsedConfig.ProfileServiceAddr, dialOptions, profileGroupId, defaultPicture, loginService, rightClient, loggerGetter,
+		)
+	}
+
+	var pageCacheSize uint64
+	var pageCacheTimeOuts map[string]time.Duration
+	if pageCacheConfig := parsedConfig.PageCache; pageCacheConfig != nil {
+		pageCacheSize = retrieveUintWithDefault(ctxLogger, "pageCache maxSize", pageCacheConfig.MaxSize, defaultPageCacheSize)
+		pageCacheTimeOuts = buildPageCacheTimeOuts(ctxLogger, pageCacheConfig.TimeOuts)
+	}
 
 	globalConfig := &GlobalConfig{
 		Domain: domain, Port: port, AllLang: allLang, SessionTimeOut: sessionTimeOut, ServiceTimeOut: serviceTimeOut,
-		MaxMultipartMemory: maxMultipartMemory, DateFormat: dateFormat, PageSize: pageSize, ExtractSize: extractSize,
-		FeedFormat: feedFormat, FeedSize: feedSize,
+		ShutdownTimeOut: shutdownTimeOut, MaxMultipartMemory: maxMultipartMemory, Compression: compression,
+		CompressionMinSize: compressionMinSize, DateFormat: dateFormat, PageSize: pageSize, ExtractSize: extractSize,
+		FeedFormat: feedFormat, FeedSize: feedSize, LangInUrl: parsedConfig.LangInUrl,
+
+		CertPath: parsedConfig.CertPath, KeyPath: parsedConfig.KeyPath, ClientCAPath: parsedConfig.ClientCAPath,
+		HttpRedirectPort: parsedConfig.HttpRedirectPort,
+
+		// the site domain is always allowed for absolute redirects
+		AllowedRedirectHosts: append(parsedConfig.AllowedRedirectHosts, domain),
+		TrustedProxies:       parsedConfig.TrustedProxies,
 
 		StaticFileSystem: http.FS(os.DirFS(staticPath)),
 		FaviconPath:      faviconPath,
-		Page404Url:       parsedConfig.Page404Url,
+		RobotsText:       robotsText,
+
+		HealthProbes:      buildHealthProbes(parsedConfig, dialOptions, embedded),
+		ProbeTimeOut:      probeTimeOut,
+		HideHealthDetails: parsedConfig.HideHealthDetails,
+
+		PageCacheSize:     pageCacheSize,
+		PageCacheTimeOuts: pageCacheTimeOuts,
+
+		RateLimits:     buildRateLimits(ctxLogger, parsedConfig.RateLimits),
+		RateLimitStore: ratelimit.NewMemoryStore(),
 
 		InitCtx:        initCtx,
 		Logger:         ctxLogger,
 		LoggerGetter:   loggerGetter,
 		TracerProvider: tp,
 		Tracer:         tracer,
+		MeterProvider:  mp,
+		MetricsHandler: metricsHandler,
 
 		LangPicturePaths: langPicturePaths,
+		LangFallbacks:    langFallbacks,
+		LangDateFormats:  langDateFormats,
 		DialOptions:      dialOptions,
 		SessionService:   sessionService,
 		TemplateService:  templateService,
 		SaltService:      saltService,
 		SettingsService:  settingsService,
+		SettingsSchema:   parsedConfig.Settings,
 		LoginService:     loginService,
 		RightClient:      rightClient,
 		ProfileService:   profileService,
 
+		Standalone:       standaloneServices,
+		EmbeddedServices: embedded,
+
 		ForumServiceAddr:    parsedConfig.ForumServiceAddr,
 		MarkdownServiceAddr: parsedConfig.MarkdownServiceAddr,
+		MarkdownService:     markdownService,
 		BlogServiceAddr:     parsedConfig.BlogServiceAddr,
 		WikiServiceAddr:     parsedConfig.WikiServiceAddr,
 	}
@@ -235,6 +385,146 @@ func Init(serviceName string, version string, parsedConfig parser.ParsedConfig,
 	return globalConfig, initSpan
 }
 
+// Create the configuration of a site served on the same port, with the Host header hostConfig.Domain
+// (the services are shared, except the wiki, forum and blog clients which are created for each widget).
+func (c *GlobalConfig) MakeVirtualHostConfig(hostConfig parser.VirtualHostConfig) (*GlobalConfig, bool) {
+	logger := c.Logger
+	logger.Info("Declaring virtual host", zap.String("site", hostConfig.Name), zap.String("domain", hostConfig.Domain))
+	if !require(logger, "site domain", hostConfig.Domain) {
+		return nil, false
+	}
+	if c.MarkdownServiceAddr != "" {
+		// load before the copy to share it between sites
+		c.loadMarkdown()
+	}
+
+	hostGlobalConfig := *c
+	hostGlobalConfig.Domain = hostConfig.Domain
+	hostGlobalConfig.Aliases = hostConfig.Aliases
+
+	allowedRedirectHosts := append(hostConfig.AllowedRedirectHosts, hostConfig.Domain)
+	hostGlobalConfig.AllowedRedirectHosts = append(allowedRedirectHosts, hostConfig.Aliases...)
+
+	if staticPath := hostConfig.StaticPath; staticPath != "" {
+		hostGlobalConfig.StaticFileSystem = http.FS(os.DirFS(retrievePath(logger, "staticPath", staticPath, "")))
+	}
+	if faviconPath := hostConfig.FaviconPath; faviconPath != "" {
+		hostGlobalConfig.FaviconPath = faviconPath
+	}
+	if robotsPath := hostConfig.RobotsPath; robotsPath != "" {
+		robotsText, err := readRobots(robotsPath)
+		if err != nil {
+			logger.Error("Can not read", zap.String("filepath", robotsPath), zap.Error(err))
+			return nil, false
+		}
+		hostGlobalConfig.RobotsText = robotsText
+	}
+	if len(hostConfig.Locales) != 0 {
+		hostGlobalConfig.AllLang, hostGlobalConfig.LangPicturePaths, hostGlobalConfig.LangFallbacks,
+			hostGlobalConfig.LangDateFormats = buildLocales(logger, hostConfig.Locales)
+	}
+	return &hostGlobalConfig, true
+}
+
+// an empty path is not an error (a default is used)
+func readRobots(robotsPath string) (string, error) {
+	if robotsPath == "" {
+		return "", nil
+	}
+	robotsData, err := os.ReadFile(robotsPath)
+	return string(robotsData), err
+}
+
+func buildLocales(logger log.Logger, locales []parser.LocaleConfig) ([]string, map[string]string, map[string][]string, map[string]string) {
+	langNumber := len(locales)
+	allLang := make([]string, 0, langNumber)
+	langPicturePaths := make(map[string]string, langNumber)
+	langFallbacks := map[string][]string{}
+	langDateFormats := map[string]string{}
+	for _, locale := range locales {
+		allLang = append(allLang, locale.Lang)
+		langPicturePaths[locale.Lang] = locale.PicturePath
+		if len(locale.Fallbacks) != 0 {
+			langFallbacks[locale.Lang] = locale.Fallbacks
+		}
+		if locale.DateFormat != "" {
+			langDateFormats[locale.Lang] = locale.DateFormat
+		}
+	}
+	logger.Info("Declared locales", zap.Strings("locales", allLang))
+	return allLang, langPicturePaths, langFallbacks, langDateFormats
+}
+
+func buildHealthProbes(parsedConfig parser.ParsedConfig, dialOptions []grpc.DialOption, embedded common.Set[string]) []health.Probe {
+	nameToAddr := [][2]string{
+		{sessionName, parsedConfig.SessionServiceAddr}, {templateName, parsedConfig.TemplateServiceAddr},
+		{passwordStrengthName, parsedConfig.PasswordStrengthServiceAddr}, {"salt", parsedConfig.SaltServiceAddr},
+		{loginName, parsedConfig.LoginServiceAddr}, {rightName, parsedConfig.RightServiceAddr},
+		{settingsName, parsedConfig.SettingsServiceAddr}, {profileName, parsedConfig.ProfileServiceAddr},
+		{markdownName, parsedConfig.MarkdownServiceAddr}, {wikiName, parsedConfig.WikiServiceAddr},
+		{forumName, parsedConfig.ForumServiceAddr}, {blogName, parsedConfig.BlogServiceAddr},
+	}
+	if embedded.Contains(loginName) {
+		// the embedded login service does not use salt
+		embedded = common.MakeSet(append(embedded.Slice(), "salt"))
+	}
+	for _, widget := range parsedConfig.Widgets {
+		nameToAddr = append(nameToAddr, [2]string{"widget/" + widget.Name, widget.ServiceAddr})
+	}
+
+	probes := make([]health.Probe, 0, len(nameToAddr))
+	for _, pair := range nameToAddr {
+		// lazy services could be not configured and embedded ones are not remote
+		if pair[1] != "" && !embedded.Contains(pair[0]) {
+			probes = append(probes, health.Probe{Name: pair[0], Checker: health.NewGrpcChecker(pair[1], dialOptions)})
+		}
+	}
+	return probes
+}
+
+func buildPageCacheTimeOuts(logger log.Logger, timeOutsConfig map[string]uint64) map[string]time.Duration {
+	// pages of all kinds are cached by default
+	timeOuts := map[string]time.Duration{
+		config.StaticPageKind: defaultPageTimeOut, config.BlogPageKind: defaultPageTimeOut,
+		config.ForumPageKind: defaultPageTimeOut, config.WikiPageKind: defaultPageTimeOut,
+	}
+	for kind, seconds := range timeOutsConfig {
+		if _, ok := timeOuts[kind]; !ok {
+			logger.Warn("Unknown page kind", zap.String("kind", kind))
+			continue
+		}
+		// zero disables the cache for the kind
+		timeOuts[kind] = time.Duration(seconds) * time.Second
+	}
+	return timeOuts
+}
+
+func buildRateLimits(logger log.Logger, rateLimitConfigs []parser.RateLimitConfig) map[string]ratelimit.Limit {
+	// login and register are limited even without configuration
+	limits := map[string]ratelimit.Limit{
+		ratelimit.LoginClass:    ratelimit.MakeLimit(10, defaultRateLimitPeriod, 0),
+		ratelimit.RegisterClass: ratelimit.MakeLimit(3, defaultRateLimitPeriod, 0),
+	}
+	for _, rateLimitConfig := range rateLimitConfigs {
+		class := rateLimitConfig.Class
+		switch class {
+		case ratelimit.LoginClass, ratelimit.RegisterClass, ratelimit.ContentClass, ratelimit.RemoteWidgetClass:
+		default:
+			logger.Warn("Unknown rate limit class", zap.String("class", class))
+			continue
+		}
+
+		if rateLimitConfig.Requests == 0 {
+			// explicitly disabled
+			delete(limits, class)
+			continue
+		}
+		period := retrieveSecondsWithDefault(logger, class+" rate limit period", rateLimitConfig.Period, defaultRateLimitPeriod)
+		limits[class] = ratelimit.MakeLimit(rateLimitConfig.Requests, period, rateLimitConfig.Burst)
+	}
+	return limits
+}
+
 func (c *GlobalConfig) loadMarkdown() bool {
 	if c.MarkdownService == nil {
 		if !require(c.Logger, "markdownServiceAddr", c.MarkdownServiceAddr) {
@@ -246,15 +536,20 @@ func (c *GlobalConfig) loadMarkdown() bool {
 }
 
 func (c *GlobalConfig) loadWiki() bool {
-	return c.loadMarkdown() && require(c.Logger, "wikiServiceAddr", c.WikiServiceAddr)
+	return c.loadMarkdown() && c.requireAddr(wikiName, "wikiServiceAddr", c.WikiServiceAddr)
 }
 
 func (c *GlobalConfig) loadForum() bool {
-	return require(c.Logger, "forumServiceAddr", c.ForumServiceAddr)
+	return c.requireAddr(forumName, "forumServiceAddr", c.ForumServiceAddr)
 }
 
 func (c *GlobalConfig) loadBlog() bool {
-	return c.loadForum() && c.loadMarkdown() && require(c.Logger, "blogServiceAddr", c.BlogServiceAddr)
+	return c.loadForum() && c.loadMarkdown() && c.requireAddr(blogName, "blogServiceAddr", c.BlogServiceAddr)
+}
+
+// embedded services have no address
+func (c *GlobalConfig) requireAddr(serviceName string, name string, value string) bool {
+	return c.EmbeddedServices.Contains(serviceName) || require(c.Logger, name, value)
 }
 
 func (c *GlobalConfig) GetLogger() log.Logger {
@@ -276,15 +571,21 @@ func (c *GlobalConfig) ExtractAuthConfig() config.AuthConfig {
 func (c *GlobalConfig) ExtractLocalesConfig() config.LocalesConfig {
 	return config.LocalesConfig{
 		Logger: c.Logger, LoggerGetter: c.LoggerGetter, Domain: c.Domain, SessionTimeOut: c.SessionTimeOut, AllLang: c.AllLang,
+		LangInUrl: c.LangInUrl, Fallbacks: c.LangFallbacks, DateFormat: c.DateFormat, DateFormats: c.LangDateFormats,
 	}
 }
 
 func (c *GlobalConfig) ExtractSiteConfig() config.SiteConfig {
 	return config.SiteConfig{
 		ServiceConfig: config.MakeServiceConfig(c, c.SessionService), TemplateService: c.TemplateService,
-		Domain: c.Domain, Port: c.Port, SessionTimeOut: c.SessionTimeOut, MaxMultipartMemory: c.MaxMultipartMemory,
-		StaticFileSystem: c.StaticFileSystem, FaviconPath: c.FaviconPath, LangPicturePaths: c.LangPicturePaths,
-		Page404Url: c.Page404Url,
+		Domain: c.Domain, Aliases: c.Aliases, Port: c.Port, CertPath: c.CertPath, KeyPath: c.KeyPath, ClientCAPath: c.ClientCAPath,
+		RedirectPort: c.HttpRedirectPort, SessionTimeOut: c.SessionTimeOut, ShutdownTimeOut: c.ShutdownTimeOut,
+		MaxMultipartMemory: c.MaxMultipartMemory, Compression: c.Compression,
+		CompressionMinSize: int(c.CompressionMinSize), StaticFileSystem: c.StaticFileSystem, FaviconPath: c.FaviconPath,
+		LangPicturePaths: c.LangPicturePaths, HealthProbes: c.HealthProbes,
+		ProbeTimeOut: c.ProbeTimeOut, HideHealthDetails: c.HideHealthDetails, MetricsHandler: c.MetricsHandler,
+		RateLimiter: ratelimit.NewLimiter(c.RateLimitStore, c.RateLimits), AllowedRedirectHosts: c.AllowedRedirectHosts,
+		TrustedProxies: c.TrustedProxies, RobotsText: c.RobotsText, PageCacheSize: int(c.PageCacheSize), PageCacheTimeOuts: c.PageCacheTimeOuts,
 	}
 }
 
@@ -307,42 +608,67 @@ func (c *GlobalConfig) ExtractProfileConfig() config.ProfileConfig {
 }
 
 func (c *GlobalConfig) ExtractSettingsConfig() config.SettingsConfig {
-	return config.MakeServiceConfig(c, c.SettingsService)
+	return config.SettingsConfig{ServiceConfig: config.MakeServiceConfig(c, c.SettingsService), Schema: c.SettingsSchema}
+}
+
+func (c *GlobalConfig) MakeMarkdownPagesConfig(pagesConfig parser.MarkdownPagesConfig) (config.MarkdownPagesConfig, bool) {
+	ok := c.loadMarkdown()
+	return config.MarkdownPagesConfig{
+		ServiceConfig: config.MakeServiceConfig(c, c.MarkdownService), Path: pagesConfig.Path, GroupId: pagesConfig.GroupId,
+		Layout: retrieveWithDefault(c.Logger, "markdownPages layout", pagesConfig.Layout, "markdown"),
+	}, ok
 }
 
 func (c *GlobalConfig) MakeWikiConfig(widgetConfig parser.WidgetConfig) (config.WikiConfig, bool) {
-	return config.WikiConfig{
-		ServiceConfig: config.MakeServiceConfig(c, wikiclient.New(
-			c.WikiServiceAddr, c.DialOptions, widgetConfig.ObjectId, widgetConfig.GroupId, c.DateFormat,
+	wikiService, ok := chooseService(c, wikiName, func() (wikiservice.WikiService, error) {
+		return c.Standalone.WikiService(widgetConfig.ObjectId, widgetConfig.GroupId, c.RightClient, c.ProfileService)
+	}, func() wikiservice.WikiService {
+		return wikiclient.New(
+			c.WikiServiceAddr, c.DialOptions, widgetConfig.ObjectId, widgetConfig.GroupId,
 			c.RightClient, c.ProfileService, c.LoggerGetter,
-		)),
-		MarkdownService: c.MarkdownService, Args: widgetConfig.Templates,
-	}, c.loadWiki()
+		)
+	})
+	return config.WikiConfig{
+		ServiceConfig:   config.MakeServiceConfig(c, wikiService),
+		MarkdownService: c.MarkdownService, LangInUrl: c.LangInUrl, Args: widgetConfig.Templates,
+	}, ok && c.loadWiki()
 }
 
 func (c *GlobalConfig) MakeForumConfig(widgetConfig parser.WidgetConfig) (config.ForumConfig, bool) {
+	forumService, ok := c.makeForumService(widgetConfig)
 	return config.ForumConfig{
-		ServiceConfig: config.MakeServiceConfig[forumservice.ForumService](c, forumclient.New(
-			c.ForumServiceAddr, c.DialOptions, widgetConfig.ObjectId, widgetConfig.GroupId, c.DateFormat,
-			c.RightClient, c.ProfileService, c.LoggerGetter,
-		)),
-		PageSize: c.PageSize, Args: widgetConfig.Templates,
-	}, c.loadForum()
+		ServiceConfig: config.MakeServiceConfig[forumservice.ForumService](c, forumService),
+		PageSize:      c.PageSize, Args: widgetConfig.Templates,
+	}, ok && c.loadForum()
 }
 
 func (c *GlobalConfig) MakeBlogConfig(widgetConfig parser.WidgetConfig) (config.BlogConfig, bool) {
-	return config.BlogConfig{
-		ServiceConfig: config.MakeServiceConfig(c, blogclient.New(
-			c.BlogServiceAddr, c.DialOptions, widgetConfig.ObjectId, widgetConfig.GroupId, c.DateFormat,
+	blogService, ok := chooseService(c, blogName, func() (blogservice.BlogService, error) {
+		return c.Standalone.BlogService(widgetConfig.ObjectId, widgetConfig.GroupId, c.RightClient, c.ProfileService)
+	}, func() blogservice.BlogService {
+		return blogclient.New(
+			c.BlogServiceAddr, c.DialOptions, widgetConfig.ObjectId, widgetConfig.GroupId,
 			c.RightClient, c.ProfileService,
-		)),
-		MarkdownService: c.MarkdownService, CommentService: forumclient.New(
-			c.ForumServiceAddr, c.DialOptions, widgetConfig.ObjectId, widgetConfig.GroupId, c.DateFormat,
-			c.RightClient, c.ProfileService, c.LoggerGetter,
-		),
-		Domain: c.Domain, Port: c.Port, DateFormat: c.DateFormat, PageSize: c.PageSize, ExtractSize: c.ExtractSize,
+		)
+	})
+	commentService, ok2 := c.makeForumService(widgetConfig)
+	return config.BlogConfig{
+		ServiceConfig:   config.MakeServiceConfig(c, blogService),
+		MarkdownService: c.MarkdownService, CommentService: commentService,
+		Domain: c.Domain, Port: c.Port, PageSize: c.PageSize, ExtractSize: c.ExtractSize,
 		FeedFormat: c.FeedFormat, FeedSize: c.FeedSize, Args: widgetConfig.Templates,
-	}, c.loadBlog()
+	}, ok && ok2 && c.loadBlog()
+}
+
+func (c *GlobalConfig) makeForumService(widgetConfig parser.WidgetConfig) (forumservice.FullForumService, bool) {
+	return chooseService(c, forumName, func() (forumservice.FullForumService, error) {
+		return c.Standalone.ForumService(widgetConfig.ObjectId, widgetConfig.GroupId, c.RightClient, c.ProfileService)
+	}, func() forumservice.FullForumService {
+		return forumclient.New(
+			c.ForumServiceAddr, c.DialOptions, widgetConfig.ObjectId, widgetConfig.GroupId,
+			c.RightClient, c.ProfileService, c.LoggerGetter,
+		)
+	})
 }
 
 func (c *GlobalConfig) MakeWidgetConfig(widgetConfig parser.WidgetConfig) (config.RemoteWidgetConfig, bool) {
@@ -368,6 +694,19 @@ func retrieveUintWithDefault(logger log.Logger, name string, value uint64, defau
 	return value
 }
 
+// value is a number of seconds
+func retrieveSecondsWithDefault(logger log.Logger, name string, value string, defaultValue time.Duration) time.Duration {
+	if value == "" {
+		logger.Info(name+" empty, using default", zap.Duration(defaultName, defaultValue))
+		return defaultValue
+	}
+	if seconds, _ := strconv.ParseInt(value, 10, 64); seconds != 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	logger.Warn("Failed to parse "+name+", using default", zap.Duration(defaultName, defaultValue))
+	return defaultValue
+}
+
 func retrievePath(logger log.Logger, name string, path string, defaultPath string) string {
 	path = retrieveWithDefault(logger, name, path, defaultPath)
 	if last := len(path) - 1; path[last] == '/' {
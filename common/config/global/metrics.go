@@ -0,0 +1,77 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package globalconfig
+
+import (
+	"context"
+	"net/http"
+	"os"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/uptrace/opentelemetry-go-extra/otelzap"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	otelprometheus "go.opentelemetry.io/otel/exporters/prometheus"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.uber.org/zap"
+)
+
+// Follow the puzzletelemetry logic for traces (OTLP export when EXEC_ENV is setted),
+// the returned handler is nil when the Prometheus endpoint is disabled.
+func initMetrics(logger otelzap.LoggerWithCtx, serviceName string, version string, prometheusEnabled bool) (*sdkmetric.MeterProvider, http.Handler) {
+	execEnv := os.Getenv("EXEC_ENV")
+	rsc, _ := resource.Merge(
+		resource.Default(),
+		resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceName(serviceName),
+			semconv.ServiceVersion(version),
+			attribute.String("environment", execEnv),
+		),
+	)
+
+	opts := make([]sdkmetric.Option, 0, 3)
+	opts = append(opts, sdkmetric.WithResource(rsc))
+	if execEnv != "" {
+		exp, err := otlpmetricgrpc.New(context.Background())
+		if err != nil {
+			logger.Fatal("Failed to init metric exporter", zap.Error(err))
+		}
+		opts = append(opts, sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exp)))
+	}
+
+	var metricsHandler http.Handler
+	if prometheusEnabled {
+		registry := prometheus.NewRegistry()
+		exp, err := otelprometheus.New(otelprometheus.WithRegisterer(registry))
+		if err != nil {
+			logger.Fatal("Failed to init Prometheus exporter", zap.Error(err))
+		}
+		opts = append(opts, sdkmetric.WithReader(exp))
+		metricsHandler = promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+	}
+
+	mp := sdkmetric.NewMeterProvider(opts...)
+	otel.SetMeterProvider(mp)
+	return mp, metricsHandler
+}
@@ -0,0 +1,97 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package globalconfig
+
+import (
+	"github.com/dvaumoron/puzzleweb/common"
+	"github.com/dvaumoron/puzzleweb/common/config/parser"
+	"github.com/dvaumoron/puzzleweb/common/log"
+	"github.com/dvaumoron/puzzleweb/standalone"
+	"github.com/uptrace/opentelemetry-go-extra/otelzap"
+	"go.uber.org/zap"
+)
+
+// names of the services which can be embedded (also used as names in the health probes)
+const (
+	sessionName          = "session"
+	templateName         = "template"
+	passwordStrengthName = "passwordStrength"
+	loginName            = "login"
+	rightName            = "right"
+	settingsName         = "settings"
+	profileName          = "profile"
+	markdownName         = "markdown"
+	wikiName             = "wiki"
+	forumName            = "forum"
+	blogName             = "blog"
+)
+
+var embeddableNames = common.MakeSet([]string{
+	sessionName, templateName, passwordStrengthName, loginName, rightName, settingsName,
+	profileName, markdownName, wikiName, forumName, blogName,
+})
+
+func initStandalone(logger otelzap.LoggerWithCtx, standaloneConfig *parser.StandaloneConfig, loggerGetter log.LoggerGetter) (*standalone.Services, common.Set[string]) {
+	embedded := common.Set[string]{}
+	if standaloneConfig == nil {
+		return nil, embedded
+	}
+
+	for _, name := range standaloneConfig.Services {
+		if embeddableNames.Contains(name) {
+			embedded.Add(name)
+		} else {
+			logger.Warn("Unknown standalone service", zap.String("service", name))
+		}
+	}
+	if len(embedded) == 0 {
+		return nil, embedded
+	}
+	logger.Info("Embedding services", zap.Strings("services", embedded.Slice()))
+
+	storagePath := standaloneConfig.StoragePath
+	if storagePath == "" {
+		logger.Warn("standalone storagePath empty, embedded services data will be lost at shutdown")
+	}
+	services, err := standalone.New(storagePath, loggerGetter)
+	if err != nil {
+		logger.Fatal("Failed to open standalone storage", zap.String("filepath", storagePath), zap.Error(err))
+	}
+	return services, embedded
+}
+
+func checkEmbedded(logger otelzap.LoggerWithCtx, name string, err error) {
+	if err != nil {
+		logger.Fatal("Failed to create embedded service", zap.String("service", name), zap.Error(err))
+	}
+}
+
+// use the embedded implementation of the service when configured, the client otherwise
+func chooseService[S any](c *GlobalConfig, name string, makeEmbedded func() (S, error), makeClient func() S) (S, bool) {
+	if !c.EmbeddedServices.Contains(name) {
+		return makeClient(), true
+	}
+
+	service, err := makeEmbedded()
+	if err != nil {
+		c.Logger.Error("Failed to create embedded service", zap.String("service", name), zap.Error(err))
+		return service, false
+	}
+	return service, true
+}
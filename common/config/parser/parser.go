@@ -36,16 +36,33 @@ type ParsedConfig struct {
 
 	SessionTimeOut     int    `hcl:"sessionTimeOut,optional" yaml:"sessionTimeOut"`
 	ServiceTimeOut     string `hcl:"serviceTimeOut,optional" yaml:"serviceTimeOut"`
+	ShutdownTimeOut    string `hcl:"shutdownTimeOut,optional" yaml:"shutdownTimeOut"`
 	MaxMultipartMemory int64  `hcl:"maxMultipartMemory,optional" yaml:"maxMultipartMemory"`
+	DisableCompression bool   `hcl:"disableCompression,optional" yaml:"disableCompression"`
+	CompressionMinSize uint64 `hcl:"compressionMinSize,optional" yaml:"compressionMinSize"`
 	DateFormat         string `hcl:"dateFormat,optional" yaml:"dateFormat"`
 	PageSize           uint64 `hcl:"pageSize,optional" yaml:"pageSize"`
 	ExtractSize        uint64 `hcl:"extractSize,optional" yaml:"extractSize"`
 	FeedFormat         string `hcl:"feedFormat,optional" yaml:"feedFormat"`
 	FeedSize           uint64 `hcl:"feedSize,optional" yaml:"feedSize"`
+	LangInUrl          bool   `hcl:"langInUrl,optional" yaml:"langInUrl"` // pages served under "/{lang}/" instead of using the lang cookie
+
+	CertPath         string `hcl:"certPath,optional" yaml:"certPath"`
+	KeyPath          string `hcl:"keyPath,optional" yaml:"keyPath"`
+	ClientCAPath     string `hcl:"clientCAPath,optional" yaml:"clientCAPath"`
+	HttpRedirectPort string `hcl:"httpRedirectPort,optional" yaml:"httpRedirectPort"`
+
+	AllowedRedirectHosts []string `hcl:"allowedRedirectHosts,optional" yaml:"allowedRedirectHosts"`
+	TrustedProxies       []string `hcl:"trustedProxies,optional" yaml:"trustedProxies"` // IPs or CIDRs allowed to give the client IP (X-Forwarded-For), none when empty
+
+	ProbeTimeOut      string `hcl:"probeTimeOut,optional" yaml:"probeTimeOut"`
+	HideHealthDetails bool   `hcl:"hideHealthDetails,optional" yaml:"hideHealthDetails"`
+	PrometheusMetrics bool   `hcl:"prometheusMetrics,optional" yaml:"prometheusMetrics"`
 
 	StaticPath  string `hcl:"staticPath,optional" yaml:"staticPath"`
 	FaviconPath string `hcl:"faviconPath,optional" yaml:"faviconPath"`
-	Page404Url  string `hcl:"page404Url,optional" yaml:"page404Url"`
+	Page404Url  string `hcl:"page404Url,optional" yaml:"page404Url"` // deprecated, kept to parse old configurations
+	RobotsPath  string `hcl:"robotsPath,optional" yaml:"robotsPath"`
 
 	ProfileGroupId            uint64 `hcl:"profileGroupId,optional" yaml:"profileGroupId"`
 	ProfileDefaultPicturePath string `hcl:"profileDefaultPicturePath,optional" yaml:"profileDefaultPicturePath"`
@@ -58,16 +75,24 @@ type ParsedConfig struct {
 	RightServiceAddr            string `hcl:"rightServiceAddr,optional" yaml:"rightServiceAddr"`
 	SettingsServiceAddr         string `hcl:"settingsServiceAddr,optional" yaml:"settingsServiceAddr"`
 	ProfileServiceAddr          string `hcl:"profileServiceAddr,optional" yaml:"profileServiceAddr"`
-	ForumServiceAddr            string `hcl:"forumServiceAddr" yaml:"forumServiceAddr"`
-	MarkdownServiceAddr         string `hcl:"markdownServiceAddr" yaml:"markdownServiceAddr"`
-	BlogServiceAddr             string `hcl:"blogServiceAddr" yaml:"blogServiceAddr"`
-	WikiServiceAddr             string `hcl:"wikiServiceAddr" yaml:"wikiServiceAddr"`
+	ForumServiceAddr            string `hcl:"forumServiceAddr,optional" yaml:"forumServiceAddr"`
+	MarkdownServiceAddr         string `hcl:"markdownServiceAddr,optional" yaml:"markdownServiceAddr"`
+	BlogServiceAddr             string `hcl:"blogServiceAddr,optional" yaml:"blogServiceAddr"`
+	WikiServiceAddr             string `hcl:"wikiServiceAddr,optional" yaml:"wikiServiceAddr"`
 
+	LocalTemplates   *LocalTemplatesConfig   `hcl:"localTemplates,block" yaml:"localTemplates"`
+	Standalone       *StandaloneConfig       `hcl:"standalone,block" yaml:"standalone"`
+	PageCache        *PageCacheConfig        `hcl:"pageCache,block" yaml:"pageCache"`
+	RateLimits       []RateLimitConfig       `hcl:"rateLimit,block" yaml:"rateLimits"`
 	Locales          []LocaleConfig          `hcl:"locale,block" yaml:"locales"`
 	PermissionGroups []PermissionGroupConfig `hcl:"permission,block" yaml:"permissionGroups"`
 	StaticPages      []StaticPagesConfig     `hcl:"staticPages,block" yaml:"staticPages"`
+	MarkdownPages    []MarkdownPagesConfig   `hcl:"markdownPages,block" yaml:"markdownPages"`
 	Widgets          []WidgetConfig          `hcl:"widget,block" yaml:"widgets"`
 	WidgetPages      []WidgetPageConfig      `hcl:"widgetPage,block" yaml:"widgetPages"`
+	Redirects        []RedirectConfig        `hcl:"redirect,block" yaml:"redirects"`
+	Settings         []SettingConfig         `hcl:"setting,block" yaml:"settings"`
+	Sites            []VirtualHostConfig     `hcl:"site,block" yaml:"sites"`
 }
 
 func (frame *ParsedConfig) WidgetsAsMap() map[string]WidgetConfig {
@@ -78,9 +103,56 @@ func (frame *ParsedConfig) WidgetsAsMap() map[string]WidgetConfig {
 	return res
 }
 
+// Additional site served on the same port, selected with the Host header
+// (services and widgets are shared, empty values are inherited from the main configuration).
+type VirtualHostConfig struct {
+	Name                 string                `hcl:"name,label" yaml:"name"`
+	Domain               string                `hcl:"domain" yaml:"domain"`
+	Aliases              []string              `hcl:"aliases,optional" yaml:"aliases"`
+	Default              bool                  `hcl:"default,optional" yaml:"default"` // serve unknown hosts
+	StaticPath           string                `hcl:"staticPath,optional" yaml:"staticPath"`
+	FaviconPath          string                `hcl:"faviconPath,optional" yaml:"faviconPath"`
+	RobotsPath           string                `hcl:"robotsPath,optional" yaml:"robotsPath"`
+	AllowedRedirectHosts []string              `hcl:"allowedRedirectHosts,optional" yaml:"allowedRedirectHosts"`
+	Locales              []LocaleConfig        `hcl:"locale,block" yaml:"locales"`
+	StaticPages          []StaticPagesConfig   `hcl:"staticPages,block" yaml:"staticPages"`
+	MarkdownPages        []MarkdownPagesConfig `hcl:"markdownPages,block" yaml:"markdownPages"`
+	WidgetPages          []WidgetPageConfig    `hcl:"widgetPage,block" yaml:"widgetPages"`
+	Redirects            []RedirectConfig      `hcl:"redirect,block" yaml:"redirects"`
+}
+
+// Templates rendered in the server process instead of calling templateServiceAddr.
+type LocalTemplatesConfig struct {
+	Path        string `hcl:"path" yaml:"path"`
+	LocalesPath string `hcl:"localesPath,optional" yaml:"localesPath"` // directory with "messages_<lang>.properties" files
+	Watch       bool   `hcl:"watch,optional" yaml:"watch"`             // reload on change (for development)
+}
+
+// Services embedded in the server process instead of called with gRPC.
+type StandaloneConfig struct {
+	Services      []string `hcl:"services" yaml:"services"`                    // like "session", "login" or "wiki"
+	StoragePath   string   `hcl:"storagePath,optional" yaml:"storagePath"`     // BoltDB file, data are kept in memory when empty
+	TemplatesPath string   `hcl:"templatesPath,optional" yaml:"templatesPath"` // required with the "template" service
+	AdminUserId   uint64   `hcl:"adminUserId,optional" yaml:"adminUserId"`     // user with every right in the "right" service (to give roles to others)
+}
+
+type PageCacheConfig struct {
+	MaxSize  uint64            `hcl:"maxSize,optional" yaml:"maxSize"`
+	TimeOuts map[string]uint64 `hcl:"timeOuts,optional" yaml:"timeOuts"` // seconds by page kind
+}
+
+type RateLimitConfig struct {
+	Class    string `hcl:"class,label" yaml:"class"`
+	Requests uint64 `hcl:"requests" yaml:"requests"`
+	Period   string `hcl:"period,optional" yaml:"period"`
+	Burst    uint64 `hcl:"burst,optional" yaml:"burst"`
+}
+
 type LocaleConfig struct {
-	Lang        string `hcl:"lang,label" yaml:"lang"`
-	PicturePath string `hcl:"picturePath" yaml:"picturePath"`
+	Lang        string   `hcl:"lang,label" yaml:"lang"`
+	PicturePath string   `hcl:"picturePath" yaml:"picturePath"`
+	Fallbacks   []string `hcl:"fallbacks,optional" yaml:"fallbacks"`   // tried before the parent languages and the default one
+	DateFormat  string   `hcl:"dateFormat,optional" yaml:"dateFormat"` // Go layout, the global dateFormat when empty
 }
 
 type PermissionGroupConfig struct {
@@ -89,9 +161,49 @@ type PermissionGroupConfig struct {
 }
 
 type StaticPagesConfig struct {
-	GroupId   uint64   `hcl:"groupId" yaml:"groupId"`
-	Hidden    bool     `hcl:"hidden,optional" yaml:"hidden"`
-	Locations []string `hcl:"locations" yaml:"locations"`
+	GroupId   uint64       `hcl:"groupId" yaml:"groupId"`
+	Hidden    bool         `hcl:"hidden,optional" yaml:"hidden"`
+	Locations []string     `hcl:"locations" yaml:"locations"`
+	Menus     []MenuConfig `hcl:"menu,block" yaml:"menus"` // matched with the locations
+}
+
+// Navigation metadata of a page, all the fields are optional.
+type MenuConfig struct {
+	Location  string `hcl:"location,optional" yaml:"location"` // in staticPages, ignored in widgetPage
+	Weight    int    `hcl:"weight,optional" yaml:"weight"`     // sub pages are sorted by ascending weight
+	Icon      string `hcl:"icon,optional" yaml:"icon"`
+	Link      string `hcl:"link,optional" yaml:"link"` // external url used instead of the page one in menus
+	Group     string `hcl:"group,optional" yaml:"group"`
+	Separator bool   `hcl:"separator,optional" yaml:"separator"`
+	NewTab    bool   `hcl:"newTab,optional" yaml:"newTab"`
+}
+
+// Static pages from the Markdown files of a directory ("docs/install.md" is served on "/docs/install",
+// "docs/index.md" on "/docs" and "about.fr.md" is the french variant of "about.md"), the front matter
+// of a file can override the group and set the title, the description, the visibility and the menu order.
+type MarkdownPagesConfig struct {
+	Path    string `hcl:"path" yaml:"path"`
+	GroupId uint64 `hcl:"groupId,optional" yaml:"groupId"`
+	Layout  string `hcl:"layout,optional" yaml:"layout"` // template receiving the rendered Markdown
+}
+
+// Redirection of the paths without page, the segments of From can be ":name" (one segment)
+// or a final "*name" (the remaining segments), they are replaced in To (like "/old/*rest" to "/new/*rest").
+// An alias serves the page of To on From without redirecting (even when a page is declared on From).
+type RedirectConfig struct {
+	From   string `hcl:"from" yaml:"from"`
+	To     string `hcl:"to" yaml:"to"`
+	Status int    `hcl:"status,optional" yaml:"status"` // 301 (default), 302, 307 or 308
+	Alias  bool   `hcl:"alias,optional" yaml:"alias"`
+}
+
+// User setting added to the settings page (after lang and timeZone, which are always declared).
+type SettingConfig struct {
+	Key     string   `hcl:"key,label" yaml:"key"`
+	Type    string   `hcl:"type,optional" yaml:"type"` // enum, bool, int or string (default)
+	Default string   `hcl:"default,optional" yaml:"default"`
+	Values  []string `hcl:"values,optional" yaml:"values"` // allowed values of an enum
+	Label   string   `hcl:"label,optional" yaml:"label"`   // message key, "Setting" followed by the key in camel case when empty
 }
 
 type WidgetConfig struct {
@@ -104,8 +216,9 @@ type WidgetConfig struct {
 }
 
 type WidgetPageConfig struct {
-	Path      string `hcl:"path,label" yaml:"path"`
-	WidgetRef string `hcl:"widgetRef" yaml:"widgetRef"`
+	Path      string      `hcl:"path,label" yaml:"path"`
+	WidgetRef string      `hcl:"widgetRef" yaml:"widgetRef"`
+	Menu      *MenuConfig `hcl:"menu,block" yaml:"menu"`
 }
 
 func ParseConfig(path string) (ParsedConfig, error) {
@@ -19,17 +19,24 @@
 package common
 
 import (
+	"context"
 	"errors"
+	"net/http"
 	"strings"
 
 	"github.com/dvaumoron/puzzleweb/common/log"
+	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 const WrongLangKey = "WrongLang"
 
 const ReportingPlaceName = "reporting_place"
 
+const ErrorPageName = "ErrorPage"
+
 const (
 	ErrorKey       = "error"
 	errorKeyEq     = ErrorKey + "="
@@ -47,31 +54,89 @@ const (
 	ErrorEmptyPasswordKey        = "EmptyPassword"
 	ErrorExistingLoginKey        = "ExistingLogin"
 	ErrorNotAuthorizedKey        = "ErrorNotAuthorized"
+	ErrorNotFoundKey             = "ErrorNotFound"
 	ErrorTechnicalKey            = "ErrorTechnicalProblem"
+	ErrorTooManyRequestsKey      = "TooManyRequests"
+	ErrorUnavailableKey          = "ErrorUnavailable"
+	ErrorUnknownSettingKey       = "UnknownSetting"
 	ErrorUpdateKey               = "ErrorUpdate"
 	ErrorWeakPasswordKey         = "WeakPassword"
 	ErrorWrongConfirmPasswordKey = "WrongConfirmPassword"
+	ErrorWrongCsrfTokenKey       = "WrongCsrfToken"
 	ErrorWrongLangKey            = "WrongLang"
 	ErrorWrongLoginKey           = "WrongLogin"
+	ErrorWrongSettingKey         = "WrongSetting"
+	ErrorWrongTimeZoneKey        = "WrongTimeZone"
 )
 
 const originalErrorMsg = "Original error"
 
 var (
-	ErrBadRoleName   = errors.New(ErrorBadRoleNameKey)
-	ErrBaseVersion   = errors.New(ErrorBaseVersionKey)
-	ErrEmptyComment  = errors.New(ErrorEmptyCommentKey)
-	ErrEmptyLogin    = errors.New(ErrorEmptyLoginKey)
-	ErrEmptyPassword = errors.New(ErrorEmptyPasswordKey)
-	ErrExistingLogin = errors.New(ErrorExistingLoginKey)
-	ErrNotAuthorized = errors.New(ErrorNotAuthorizedKey)
-	ErrTechnical     = errors.New(ErrorTechnicalKey)
-	ErrUpdate        = errors.New(ErrorUpdateKey)
-	ErrWeakPassword  = errors.New(ErrorWeakPasswordKey)
-	ErrWrongConfirm  = errors.New(ErrorWrongConfirmPasswordKey)
-	ErrWrongLogin    = errors.New(ErrorWrongLoginKey)
+	ErrBadRoleName     error = NewStatusError(http.StatusBadRequest, ErrorBadRoleNameKey)
+	ErrBaseVersion     error = NewStatusError(http.StatusConflict, ErrorBaseVersionKey)
+	ErrEmptyComment    error = NewStatusError(http.StatusBadRequest, ErrorEmptyCommentKey)
+	ErrEmptyLogin      error = NewStatusError(http.StatusBadRequest, ErrorEmptyLoginKey)
+	ErrEmptyPassword   error = NewStatusError(http.StatusBadRequest, ErrorEmptyPasswordKey)
+	ErrExistingLogin   error = NewStatusError(http.StatusConflict, ErrorExistingLoginKey)
+	ErrNotAuthorized   error = NewStatusError(http.StatusForbidden, ErrorNotAuthorizedKey)
+	ErrNotFound        error = NewStatusError(http.StatusNotFound, ErrorNotFoundKey)
+	ErrTechnical       error = NewStatusError(http.StatusInternalServerError, ErrorTechnicalKey)
+	ErrTooManyRequests error = NewStatusError(http.StatusTooManyRequests, ErrorTooManyRequestsKey)
+	ErrUnavailable     error = NewStatusError(http.StatusServiceUnavailable, ErrorUnavailableKey)
+	ErrUpdate          error = NewStatusError(http.StatusInternalServerError, ErrorUpdateKey)
+	ErrWeakPassword    error = NewStatusError(http.StatusBadRequest, ErrorWeakPasswordKey)
+	ErrWrongConfirm    error = NewStatusError(http.StatusBadRequest, ErrorWrongConfirmPasswordKey)
+	ErrWrongCsrf       error = NewStatusError(http.StatusForbidden, ErrorWrongCsrfTokenKey)
+	ErrWrongLogin      error = NewStatusError(http.StatusForbidden, ErrorWrongLoginKey)
 )
 
+// Error with the HTTP status and the message key to display (the key is not filtered).
+type StatusError struct {
+	status int
+	key    string
+}
+
+func NewStatusError(status int, key string) *StatusError {
+	return &StatusError{status: status, key: key}
+}
+
+func (e *StatusError) Error() string {
+	return e.key
+}
+
+func (e *StatusError) Status() int {
+	return e.status
+}
+
+// Retrieve the HTTP status and the message key to display for err (gRPC codes are converted).
+func ExtractStatusAndKey(logger log.Logger, err error) (int, string) {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.status, statusErr.key
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusServiceUnavailable, ErrorUnavailableKey
+	}
+
+	switch status.Code(err) {
+	case codes.NotFound:
+		return http.StatusNotFound, ErrorNotFoundKey
+	case codes.PermissionDenied, codes.Unauthenticated:
+		return http.StatusForbidden, ErrorNotAuthorizedKey
+	case codes.Unavailable, codes.DeadlineExceeded:
+		LogOriginalError(logger, err)
+		return http.StatusServiceUnavailable, ErrorUnavailableKey
+	}
+	return http.StatusInternalServerError, FilterErrorMsg(logger, err.Error())
+}
+
+// To use in a TemplateRedirecter, the error page is displayed instead of a template,
+// with the status and the message from err (redirecting stays possible for form posts).
+func ErrorPage(c *gin.Context, err error) (string, string) {
+	c.Set(ErrorPageName, err)
+	return "", ""
+}
+
 func LogOriginalError(logger log.Logger, err error) {
 	logger.Warn(originalErrorMsg, zap.Error(err))
 }
@@ -85,12 +150,16 @@ func DefaultErrorRedirect(logger log.Logger, errorMsg string) string {
 	return PathQueryError + FilterErrorMsg(logger, errorMsg)
 }
 
+// the keys which could be displayed, any other message is replaced by ErrorTechnicalKey
+var displayableErrorKeys = MakeSet([]string{
+	ErrorBadRoleNameKey, ErrorBaseVersionKey, ErrorEmptyCommentKey, ErrorEmptyLoginKey, ErrorEmptyPasswordKey,
+	ErrorExistingLoginKey, ErrorNotAuthorizedKey, ErrorNotFoundKey, ErrorTechnicalKey, ErrorTooManyRequestsKey,
+	ErrorUnavailableKey, ErrorUnknownSettingKey, ErrorUpdateKey, ErrorWeakPasswordKey, ErrorWrongConfirmPasswordKey,
+	ErrorWrongCsrfTokenKey, ErrorWrongLangKey, ErrorWrongLoginKey, ErrorWrongSettingKey, ErrorWrongTimeZoneKey,
+})
+
 func FilterErrorMsg(logger log.Logger, errorMsg string) string {
-	if errorMsg == ErrorBadRoleNameKey || errorMsg == ErrorBaseVersionKey || errorMsg == ErrorEmptyCommentKey ||
-		errorMsg == ErrorEmptyLoginKey || errorMsg == ErrorEmptyPasswordKey || errorMsg == ErrorExistingLoginKey ||
-		errorMsg == ErrorNotAuthorizedKey || errorMsg == ErrorTechnicalKey || errorMsg == ErrorUpdateKey ||
-		errorMsg == ErrorWeakPasswordKey || errorMsg == ErrorWrongConfirmPasswordKey || errorMsg == ErrorWrongLangKey ||
-		errorMsg == ErrorWrongLoginKey {
+	if displayableErrorKeys.Contains(errorMsg) {
 		return errorMsg
 	}
 	logger.Error(originalErrorMsg, zap.String(ErrorKey, errorMsg))
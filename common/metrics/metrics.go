@@ -0,0 +1,86 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package metrics
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+	"google.golang.org/grpc"
+)
+
+const (
+	meterName = "puzzleWeb"
+
+	millisecondUnit = "ms"
+)
+
+// Instruments use the global meter provider (setted in globalconfig.Init),
+// so they can be created before its initialisation.
+func Meter() metric.Meter {
+	return otel.Meter(meterName)
+}
+
+// Fallback to a no-op instrument on error (only an invalid name can cause it).
+func Int64Counter(name string, description string) metric.Int64Counter {
+	counter, err := Meter().Int64Counter(name, metric.WithDescription(description))
+	if err != nil {
+		otel.Handle(err)
+		return noop.Int64Counter{}
+	}
+	return counter
+}
+
+// Fallback to a no-op instrument on error (only an invalid name can cause it).
+func DurationHistogram(name string, description string) metric.Float64Histogram {
+	histogram, err := Meter().Float64Histogram(
+		name, metric.WithDescription(description), metric.WithUnit(millisecondUnit),
+	)
+	if err != nil {
+		otel.Handle(err)
+		return noop.Float64Histogram{}
+	}
+	return histogram
+}
+
+func SinceInMs(start time.Time) float64 {
+	return float64(time.Since(start)) / float64(time.Millisecond)
+}
+
+// Record the latency of each unary call, with the called service and method as attributes.
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	latency := DurationHistogram("puzzleweb.grpc.client.duration", "Latency of gRPC calls by service")
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+
+		// method has the "/package.Service/Method" format
+		service, methodName, _ := strings.Cut(strings.TrimPrefix(method, "/"), "/")
+		latency.Record(ctx, SinceInMs(start), metric.WithAttributes(
+			attribute.String("rpc.service", service), attribute.String("rpc.method", methodName),
+			attribute.Bool("error", err != nil),
+		))
+		return err
+	}
+}
@@ -0,0 +1,79 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package common
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/dvaumoron/puzzleweb/common/log"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const redirectValidatorName = "RedirectValidator"
+
+type RedirectValidator struct {
+	loggerGetter log.LoggerGetter
+	allowedHosts Set[string]
+}
+
+func NewRedirectValidator(loggerGetter log.LoggerGetter, allowedHosts []string) RedirectValidator {
+	return RedirectValidator{loggerGetter: loggerGetter, allowedHosts: MakeSet(allowedHosts)}
+}
+
+// middleware making the validator available to CheckRedirect
+func (v RedirectValidator) Manage(c *gin.Context) {
+	c.Set(redirectValidatorName, v)
+}
+
+// Return target when it is a same-origin relative path or an url with an allowed host, "/" otherwise.
+func CheckRedirect(c *gin.Context, target string) string {
+	if target == "" {
+		return "/"
+	}
+
+	untyped, _ := c.Get(redirectValidatorName)
+	v, _ := untyped.(RedirectValidator) // without validator only relative paths are allowed
+	if v.allowed(target) {
+		return target
+	}
+
+	if v.loggerGetter != nil {
+		v.loggerGetter.Logger(c.Request.Context()).Warn("Unsafe redirect replaced", zap.String("target", target))
+	}
+	return "/"
+}
+
+func (v RedirectValidator) allowed(target string) bool {
+	// browsers read backslashes as slashes and ignore some control characters ("/\evil.com" or "/\t/evil.com")
+	if strings.ContainsAny(target, "\\\t\r\n") {
+		return false
+	}
+
+	parsed, err := url.Parse(target)
+	if err != nil {
+		return false
+	}
+	if parsed.Scheme == "" && parsed.Host == "" {
+		// "//evil.com" is a scheme-relative url
+		return !strings.HasPrefix(target, "//")
+	}
+	return (parsed.Scheme == "http" || parsed.Scheme == "https") && v.allowedHosts.Contains(parsed.Hostname())
+}
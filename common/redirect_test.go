@@ -0,0 +1,67 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package common
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCheckRedirect(t *testing.T) {
+	validator := NewRedirectValidator(nil, []string{"allowed.host"})
+	cases := []struct {
+		target   string
+		expected string
+	}{
+		{target: "", expected: "/"},
+		{target: "/docs/install?lang=fr", expected: "/docs/install?lang=fr"},
+		{target: "//evil.com", expected: "/"},
+		{target: "//evil.com/path", expected: "/"},
+		{target: "/\\evil.com", expected: "/"},
+		{target: "/\t/evil.com", expected: "/"},
+		{target: "/\n/evil.com", expected: "/"},
+		{target: "/\x00/evil.com", expected: "/"},
+		{target: "javascript:alert(1)", expected: "/"},
+		{target: "JavaScript:alert(1)", expected: "/"},
+		{target: "http://allowed.host/x", expected: "http://allowed.host/x"},
+		{target: "https://allowed.host:8443/x", expected: "https://allowed.host:8443/x"},
+		{target: "ftp://allowed.host/x", expected: "/"},
+		{target: "https://evil.com/x", expected: "/"},
+		{target: "https://allowed.host.evil.com/x", expected: "/"},
+	}
+
+	for _, testCase := range cases {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+		validator.Manage(c)
+		if actual := CheckRedirect(c, testCase.target); actual != testCase.expected {
+			t.Errorf("CheckRedirect(%q) is %q, expected %q", testCase.target, actual, testCase.expected)
+		}
+	}
+
+	// without validator, only relative paths are allowed
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	if actual := CheckRedirect(c, "http://allowed.host/x"); actual != "/" {
+		t.Errorf("absolute url allowed without validator : %q", actual)
+	}
+}
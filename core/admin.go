@@ -77,6 +77,7 @@ type adminWidget struct {
 	viewUserHandler   gin.HandlerFunc
 	editUserHandler   gin.HandlerFunc
 	saveUserHandler   gin.HandlerFunc
+	confirmDeleteUser gin.HandlerFunc
 	deleteUserHandler gin.HandlerFunc
 	listRoleHandler   gin.HandlerFunc
 	editRoleHandler   gin.HandlerFunc
@@ -89,7 +90,7 @@ func (w adminWidget) LoadInto(router gin.IRouter) {
 	router.GET("/user/view/:UserId", w.viewUserHandler)
 	router.GET("/user/edit/:UserId", w.editUserHandler)
 	router.POST("/user/save/:UserId", w.saveUserHandler)
-	router.GET("/user/delete/:UserId", w.deleteUserHandler)
+	AddDeleteRoutes(router, "/user/delete/:UserId", w.confirmDeleteUser, w.deleteUserHandler)
 	router.GET("/role/list", w.listRoleHandler)
 	router.GET("/role/edit/:RoleName/:Group", w.editRoleHandler)
 	router.POST("/role/save", w.saveRoleHandler)
@@ -102,26 +103,26 @@ func newAdminPage(adminConfig config.AdminConfig) Page {
 	defaultPageSize := adminConfig.PageSize
 
 	p := MakeHiddenPage("admin")
+	p.GroupId = adminservice.AdminGroupId
 	p.Widget = adminWidget{
 		displayHandler: CreateTemplate(func(data gin.H, c *gin.Context) (string, string) {
 			viewAdmin, _ := data[viewAdminName].(bool)
 			if !viewAdmin {
-				return "", common.DefaultErrorRedirect(GetLogger(c), common.ErrorNotAuthorizedKey)
+				return common.ErrorPage(c, common.ErrNotAuthorized)
 			}
 			return "admin/index", ""
 		}),
 		listUserHandler: CreateTemplate(func(data gin.H, c *gin.Context) (string, string) {
-			logger := GetLogger(c)
 			viewAdmin, _ := data[viewAdminName].(bool)
 			if !viewAdmin {
-				return "", common.DefaultErrorRedirect(logger, common.ErrorNotAuthorizedKey)
+				return common.ErrorPage(c, common.ErrNotAuthorized)
 			}
 
 			pageNumber, start, end, filter := common.GetPagination(defaultPageSize, c)
 
 			total, users, err := userService.ListUsers(c.Request.Context(), start, end, filter)
 			if err != nil {
-				return "", common.DefaultErrorRedirect(logger, err.Error())
+				return common.ErrorPage(c, err)
 			}
 
 			common.InitPagination(data, filter, pageNumber, end, total)
@@ -130,22 +131,21 @@ func newAdminPage(adminConfig config.AdminConfig) Page {
 			return "admin/user/list", ""
 		}),
 		viewUserHandler: CreateTemplate(func(data gin.H, c *gin.Context) (string, string) {
-			logger := GetLogger(c)
 			adminId, _ := data[common.UserIdName].(uint64)
 			userId := GetRequestedUserId(c)
 			if userId == 0 {
-				return "", common.DefaultErrorRedirect(logger, common.ErrorTechnicalKey)
+				return common.ErrorPage(c, common.ErrNotFound)
 			}
 
 			ctx := c.Request.Context()
 			updateRight, groups, err := adminService.ViewUserRoles(ctx, adminId, userId)
 			if err != nil {
-				return "", common.DefaultErrorRedirect(logger, err.Error())
+				return common.ErrorPage(c, err)
 			}
 
 			users, err := userService.GetUsers(ctx, []uint64{userId})
 			if err != nil {
-				return "", common.DefaultErrorRedirect(logger, err.Error())
+				return common.ErrorPage(c, err)
 			}
 
 			user := users[userId]
@@ -155,22 +155,21 @@ func newAdminPage(adminConfig config.AdminConfig) Page {
 			return "admin/user/view", ""
 		}),
 		editUserHandler: CreateTemplate(func(data gin.H, c *gin.Context) (string, string) {
-			logger := GetLogger(c)
 			adminId, _ := data[common.UserIdName].(uint64)
 			userId := GetRequestedUserId(c)
 			if userId == 0 {
-				return "", common.DefaultErrorRedirect(logger, common.ErrorTechnicalKey)
+				return common.ErrorPage(c, common.ErrNotFound)
 			}
 
 			ctx := c.Request.Context()
 			userRoles, allRoles, err := adminService.EditUserRoles(ctx, adminId, userId)
 			if err != nil {
-				return "", common.DefaultErrorRedirect(logger, err.Error())
+				return common.ErrorPage(c, err)
 			}
 
 			userIdToLogin, err := userService.GetUsers(ctx, []uint64{userId})
 			if err != nil {
-				return "", common.DefaultErrorRedirect(logger, err.Error())
+				return common.ErrorPage(c, err)
 			}
 
 			data[common.ViewedUserName] = userIdToLogin[userId]
@@ -195,15 +194,24 @@ func newAdminPage(adminConfig config.AdminConfig) Page {
 						nameToGroup[groupName] = group
 					}
 				}
-				err = adminService.UpdateUser(c.Request.Context(), GetSessionUserId(c), userId, common.MapToValueSlice(nameToGroup))
+				adminId := GetSessionUserId(c)
+				err = adminService.UpdateUser(c.Request.Context(), adminId, userId, common.MapToValueSlice(nameToGroup))
+				if err == nil && userId == adminId {
+					// only the session of the acting user is renewed (there is no index of the sessions by user),
+					// the rights are not kept in sessions, so the other users get the change on their next request
+					renewSession(c)
+				}
 			}
 
-			targetBuilder := userListUrlBuilder()
+			targetBuilder := userListUrlBuilder(c)
 			if err != nil {
 				common.WriteError(targetBuilder, GetLogger(c), err.Error())
 			}
 			return targetBuilder.String()
 		}),
+		confirmDeleteUser: CreateConfirmTemplate("ConfirmDeleteUser", func(c *gin.Context) string {
+			return userListUrlBuilder(c).String()
+		}),
 		deleteUserHandler: common.CreateRedirect(func(c *gin.Context) string {
 			userId := GetRequestedUserId(c)
 			err := common.ErrTechnical
@@ -220,7 +228,7 @@ func newAdminPage(adminConfig config.AdminConfig) Page {
 				}
 			}
 
-			targetBuilder := userListUrlBuilder()
+			targetBuilder := userListUrlBuilder(c)
 			if err != nil {
 				common.WriteError(targetBuilder, GetLogger(c), err.Error())
 			}
@@ -230,7 +238,7 @@ func newAdminPage(adminConfig config.AdminConfig) Page {
 			adminId, _ := data[common.UserIdName].(uint64)
 			allGroups, err := adminService.GetAllGroups(c.Request.Context(), adminId)
 			if err != nil {
-				return "", common.DefaultErrorRedirect(GetLogger(c), err.Error())
+				return common.ErrorPage(c, err)
 			}
 			data[groupsName] = displayGroups(allGroups)
 			return "admin/role/list", ""
@@ -247,7 +255,7 @@ func newAdminPage(adminConfig config.AdminConfig) Page {
 				adminId, _ := data[common.UserIdName].(uint64)
 				actions, err := adminService.GetActions(c.Request.Context(), adminId, roleName, group)
 				if err != nil {
-					return "", common.DefaultErrorRedirect(GetLogger(c), err.Error())
+					return common.ErrorPage(c, err)
 				}
 
 				actionSet := common.MakeSet(actions)
@@ -269,6 +277,7 @@ func newAdminPage(adminConfig config.AdminConfig) Page {
 			}
 
 			var targetBuilder strings.Builder
+			targetBuilder.WriteString(getLangPrefix(c))
 			targetBuilder.WriteString("/admin/role/list")
 			if err != nil {
 				common.WriteError(&targetBuilder, GetLogger(c), err.Error())
@@ -360,8 +369,9 @@ func setActionChecked(data gin.H, actionSet common.Set[string], toTest string, n
 	}
 }
 
-func userListUrlBuilder() *strings.Builder {
+func userListUrlBuilder(c *gin.Context) *strings.Builder {
 	targetBuilder := new(strings.Builder)
+	targetBuilder.WriteString(getLangPrefix(c))
 	targetBuilder.WriteString("/admin/user/list")
 	return targetBuilder
 }
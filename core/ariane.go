@@ -29,28 +29,44 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-const errorMsgName = "ErrorMsg"
+const (
+	errorMsgName      = "ErrorMsg"
+	accessResultsName = "accessResults"
+)
 
 type PageDesc struct {
-	Name string
-	Url  string
+	MenuInfo
+	Name     string
+	Url      string
+	SubPages []PageDesc // only filled in the menu tree
 }
 
 func makePageDesc(name string, url string) PageDesc {
 	return PageDesc{Name: getPageTitleKey(name), Url: url}
 }
 
+func makeMenuPageDesc(page Page, url string) PageDesc {
+	if link := page.Menu.Link; link != "" {
+		url = link
+	}
+	return PageDesc{MenuInfo: page.Menu, Name: getPageTitleKey(page.name), Url: url}
+}
+
 func getPageTitleKey(name string) string {
 	return "PageTitle" + locale.CamelCase(name)
 }
 
-func buildAriane(splittedPath []string) []PageDesc {
-	pageDescs := make([]PageDesc, 0, len(splittedPath))
+// inaccessible pages are skipped (their url is still used for the following ones)
+func (site *Site) buildAriane(path []Page, langPrefix string, userId uint64, c *gin.Context) []PageDesc {
+	pageDescs := make([]PageDesc, 0, len(path))
 	var urlBuilder strings.Builder
-	for _, name := range splittedPath {
+	urlBuilder.WriteString(langPrefix)
+	for _, page := range path {
 		urlBuilder.WriteByte('/')
-		urlBuilder.WriteString(name)
-		pageDescs = append(pageDescs, makePageDesc(name, urlBuilder.String()))
+		urlBuilder.WriteString(page.name)
+		if site.checkAccess(c, userId, page.GroupId) == nil {
+			pageDescs = append(pageDescs, makePageDesc(page.name, urlBuilder.String()))
+		}
 	}
 	return pageDescs
 }
@@ -74,22 +90,41 @@ func InitNoELementMsg(data gin.H, size int, c *gin.Context) {
 	}
 }
 
-func (site *Site) extractArianeInfoFromUrl(url string) (Page, []string) {
+// The result is memoized during the request, the navigation of a page often has several entries with the same group.
+func (site *Site) checkAccess(c *gin.Context, userId uint64, groupId uint64) error {
+	var results map[[2]uint64]error
+	if resultsUntyped, ok := c.Get(accessResultsName); ok {
+		results = resultsUntyped.(map[[2]uint64]error)
+	} else {
+		results = map[[2]uint64]error{}
+		c.Set(accessResultsName, results)
+	}
+
+	key := [2]uint64{userId, groupId}
+	err, ok := results[key]
+	if !ok {
+		err = site.authService.AuthQuery(c.Request.Context(), userId, groupId, adminservice.ActionAccess)
+		results[key] = err
+	}
+	return err
+}
+
+func (site *Site) extractArianeInfoFromUrl(url string) (Page, []Page) {
 	current := site.root
 	splitted := strings.Split(url, "/")[1:]
-	names := make([]string, 0, len(splitted))
+	path := make([]Page, 0, len(splitted))
 	for _, name := range splitted {
 		subPage, ok := current.GetSubPage(name)
 		if !ok {
 			break
 		}
 		current = subPage
-		names = append(names, name)
+		path = append(path, subPage)
 	}
-	return current, names
+	return current, path
 }
 
-func (p Page) extractSubPageNames(url string, c *gin.Context) []PageDesc {
+func (site *Site) extractSubPageNames(p Page, url string, userId uint64, c *gin.Context) []PageDesc {
 	sw, ok := p.Widget.(*staticWidget)
 	if !ok {
 		return nil
@@ -103,9 +138,27 @@ func (p Page) extractSubPageNames(url string, c *gin.Context) []PageDesc {
 
 	pageDescs := make([]PageDesc, 0, size)
 	for _, page := range pages {
-		if page.visible {
-			name := page.name
-			pageDescs = append(pageDescs, makePageDesc(name, url+name))
+		if page.visible && site.checkAccess(c, userId, page.GroupId) == nil {
+			pageDescs = append(pageDescs, makeMenuPageDesc(page, url+page.name))
+		}
+	}
+	return pageDescs
+}
+
+// visible and accessible pages at every level (for multi-level menus), url is the one of p ending with "/"
+func (site *Site) buildMenuTree(p Page, url string, userId uint64, c *gin.Context) []PageDesc {
+	sw, ok := p.Widget.(*staticWidget)
+	if !ok {
+		return nil
+	}
+
+	var pageDescs []PageDesc
+	for _, page := range sw.subPages {
+		if page.visible && site.checkAccess(c, userId, page.GroupId) == nil {
+			pageUrl := url + page.name
+			pageDesc := makeMenuPageDesc(page, pageUrl)
+			pageDesc.SubPages = site.buildMenuTree(page, pageUrl+"/", userId, c)
+			pageDescs = append(pageDescs, pageDesc)
 		}
 	}
 	return pageDescs
@@ -115,33 +168,43 @@ func initData(c *gin.Context) gin.H {
 	site := getSite(c)
 	localesManager := site.localesManager
 	currentUrl := common.GetCurrentUrl(c)
-	page, path := site.extractArianeInfoFromUrl(currentUrl)
+	escapedUrl := url.QueryEscape(c.Request.URL.Path)
+	langPrefix := getLangPrefix(c)
+	pagePath := strings.TrimPrefix(currentUrl, langPrefix) // path in the page tree
+	lang := localesManager.GetLang(c)
 	data := gin.H{
-		locale.LangName: localesManager.GetLang(c),
-		"PageTitle":     getPageTitleKey(page.name),
-		"CurrentUrl":    currentUrl,
-		"Ariane":        buildAriane(path),
-		"SubPages":      page.extractSubPageNames(currentUrl, c),
-		errorMsgName:    c.Query("error"),
+		locale.LangName:      lang,
+		locale.FallbacksName: localesManager.GetFallbacks(lang),
+		"CurrentUrl":         currentUrl,
+		errorMsgName:         c.Query("error"),
+		CsrfTokenName:        GetCsrfToken(c),
 	}
-	escapedUrl := url.QueryEscape(c.Request.URL.Path)
 	if localesManager.GetMultipleLang() {
 		data["LangSelectorUrl"] = "/changeLang?Redirect=" + escapedUrl
 		data["AllLang"] = localesManager.GetAllLang()
+		if langPrefix != "" {
+			data["LangAlternates"] = site.buildLangAlternates(strings.TrimPrefix(c.Request.URL.Path, langPrefix))
+		}
 	}
 	session := GetSession(c)
+	data[locale.DatesName] = locale.NewDateFormatter(localesManager.GetDateFormat(lang), session.Load(locale.TimeZoneName))
 	var currentUserId uint64
 	if login := session.Load(loginName); login == "" {
-		data[loginUrlName] = "/login?Redirect=" + escapedUrl
+		data[loginUrlName] = langPrefix + "/login?Redirect=" + escapedUrl
 	} else {
 		currentUserId = GetSessionUserId(c)
 		data[loginName] = login
 		data[common.UserIdName] = currentUserId
-		data[loginUrlName] = "/login/logout?Redirect=" + escapedUrl
+		data[loginUrlName] = langPrefix + "/login/logout?Redirect=" + escapedUrl
 	}
-	data[viewAdminName] = site.authService.AuthQuery(
-		c.Request.Context(), currentUserId, adminservice.AdminGroupId, adminservice.ActionAccess,
-	) == nil
+
+	// navigation only shows the pages the user can access
+	page, path := site.extractArianeInfoFromUrl(pagePath)
+	data["PageTitle"] = getPageTitleKey(page.name)
+	data["Ariane"] = site.buildAriane(path, langPrefix, currentUserId, c)
+	data["SubPages"] = site.extractSubPageNames(page, currentUrl, currentUserId, c)
+	data["Menu"] = site.buildMenuTree(site.root, langPrefix+"/", currentUserId, c)
+	data[viewAdminName] = site.checkAccess(c, currentUserId, adminservice.AdminGroupId) == nil
 	for _, adder := range site.adders {
 		adder(data, c)
 	}
@@ -0,0 +1,205 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package puzzleweb
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	brotliEncoding = "br"
+	gzipEncoding   = "gzip"
+
+	contentEncodingHeader = "Content-Encoding"
+)
+
+type flushWriteCloser interface {
+	io.WriteCloser
+	Flush() error
+	Reset(io.Writer)
+}
+
+type compressor struct {
+	minSize int
+	pools   map[string]*sync.Pool
+}
+
+func newCompressor(minSize int) compressor {
+	return compressor{minSize: minSize, pools: map[string]*sync.Pool{
+		brotliEncoding: {New: func() any {
+			return brotli.NewWriterLevel(io.Discard, brotli.DefaultCompression)
+		}},
+		gzipEncoding: {New: func() any {
+			writer, _ := gzip.NewWriterLevel(io.Discard, gzip.DefaultCompression)
+			return writer
+		}},
+	}}
+}
+
+func (cp compressor) compress(c *gin.Context) {
+	request := c.Request
+	// partial content can not be compressed without breaking the range semantic
+	if request.Method == http.MethodHead || request.Header.Get("Range") != "" {
+		return
+	}
+
+	c.Writer.Header().Add("Vary", "Accept-Encoding")
+	encoding := chooseEncoding(request.Header.Get("Accept-Encoding"))
+	if encoding == "" {
+		return
+	}
+
+	writer := &compressWriter{ResponseWriter: c.Writer, encoding: encoding, minSize: cp.minSize, pool: cp.pools[encoding]}
+	c.Writer = writer
+	defer writer.close()
+
+	c.Next()
+}
+
+// brotli is preferred to gzip, the weights are only used to exclude an encoding
+func chooseEncoding(acceptEncoding string) string {
+	accepted := map[string]bool{}
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.TrimSpace(name)
+		accepted[name] = true
+		if _, weight, ok := strings.Cut(params, "q="); ok {
+			if q, err := strconv.ParseFloat(strings.TrimSpace(weight), 64); err == nil && q == 0 {
+				accepted[name] = false
+			}
+		}
+	}
+
+	for _, encoding := range [2]string{brotliEncoding, gzipEncoding} {
+		if allowed, present := accepted[encoding]; allowed || (!present && accepted["*"]) {
+			return encoding
+		}
+	}
+	return ""
+}
+
+// compressible types are textual, already compressed ones (images, archives, fonts) are skipped
+func compressibleType(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.TrimSpace(mediaType)
+	return strings.HasPrefix(mediaType, "text/") || strings.HasSuffix(mediaType, "json") ||
+		strings.HasSuffix(mediaType, "xml") || strings.HasSuffix(mediaType, "javascript")
+}
+
+// buffer the start of the body until minSize is reached to decide if it worth compressing
+type compressWriter struct {
+	gin.ResponseWriter
+	encoding string
+	minSize  int
+	pool     *sync.Pool
+	buffer   []byte
+	decided  bool
+	encoder  flushWriteCloser // nil when not compressing
+}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if w.decided {
+		if w.encoder != nil {
+			return w.encoder.Write(data)
+		}
+		return w.ResponseWriter.Write(data)
+	}
+
+	w.buffer = append(w.buffer, data...)
+	if len(w.buffer) >= w.minSize {
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// headers are sent, so the body can no longer be compressed
+func (w *compressWriter) WriteHeaderNow() {
+	if !w.decided {
+		w.decide(false)
+	}
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		w.decide(len(w.buffer) != 0)
+	}
+	if w.encoder != nil {
+		w.encoder.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *compressWriter) decide(sizeOk bool) error {
+	w.decided = true
+	header := w.Header()
+	if contentType := header.Get("Content-Type"); contentType == "" && len(w.buffer) != 0 {
+		// sniffing must be done on the uncompressed data
+		header.Set("Content-Type", http.DetectContentType(w.buffer))
+	}
+
+	status := w.Status()
+	if sizeOk && header.Get(contentEncodingHeader) == "" && status != http.StatusPartialContent &&
+		status != http.StatusNoContent && status != http.StatusNotModified && compressibleType(header.Get("Content-Type")) {
+		header.Set(contentEncodingHeader, w.encoding)
+		header.Del("Content-Length")
+
+		encoder := w.pool.Get().(flushWriteCloser)
+		encoder.Reset(w.ResponseWriter)
+		w.encoder = encoder
+	}
+
+	buffer := w.buffer
+	w.buffer = nil
+	if len(buffer) == 0 {
+		return nil
+	}
+	if w.encoder != nil {
+		_, err := w.encoder.Write(buffer)
+		return err
+	}
+	_, err := w.ResponseWriter.Write(buffer)
+	return err
+}
+
+func (w *compressWriter) close() {
+	if !w.decided {
+		// the whole body is smaller than minSize
+		w.decide(false)
+	}
+	if encoder := w.encoder; encoder != nil {
+		encoder.Close()
+		encoder.Reset(io.Discard)
+		w.pool.Put(encoder)
+	}
+}
@@ -0,0 +1,50 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package puzzleweb
+
+import (
+	"github.com/dvaumoron/puzzleweb/common"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	ConfirmTemplateName = "confirm"
+
+	confirmMessageName = "ConfirmMessage"
+	confirmUrlName     = "ConfirmUrl"
+	cancelUrlName      = "CancelUrl"
+)
+
+// Register a destructive action : GET only display a confirmation page,
+// the action itself is done with POST (from the confirmation form) or DELETE (from htmx).
+func AddDeleteRoutes(router gin.IRouter, relativePath string, confirmHandler gin.HandlerFunc, deleteHandler gin.HandlerFunc) {
+	router.GET(relativePath, confirmHandler)
+	router.POST(relativePath, deleteHandler)
+	router.DELETE(relativePath, deleteHandler)
+}
+
+// The confirmation form must post to the same url (query included).
+func CreateConfirmTemplate(messageKey string, cancelRedirecter common.Redirecter) gin.HandlerFunc {
+	return CreateTemplate(func(data gin.H, c *gin.Context) (string, string) {
+		data[confirmMessageName] = messageKey
+		data[confirmUrlName] = c.Request.URL.RequestURI()
+		data[cancelUrlName] = cancelRedirecter(c)
+		return ConfirmTemplateName, ""
+	})
+}
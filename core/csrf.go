@@ -0,0 +1,73 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package puzzleweb
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+
+	"github.com/dvaumoron/puzzleweb/common"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	CsrfTokenName   = "CsrfToken" // name in session, in template data and in posted form
+	csrfHeaderName  = "X-Csrf-Token"
+	csrfTokenLength = 32
+)
+
+// Retrieve the anti-forgery token tied to the session, generate it when missing.
+func GetCsrfToken(c *gin.Context) string {
+	session := GetSession(c)
+	token := session.Load(CsrfTokenName)
+	if token == "" {
+		randomBytes := make([]byte, csrfTokenLength)
+		if _, err := rand.Read(randomBytes); err != nil {
+			GetLogger(c).Error("Failed to generate CSRF token", zap.Error(err))
+			return ""
+		}
+		token = base64.RawURLEncoding.EncodeToString(randomBytes)
+		session.Store(CsrfTokenName, token)
+	}
+	return token
+}
+
+// Check the token on every state-changing request (the header allows htmx calls without form).
+func checkCsrf(c *gin.Context) {
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return
+	}
+
+	sent := c.GetHeader(csrfHeaderName)
+	if sent == "" {
+		sent = c.PostForm(CsrfTokenName)
+	}
+
+	expected := GetSession(c).Load(CsrfTokenName)
+	if expected == "" || subtle.ConstantTimeCompare([]byte(sent), []byte(expected)) != 1 {
+		logger := GetLogger(c)
+		logger.Warn("CSRF token mismatch", zap.String("path", c.Request.URL.Path))
+		c.Redirect(http.StatusFound, common.DefaultErrorRedirect(logger, common.ErrorWrongCsrfTokenKey))
+		c.Abort()
+	}
+}
@@ -0,0 +1,59 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package puzzleweb
+
+import (
+	"net/http"
+
+	adminservice "github.com/dvaumoron/puzzleweb/admin/service"
+	"github.com/dvaumoron/puzzleweb/common/config"
+	"github.com/dvaumoron/puzzleweb/health"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	statusOk   = "ok"
+	statusFail = "fail"
+)
+
+// registered before the session middleware, probes must not create sessions
+func (site *Site) loadHealthInto(router gin.IRouter, siteConfig config.SiteConfig, manager sessionManager) {
+	probes, timeOut, hideDetails := siteConfig.HealthProbes, siteConfig.ProbeTimeOut, siteConfig.HideHealthDetails
+
+	router.GET("/healthz", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": statusOk})
+	})
+	router.GET("/readyz", func(c *gin.Context) {
+		ctx := c.Request.Context()
+		statuses, ready := health.CheckAll(ctx, timeOut, probes)
+
+		code, globalStatus := http.StatusOK, statusOk
+		if !ready {
+			code, globalStatus = http.StatusServiceUnavailable, statusFail
+		}
+
+		res := gin.H{"status": globalStatus}
+		if !hideDetails || site.authService.AuthQuery(
+			ctx, manager.peekUserId(c), adminservice.AdminGroupId, adminservice.ActionAccess,
+		) == nil {
+			res["services"] = statuses
+		}
+		c.JSON(code, res)
+	})
+}
@@ -0,0 +1,109 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package puzzleweb
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/dvaumoron/puzzleweb/locale"
+	"github.com/gin-gonic/gin"
+)
+
+// Link to the same page in another lang (for the hreflang alternate links).
+type LangAlternate struct {
+	Lang string
+	Url  string
+}
+
+// "/{lang}" in lang prefix mode, empty otherwise.
+func getLangPrefix(c *gin.Context) string {
+	if lang := c.GetString(locale.UrlLangName); lang != "" {
+		return "/" + lang
+	}
+	return ""
+}
+
+// Replace the lang prefix of a path starting with one (like the base urls of SitemapWidget in lang prefix mode).
+func ReplaceLangPrefix(path string, lang string) string {
+	rest := path[1:]
+	if index := strings.IndexByte(rest, '/'); index != -1 {
+		return "/" + lang + rest[index:]
+	}
+	return "/" + lang
+}
+
+func (site *Site) isDeclaredLang(lang string) bool {
+	for _, declared := range site.localesManager.GetAllLang() {
+		if lang == declared {
+			return true
+		}
+	}
+	return false
+}
+
+// replace the lang prefix of target (which can have a query), or add one when missing
+func (site *Site) changeLangPrefix(target string, lang string) string {
+	rest := strings.TrimPrefix(target, "/")
+	end := strings.IndexAny(rest, "/?")
+	if end == -1 {
+		end = len(rest)
+	}
+	if site.isDeclaredLang(rest[:end]) {
+		return "/" + lang + rest[end:]
+	}
+	return "/" + lang + "/" + rest
+}
+
+func setUrlLang(lang string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(locale.UrlLangName, lang)
+		// unprefixed links are redirected with the cookie
+		GetLocalesManager(c).SetLangCookie(lang, c)
+	}
+}
+
+// evaluated before the not found handler, the lang is the one of the cookie (or Accept-Language)
+func (site *Site) redirectToLang(c *gin.Context) {
+	path := c.Request.URL.Path
+	if first, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/"); site.isDeclaredLang(first) {
+		return
+	}
+
+	target := "/" + site.localesManager.GetLang(c) + path
+	if rawQuery := c.Request.URL.RawQuery; rawQuery != "" {
+		target += "?" + rawQuery
+	}
+	status := http.StatusFound
+	if method := c.Request.Method; method != http.MethodGet && method != http.MethodHead {
+		// keep the method and the body
+		status = http.StatusTemporaryRedirect
+	}
+	c.Redirect(status, target)
+	c.Abort()
+}
+
+func (site *Site) buildLangAlternates(pagePath string) []LangAlternate {
+	allLang := site.localesManager.GetAllLang()
+	alternates := make([]LangAlternate, 0, len(allLang))
+	for _, lang := range allLang {
+		alternates = append(alternates, LangAlternate{Lang: lang, Url: site.siteUrl + "/" + lang + pagePath})
+	}
+	return alternates
+}
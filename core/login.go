@@ -26,6 +26,7 @@ import (
 	"github.com/dvaumoron/puzzleweb/common/config"
 	"github.com/dvaumoron/puzzleweb/locale"
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 )
 
 const (
@@ -45,7 +46,7 @@ type loginWidget struct {
 
 func (w loginWidget) LoadInto(router gin.IRouter) {
 	router.GET("/", w.displayHandler)
-	router.POST("/submit", w.submitHandler)
+	router.POST("/submit", rateLimitLogin, w.submitHandler)
 	router.GET("/logout", w.logoutHandler)
 }
 
@@ -55,7 +56,7 @@ func newLoginPage(loginConfig config.LoginConfig, settingsManager *SettingsManag
 	p := MakeHiddenPage("login")
 	p.Widget = loginWidget{
 		displayHandler: CreateTemplate(func(data gin.H, c *gin.Context) (string, string) {
-			data[common.RedirectName] = c.Query(common.RedirectName)
+			data[common.RedirectName] = common.CheckRedirect(c, c.Query(common.RedirectName))
 
 			currentUrl := c.Request.URL
 			errorKey := common.AddQueryError
@@ -99,10 +100,17 @@ func newLoginPage(loginConfig config.LoginConfig, settingsManager *SettingsManag
 			}
 
 			s := GetSession(c)
+			// the id used before the login could have been planted
+			if err = s.Renew(c); err != nil {
+				GetLogger(c).Error("Failed to renew session", zap.Error(err))
+				return c.PostForm(prevUrlWithErrorName) + common.ErrorTechnicalKey
+			}
 			s.Store(loginName, login)
 			s.Store(userIdName, strconv.FormatUint(userId, 10))
 
-			GetLocalesManager(c).SetLangCookie(settingsManager.Get(ctx, userId, c)[locale.LangName], c)
+			settings := settingsManager.Get(ctx, userId, c)
+			GetLocalesManager(c).SetLangCookie(settings[locale.LangName], c)
+			storeTimeZone(c, settings)
 
 			return c.PostForm(common.RedirectName)
 		}),
@@ -110,6 +118,8 @@ func newLoginPage(loginConfig config.LoginConfig, settingsManager *SettingsManag
 			s := GetSession(c)
 			s.Delete(loginName)
 			s.Delete(userIdName)
+			s.Delete(locale.TimeZoneName)
+			renewSession(c)
 			return c.Query(common.RedirectName)
 		}),
 	}
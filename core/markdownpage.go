@@ -0,0 +1,274 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package puzzleweb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dvaumoron/puzzleweb/common"
+	"github.com/dvaumoron/puzzleweb/common/config"
+	"github.com/dvaumoron/puzzleweb/locale"
+	markdownservice "github.com/dvaumoron/puzzleweb/markdown/service"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	markdownExt          = ".md"
+	markdownIndex        = "index"
+	frontMatterDelimiter = "---"
+
+	markdownTitleName       = "MarkdownTitle"
+	markdownDescriptionName = "MarkdownDescription"
+	markdownContentName     = "MarkdownContent"
+)
+
+var errFrontMatterEnd = errors.New("front matter without end delimiter")
+
+type frontMatter struct {
+	Title       string  `yaml:"title"`
+	Description string  `yaml:"description"`
+	Group       *uint64 `yaml:"group"` // the group of the configuration when nil
+	Hidden      bool    `yaml:"hidden"`
+	Order       int     `yaml:"order"` // menu weight, pages with the same order are sorted by name
+	Icon        string  `yaml:"icon"`
+}
+
+type markdownContent struct {
+	modTime     time.Time
+	frontMatter frontMatter
+	html        string
+}
+
+// Markdown file with its rendering, refreshed when the modification time change.
+type markdownSource struct {
+	path    string
+	mutex   sync.RWMutex
+	content *markdownContent
+}
+
+func (s *markdownSource) load(ctx context.Context, markdownService markdownservice.MarkdownService) (*markdownContent, error) {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mutex.RLock()
+	content := s.content
+	s.mutex.RUnlock()
+	if content != nil && content.modTime.Equal(info.ModTime()) {
+		return content, nil
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, err
+	}
+	matter, markdown, err := splitFrontMatter(data)
+	if err != nil {
+		return nil, err
+	}
+	html, err := markdownService.Apply(ctx, markdown)
+	if err != nil {
+		return nil, err
+	}
+
+	content = &markdownContent{modTime: info.ModTime(), frontMatter: matter, html: html}
+	s.mutex.Lock()
+	s.content = content
+	s.mutex.Unlock()
+	return content, nil
+}
+
+// The optional front matter is a YAML document between two "---" lines at the start of the file.
+func splitFrontMatter(data []byte) (frontMatter, string, error) {
+	var matter frontMatter
+	text := string(bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n")))
+	rest, found := strings.CutPrefix(text, frontMatterDelimiter+"\n")
+	if !found {
+		return matter, text, nil
+	}
+
+	yamlPart, markdown, found := strings.Cut(rest, "\n"+frontMatterDelimiter+"\n")
+	if !found {
+		if yamlPart, found = strings.CutSuffix(rest, "\n"+frontMatterDelimiter); !found {
+			return matter, "", errFrontMatterEnd
+		}
+	}
+	return matter, markdown, yaml.Unmarshal([]byte(yamlPart), &matter)
+}
+
+// the default variant is under the empty lang
+type markdownPageDesc struct {
+	path     string // like "docs/" (page with sub pages) or "docs/install"
+	variants map[string]*markdownSource
+	matter   frontMatter
+}
+
+func (desc markdownPageDesc) depth() int {
+	return strings.Count(strings.TrimSuffix(desc.path, "/"), "/")
+}
+
+func (site *Site) AddMarkdownPages(pagesConfig config.MarkdownPagesConfig) bool {
+	logger := pagesConfig.Logger
+	descs, err := readMarkdownPages(pagesConfig.Path, site.localesManager.GetAllLang())
+	if err != nil {
+		logger.Error("Failed to read markdown pages", zap.String("filepath", pagesConfig.Path), zap.Error(err))
+		return false
+	}
+
+	ctx := context.Background()
+	for _, desc := range descs {
+		defaultSource := desc.variants[""]
+		if defaultSource == nil {
+			logger.Error("Markdown page without default variant", zap.String("page", desc.path))
+			return false
+		}
+		content, err := defaultSource.load(ctx, pagesConfig.Service)
+		if err != nil {
+			logger.Error("Failed to load markdown page", zap.String("filepath", defaultSource.path), zap.Error(err))
+			return false
+		}
+		desc.matter = content.frontMatter
+	}
+
+	// parents before children (the menu order is applied when adding sub pages)
+	sort.Slice(descs, func(i, j int) bool {
+		if depthI, depthJ := descs[i].depth(), descs[j].depth(); depthI != depthJ {
+			return depthI < depthJ
+		}
+		return descs[i].path < descs[j].path
+	})
+
+	for _, desc := range descs {
+		groupId := pagesConfig.GroupId
+		if desc.matter.Group != nil {
+			groupId = *desc.matter.Group
+		}
+		widget := newMarkdownWidget(groupId, pagesConfig.Layout, desc.variants, pagesConfig.Service)
+
+		if desc.path == "" {
+			// replace the content of the home page
+			site.root.Widget.(*staticWidget).displayHandler = widget.displayHandler
+			continue
+		}
+
+		parentPage, pageName, _, ok := site.root.extractSubPageAndNamesFromPath(desc.path)
+		if !ok {
+			logger.Error("Failed to retrieve parent of markdown page", zap.String("page", desc.path))
+			return false
+		}
+
+		newPage := MakePage(pageName)
+		if desc.matter.Hidden {
+			newPage = MakeHiddenPage(pageName)
+		}
+		newPage.GroupId = groupId
+		newPage.Widget = widget
+		newPage.Menu.Weight = desc.matter.Order
+		newPage.Menu.Icon = desc.matter.Icon
+		if !parentPage.AddSubPage(newPage) {
+			logger.Error("Only static page can have sub page", zap.String("page", desc.path))
+			return false
+		}
+	}
+	return true
+}
+
+// file names like "about.fr.md" are variants when the lang is declared
+func readMarkdownPages(rootPath string, allLang []string) ([]*markdownPageDesc, error) {
+	langs := common.MakeSet(allLang)
+	descsByPath := map[string]*markdownPageDesc{}
+	var descs []*markdownPageDesc
+	err := filepath.WalkDir(rootPath, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || !strings.HasSuffix(path, markdownExt) {
+			return err
+		}
+
+		relPath, err := filepath.Rel(rootPath, path)
+		if err != nil {
+			return err
+		}
+
+		pagePath := strings.TrimSuffix(filepath.ToSlash(relPath), markdownExt)
+		lang := ""
+		if index := strings.LastIndexByte(pagePath, '.'); index != -1 && langs.Contains(pagePath[index+1:]) {
+			lang = pagePath[index+1:]
+			pagePath = pagePath[:index]
+		}
+		// "docs/index" is the page "docs/" and "index" the home page ("")
+		if pagePath == markdownIndex {
+			pagePath = ""
+		} else if dirPath, found := strings.CutSuffix(pagePath, "/"+markdownIndex); found {
+			pagePath = dirPath + "/"
+		}
+
+		desc := descsByPath[pagePath]
+		if desc == nil {
+			desc = &markdownPageDesc{path: pagePath, variants: map[string]*markdownSource{}}
+			descsByPath[pagePath] = desc
+			descs = append(descs, desc)
+		}
+		desc.variants[lang] = &markdownSource{path: path}
+		return nil
+	})
+	return descs, err
+}
+
+// reuse the static widget to allow sub pages
+func newMarkdownWidget(groupId uint64, layout string, variants map[string]*markdownSource, markdownService markdownservice.MarkdownService) *staticWidget {
+	return &staticWidget{groupId: groupId, displayHandler: CreateTemplate(func(data gin.H, c *gin.Context) (string, string) {
+		site := getSite(c)
+		ctx := c.Request.Context()
+		userId, _ := data[common.UserIdName].(uint64)
+		if err := site.checkAccess(c, userId, groupId); err != nil {
+			return common.ErrorPage(c, err)
+		}
+
+		// the file without lang is the last fallback
+		source := variants[""]
+		lang, _ := data[locale.LangName].(string)
+		for _, candidate := range append([]string{lang}, site.localesManager.GetFallbacks(lang)...) {
+			if variant, ok := variants[candidate]; ok {
+				source = variant
+				break
+			}
+		}
+		content, err := source.load(ctx, markdownService)
+		if err != nil {
+			site.loggerGetter.Logger(ctx).Error("Failed to load markdown page", zap.String("filepath", source.path), zap.Error(err))
+			return common.ErrorPage(c, common.ErrTechnical)
+		}
+
+		data[markdownTitleName] = content.frontMatter.Title
+		data[markdownDescriptionName] = content.frontMatter.Description
+		data[markdownContentName] = content.html
+		return layout, ""
+	})}
+}
@@ -0,0 +1,68 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package puzzleweb
+
+import (
+	"net/http"
+	"time"
+
+	adminservice "github.com/dvaumoron/puzzleweb/admin/service"
+	"github.com/dvaumoron/puzzleweb/common/metrics"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const noRouteName = "noRoute"
+
+func newRequestRecorder() gin.HandlerFunc {
+	counter := metrics.Int64Counter("puzzleweb.request.count", "Number of requests by route")
+	latency := metrics.DurationHistogram("puzzleweb.request.duration", "Latency of requests by route")
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		// use the route pattern to keep a bounded cardinality
+		route := c.FullPath()
+		if route == "" {
+			route = noRouteName
+		}
+		attrs := metric.WithAttributes(
+			attribute.String("http.route", route), attribute.String("http.method", c.Request.Method),
+			attribute.Int("http.status_code", c.Writer.Status()),
+		)
+		ctx := c.Request.Context()
+		counter.Add(ctx, 1, attrs)
+		latency.Record(ctx, metrics.SinceInMs(start), attrs)
+	}
+}
+
+// the scrape endpoint is restricted to the admin group
+func (site *Site) createMetricsHandler(metricsHandler http.Handler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := site.authService.AuthQuery(
+			c.Request.Context(), GetSessionUserId(c), adminservice.AdminGroupId, adminservice.ActionAccess,
+		)
+		if err != nil {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		metricsHandler.ServeHTTP(c.Writer, c.Request)
+	}
+}
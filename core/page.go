@@ -20,10 +20,12 @@ package puzzleweb
 
 import (
 	"net/http"
+	"slices"
+	"sort"
 	"strings"
 
-	adminservice "github.com/dvaumoron/puzzleweb/admin/service"
 	"github.com/dvaumoron/puzzleweb/common"
+	"github.com/dvaumoron/puzzleweb/common/config"
 	"github.com/dvaumoron/puzzleweb/common/config/parser"
 	"github.com/dvaumoron/puzzleweb/locale"
 	"github.com/dvaumoron/puzzleweb/templates"
@@ -39,7 +41,26 @@ type Widget interface {
 type Page struct {
 	name    string
 	visible bool
+	GroupId uint64 // access group, the navigation hides the page to users outside of it
 	Widget  Widget
+	Menu    MenuInfo
+}
+
+// Optional navigation metadata, copied in the PageDesc of the template data.
+type MenuInfo struct {
+	Weight    int // sub pages are sorted by ascending weight (in insertion order when equal)
+	Icon      string
+	Link      string // external url used instead of the page one in menus
+	Group     string // label of the group of the entry
+	Separator bool   // the entry is preceded by a separator
+	NewTab    bool
+}
+
+func MakeMenuInfo(menuConfig parser.MenuConfig) MenuInfo {
+	return MenuInfo{
+		Weight: menuConfig.Weight, Icon: menuConfig.Icon, Link: menuConfig.Link,
+		Group: menuConfig.Group, Separator: menuConfig.Separator, NewTab: menuConfig.NewTab,
+	}
 }
 
 func MakePage(name string) Page {
@@ -51,52 +72,75 @@ func MakeHiddenPage(name string) Page {
 }
 
 type staticWidget struct {
+	groupId        uint64
 	displayHandler gin.HandlerFunc
 	subPages       []Page
 }
 
 func (w *staticWidget) addSubPage(page Page) {
-	w.subPages = append(w.subPages, page)
+	subPages := append(w.subPages, page)
+	sort.SliceStable(subPages, func(i, j int) bool {
+		return subPages[i].Menu.Weight < subPages[j].Menu.Weight
+	})
+	w.subPages = subPages
 }
 
 func (w *staticWidget) LoadInto(router gin.IRouter) {
-	router.GET("/", w.displayHandler)
+	router.GET("/", CachePage(config.StaticPageKind), w.displayHandler)
 	for _, page := range w.subPages {
 		page.Widget.LoadInto(router.Group("/" + page.name))
 	}
 }
 
 func newStaticWidget(groupId uint64, templateName string) *staticWidget {
-	return &staticWidget{displayHandler: CreateTemplate(func(data gin.H, c *gin.Context) (string, string) {
+	return &staticWidget{groupId: groupId, displayHandler: CreateTemplate(func(data gin.H, c *gin.Context) (string, string) {
 		site := getSite(c)
-		ctx := c.Request.Context()
-		logger := site.loggerGetter.Logger(ctx)
 		userId, _ := data[common.UserIdName].(uint64)
-		err := site.authService.AuthQuery(ctx, userId, groupId, adminservice.ActionAccess)
-		if err != nil {
-			return "", common.DefaultErrorRedirect(logger, err.Error())
+		if err := site.checkAccess(c, userId, groupId); err != nil {
+			return common.ErrorPage(c, err)
 		}
-		localesManager := GetLocalesManager(c)
-		if lang := localesManager.GetLang(c); lang != localesManager.GetDefaultLang() {
-			logger.Info("Using alternative static page", zap.String(locale.LangName, lang))
-			var builder strings.Builder
-			builder.WriteString(lang)
-			builder.WriteByte('/')
-			builder.WriteString(templateName)
-			return builder.String(), ""
-		}
-		return templateName, ""
+		lang, _ := data[locale.LangName].(string)
+		return site.chooseLocalizedTemplate(c, lang, templateName), ""
 	})}
 }
 
+// The first template of the fallback chain known by the template service ("fr/about"),
+// the one of the default lang is unprefixed ("about"). When the service can not tell
+// if a template exists, the next ones of the chain are rendered on failure.
+func (site *Site) chooseLocalizedTemplate(c *gin.Context, lang string, templateName string) string {
+	localesManager := site.localesManager
+	defaultLang := localesManager.GetDefaultLang()
+	var candidates []string
+	for _, candidate := range append([]string{lang}, localesManager.GetFallbacks(lang)...) {
+		if candidate == defaultLang {
+			break
+		}
+
+		localizedName := candidate + "/" + templateName
+		if site.templateChecker == nil {
+			candidates = append(candidates, localizedName)
+		} else if site.templateChecker.HasTemplate(localizedName) {
+			site.loggerGetter.Logger(c.Request.Context()).Info("Using alternative static page", zap.String(locale.LangName, candidate))
+			return localizedName
+		}
+	}
+	if len(candidates) == 0 {
+		return templateName
+	}
+	c.Set(templateFallbacksName, append(candidates[1:], templateName))
+	return candidates[0]
+}
+
 func MakeStaticPage(name string, groupId uint64, templateName string) Page {
 	p := MakePage(name)
+	p.GroupId = groupId
 	p.Widget = newStaticWidget(groupId, templateName)
 	return p
 }
 
 func MakeHiddenStaticPage(name string, groupId uint64, templateName string) Page {
 	p := MakeHiddenPage(name)
+	p.GroupId = groupId
 	p.Widget = newStaticWidget(groupId, templateName)
 	return p
 }
@@ -109,7 +153,17 @@ func (p Page) AddSubPage(page Page) bool {
 	return ok
 }
 
+// fail when a menu location is not in the locations of pageGroup
 func (p Page) AddStaticPages(pageGroup parser.StaticPagesConfig) bool {
+	if _, ok := findUnknownMenuLocation(pageGroup); ok {
+		return false
+	}
+
+	menus := make(map[string]parser.MenuConfig, len(pageGroup.Menus))
+	for _, menuConfig := range pageGroup.Menus {
+		menus[menuConfig.Location] = menuConfig
+	}
+
 	for _, pagePath := range pageGroup.Locations {
 		subPage, pageName, templateName, ok := p.extractSubPageAndNamesFromPath(pagePath)
 		if !ok {
@@ -122,6 +176,9 @@ func (p Page) AddStaticPages(pageGroup parser.StaticPagesConfig) bool {
 		} else {
 			newPage = MakeStaticPage(pageName, pageGroup.GroupId, templateName)
 		}
+		if menuConfig, ok := menus[pagePath]; ok {
+			newPage.Menu = MakeMenuInfo(menuConfig)
+		}
 		if !subPage.AddSubPage(newPage) {
 			return false
 		}
@@ -129,6 +186,15 @@ func (p Page) AddStaticPages(pageGroup parser.StaticPagesConfig) bool {
 	return true
 }
 
+func findUnknownMenuLocation(pageGroup parser.StaticPagesConfig) (string, bool) {
+	for _, menuConfig := range pageGroup.Menus {
+		if !slices.Contains(pageGroup.Locations, menuConfig.Location) {
+			return menuConfig.Location, true
+		}
+	}
+	return "", false
+}
+
 func (p Page) GetSubPage(name string) (Page, bool) {
 	if name == "" {
 		return Page{}, false
@@ -170,22 +236,56 @@ func (p Page) extractSubPageAndNamesFromPath(path string) (Page, string, string,
 	return resPage, splitted[last], path, ok
 }
 
+const (
+	ErrorTemplateName = "error"
+
+	errorStatusName       = "ErrorStatus"
+	templateFallbacksName = "TemplateFallbacks"
+)
+
 func CreateTemplate(redirecter common.TemplateRedirecter) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		data := initData(c)
-		if tmpl, redirect := redirecter(data, c); redirect == "" {
+		tmpl, redirect := redirecter(data, c)
+		if err, ok := c.Get(common.ErrorPageName); ok {
+			renderError(c, data, err.(error))
+			return
+		}
+
+		if redirect == "" {
+			fallbacks := c.GetStringSlice(templateFallbacksName)
 			if pagePart := c.Query("pagePart"); pagePart != "" {
-				var tmplBuilder strings.Builder
-				tmplBuilder.WriteString(tmpl)
-				tmplBuilder.WriteByte('#')
-				tmplBuilder.WriteString(pagePart)
-				tmpl = tmplBuilder.String()
+				tmpl = addPagePart(tmpl, pagePart)
+				for index, fallback := range fallbacks {
+					fallbacks[index] = addPagePart(fallback, pagePart)
+				}
 			}
 			otelgin.HTML(c, http.StatusOK, tmpl, templates.ContextAndData{
-				Ctx: c.Request.Context(), Data: data,
+				Ctx: c.Request.Context(), Data: data, Fallbacks: fallbacks,
 			})
 		} else {
-			c.Redirect(http.StatusFound, redirect)
+			c.Redirect(http.StatusFound, common.CheckRedirect(c, redirect))
 		}
 	}
 }
+
+func addPagePart(tmpl string, pagePart string) string {
+	var tmplBuilder strings.Builder
+	tmplBuilder.WriteString(tmpl)
+	tmplBuilder.WriteByte('#')
+	tmplBuilder.WriteString(pagePart)
+	return tmplBuilder.String()
+}
+
+func renderError(c *gin.Context, data gin.H, err error) {
+	status, errorKey := common.ExtractStatusAndKey(GetLogger(c), err)
+	data[errorMsgName] = errorKey
+	data[errorStatusName] = status
+	otelgin.HTML(c, status, ErrorTemplateName, templates.ContextAndData{
+		Ctx: c.Request.Context(), Data: data,
+	})
+}
+
+func notFoundHandler(c *gin.Context) {
+	renderError(c, initData(c), common.ErrNotFound)
+}
@@ -0,0 +1,246 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package puzzleweb
+
+import (
+	"bytes"
+	"container/list"
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dvaumoron/puzzleweb/common/metrics"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// replace the CSRF token of the session used for the rendering
+var csrfPlaceholder = []byte("\x00csrf\x00")
+
+var (
+	pageHitCounter  = metrics.Int64Counter("puzzleweb.pagecache.hit", "Number of page cache hits")
+	pageMissCounter = metrics.Int64Counter("puzzleweb.pagecache.miss", "Number of page cache misses")
+)
+
+type cachedPage struct {
+	key         string
+	body        []byte
+	contentType string
+	hash        string
+	expire      time.Time
+}
+
+// Anonymous pages rendered by CreateTemplate, with a LRU eviction bounding the memory used by bodies.
+type pageCache struct {
+	mutex    sync.Mutex
+	timeOuts map[string]time.Duration
+	maxSize  int
+	size     int
+	lru      *list.List // front is the most recently used
+	entries  map[string]*list.Element
+}
+
+func newPageCache(maxSize int, timeOuts map[string]time.Duration) *pageCache {
+	return &pageCache{timeOuts: timeOuts, maxSize: maxSize, lru: list.New(), entries: map[string]*list.Element{}}
+}
+
+func (pc *pageCache) load(key string, now time.Time) (*cachedPage, bool) {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+
+	element, ok := pc.entries[key]
+	if !ok {
+		return nil, false
+	}
+	page := element.Value.(*cachedPage)
+	if now.After(page.expire) {
+		pc.remove(element)
+		return nil, false
+	}
+	pc.lru.MoveToFront(element)
+	return page, true
+}
+
+func (pc *pageCache) store(page *cachedPage) {
+	size := len(page.body)
+	if size > pc.maxSize {
+		return
+	}
+
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+
+	if element, ok := pc.entries[page.key]; ok {
+		pc.remove(element)
+	}
+	for pc.size+size > pc.maxSize {
+		pc.remove(pc.lru.Back())
+	}
+	pc.entries[page.key] = pc.lru.PushFront(page)
+	pc.size += size
+}
+
+func (pc *pageCache) invalidate(pathPrefixes []string) {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+
+	for key, element := range pc.entries {
+		for _, pathPrefix := range pathPrefixes {
+			if strings.HasPrefix(key, pathPrefix) {
+				pc.remove(element)
+				break
+			}
+		}
+	}
+}
+
+// must be called with the mutex locked
+func (pc *pageCache) remove(element *list.Element) {
+	page := pc.lru.Remove(element).(*cachedPage)
+	delete(pc.entries, page.key)
+	pc.size -= len(page.body)
+}
+
+// Cache the page for anonymous users, with the time to live configured for the kind (see config.StaticPageKind)
+// (there is no caching when the page cache is disabled or the kind has no time to live).
+func CachePage(kind string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		pc := getSite(c).pageCache
+		if pc == nil || c.Request.Method != http.MethodGet || GetSession(c).Load(loginName) != "" {
+			return
+		}
+		timeOut := pc.timeOuts[kind]
+		if timeOut == 0 {
+			return
+		}
+
+		token := GetCsrfToken(c)
+		if token == "" {
+			return
+		}
+
+		ctx := c.Request.Context()
+		key := buildPageKey(c)
+		now := time.Now()
+		if page, ok := pc.load(key, now); ok {
+			pageHitCounter.Add(ctx, 1)
+			writePage(c, page, token)
+			c.Abort()
+			return
+		}
+		pageMissCounter.Add(ctx, 1)
+
+		writer := &captureWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+
+		if writer.Status() != http.StatusOK {
+			writer.flush()
+			return
+		}
+
+		body := bytes.ReplaceAll(writer.buffer.Bytes(), []byte(token), csrfPlaceholder)
+		page := &cachedPage{
+			key: key, body: body, contentType: c.Writer.Header().Get("Content-Type"), hash: hashBytes(body),
+			expire: now.Add(timeOut),
+		}
+		pc.store(page)
+		GetLogger(c).Debug("Page cached", zap.String("pageKey", key))
+		writePage(c, page, token)
+	}
+}
+
+// Remove the cached pages whose path start with pathPrefix, to call when content change
+// (in lang prefix mode, the pages are removed under the prefix of each lang).
+func InvalidatePages(c *gin.Context, pathPrefix string) {
+	site := getSite(c)
+	pc := site.pageCache
+	if pc == nil {
+		return
+	}
+
+	pathPrefixes := []string{pathPrefix}
+	if langPrefix := getLangPrefix(c); langPrefix != "" && strings.HasPrefix(pathPrefix, langPrefix+"/") {
+		pathPrefixes = pathPrefixes[:0]
+		for _, lang := range site.localesManager.GetAllLang() {
+			pathPrefixes = append(pathPrefixes, ReplaceLangPrefix(pathPrefix, lang))
+		}
+	}
+	pc.invalidate(pathPrefixes)
+}
+
+func buildPageKey(c *gin.Context) string {
+	var keyBuilder strings.Builder
+	keyBuilder.WriteString(c.Request.URL.Path)
+	keyBuilder.WriteByte('?')
+	keyBuilder.WriteString(c.Request.URL.RawQuery)
+	keyBuilder.WriteString("#anonymous#")
+	keyBuilder.WriteString(GetLocalesManager(c).GetLang(c))
+	return keyBuilder.String()
+}
+
+func writePage(c *gin.Context, page *cachedPage, token string) {
+	// the body differs for each session, so does the ETag
+	etag := "\"" + page.hash + "-" + hashBytes([]byte(token)) + "\""
+	header := c.Writer.Header()
+	header.Set("ETag", etag)
+	header.Set("Cache-Control", "no-cache")
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, page.contentType, bytes.ReplaceAll(page.body, csrfPlaceholder, []byte(token)))
+}
+
+func hashBytes(data []byte) string {
+	hasher := fnv.New64a()
+	hasher.Write(data)
+	return strconv.FormatUint(hasher.Sum64(), 36)
+}
+
+// buffer the whole response, in order to set the ETag before sending it
+type captureWriter struct {
+	gin.ResponseWriter
+	buffer bytes.Buffer
+}
+
+func (w *captureWriter) Write(data []byte) (int, error) {
+	return w.buffer.Write(data)
+}
+
+func (w *captureWriter) WriteString(s string) (int, error) {
+	return w.buffer.WriteString(s)
+}
+
+// the status is kept by the wrapped writer, headers are sent with the body
+func (w *captureWriter) WriteHeaderNow() {}
+
+func (w *captureWriter) Flush() {}
+
+func (w *captureWriter) flush() {
+	if w.buffer.Len() == 0 {
+		w.ResponseWriter.WriteHeaderNow()
+		return
+	}
+	w.ResponseWriter.Write(w.buffer.Bytes())
+}
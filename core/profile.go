@@ -47,7 +47,7 @@ func defaultRedirecter(c *gin.Context) string {
 	if userId == 0 {
 		return common.DefaultErrorRedirect(GetLogger(c), unknownUserKey)
 	}
-	return profileUrlBuilder(userId).String()
+	return profileUrlBuilder(c, userId).String()
 }
 
 func (w profileWidget) LoadInto(router gin.IRouter) {
@@ -70,30 +70,29 @@ func newProfilePage(profileConfig config.ProfileConfig) Page {
 	p.Widget = profileWidget{
 		defaultHandler: common.CreateRedirect(defaultRedirecter),
 		viewHandler: CreateTemplate(func(data gin.H, c *gin.Context) (string, string) {
-			logger := GetLogger(c)
 			ctx := c.Request.Context()
 			viewedUserId := GetRequestedUserId(c)
 			if viewedUserId == 0 {
-				return "", common.DefaultErrorRedirect(logger, common.ErrorTechnicalKey)
+				return common.ErrorPage(c, common.ErrNotFound)
 			}
 
 			currentUserId, _ := data[common.UserIdName].(uint64)
 			updateRight := viewedUserId == currentUserId
 			if !updateRight {
 				if err := profileService.ViewRight(ctx, currentUserId); err != nil {
-					return "", common.DefaultErrorRedirect(logger, err.Error())
+					return common.ErrorPage(c, err)
 				}
 			}
 
 			profiles, err := profileService.GetProfiles(ctx, []uint64{viewedUserId})
 			if err != nil {
-				return "", common.DefaultErrorRedirect(logger, err.Error())
+				return common.ErrorPage(c, err)
 			}
 
 			userRoles, err := adminService.GetUserRoles(ctx, currentUserId, viewedUserId)
 			// ignore ErrNotAuthorized
 			if err == common.ErrTechnical {
-				return "", common.DefaultErrorRedirect(logger, common.ErrorTechnicalKey)
+				return common.ErrorPage(c, common.ErrTechnical)
 			}
 			if err == nil {
 				data["UserRight"] = displayGroups(userRoles)
@@ -115,15 +114,14 @@ func newProfilePage(profileConfig config.ProfileConfig) Page {
 			return "profile/link", ""
 		}),
 		editHandler: CreateTemplate(func(data gin.H, c *gin.Context) (string, string) {
-			logger := GetLogger(c)
 			userId, _ := data[common.UserIdName].(uint64)
 			if userId == 0 {
-				return "", common.DefaultErrorRedirect(logger, unknownUserKey)
+				return common.ErrorPage(c, errUnknownUser)
 			}
 
 			profiles, err := profileService.GetProfiles(c.Request.Context(), []uint64{userId})
 			if err != nil {
-				return "", common.DefaultErrorRedirect(logger, err.Error())
+				return common.ErrorPage(c, err)
 			}
 
 			userProfile := profiles[userId]
@@ -170,7 +168,7 @@ func newProfilePage(profileConfig config.ProfileConfig) Page {
 				err = profileService.UpdateProfile(ctx, userId, desc, info)
 			}
 
-			targetBuilder := profileUrlBuilder(userId)
+			targetBuilder := profileUrlBuilder(c, userId)
 			if err != nil {
 				common.WriteError(targetBuilder, logger, err.Error())
 			}
@@ -193,7 +191,7 @@ func newProfilePage(profileConfig config.ProfileConfig) Page {
 				err = loginService.ChangeLogin(c.Request.Context(), userId, oldLogin, newLogin, password)
 			}
 
-			targetBuilder := profileUrlBuilder(userId)
+			targetBuilder := profileUrlBuilder(c, userId)
 			if err == nil {
 				session.Store(loginName, newLogin)
 			} else {
@@ -222,7 +220,7 @@ func newProfilePage(profileConfig config.ProfileConfig) Page {
 				}
 			}
 
-			targetBuilder := profileUrlBuilder(userId)
+			targetBuilder := profileUrlBuilder(c, userId)
 			if err != nil {
 				common.WriteError(targetBuilder, logger, err.Error())
 			}
@@ -251,8 +249,9 @@ func GetRequestedUserId(c *gin.Context) uint64 {
 	return userId
 }
 
-func profileUrlBuilder(userId uint64) *strings.Builder {
+func profileUrlBuilder(c *gin.Context, userId uint64) *strings.Builder {
 	targetBuilder := new(strings.Builder)
+	targetBuilder.WriteString(getLangPrefix(c))
 	targetBuilder.WriteString("/profile/view/")
 	targetBuilder.WriteString(strconv.FormatUint(userId, 10))
 	return targetBuilder
@@ -0,0 +1,91 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package puzzleweb
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/dvaumoron/puzzleweb/common"
+	"github.com/dvaumoron/puzzleweb/ratelimit"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Limit the requests by client IP and session, a limited form post is redirected with an error
+// (htmx and other requests receive a 429 status).
+func RateLimit(class string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		checkRateLimit(c, class, "", rateLimitKeys(c)...)
+	}
+}
+
+// login attempts are also limited by client IP and target login, so the attempts on a login
+// from an IP do not exhaust the limit of the ones from another IP (it would allow to lock a user out)
+func rateLimitLogin(c *gin.Context) {
+	keys := rateLimitKeys(c)
+	errorRedirect := c.PostForm(prevUrlWithErrorName)
+	if errorRedirect != "" {
+		errorRedirect += common.ErrorTooManyRequestsKey
+	}
+
+	if c.PostForm("Register") == "true" {
+		checkRateLimit(c, ratelimit.RegisterClass, errorRedirect, keys...)
+		return
+	}
+	if login := c.PostForm(loginName); login != "" {
+		keys = append(keys, "login:"+c.ClientIP()+"/"+login)
+	}
+	checkRateLimit(c, ratelimit.LoginClass, errorRedirect, keys...)
+}
+
+func rateLimitKeys(c *gin.Context) []string {
+	return []string{"ip:" + c.ClientIP(), "session:" + strconv.FormatUint(GetSession(c).id, 10)}
+}
+
+func checkRateLimit(c *gin.Context, class string, errorRedirect string, keys ...string) {
+	limiter := getSite(c).rateLimiter
+	if limiter == nil {
+		return
+	}
+
+	logger := GetLogger(c)
+	wait, err := limiter.Allow(c.Request.Context(), class, keys...)
+	if err != nil {
+		// a failing store must not block the site
+		logger.Warn("Failed to check rate limit", zap.String("class", class), zap.Error(err))
+		return
+	}
+	if wait == 0 {
+		return
+	}
+
+	logger.Info("Rate limit reached", zap.String("class", class), zap.String("clientIp", c.ClientIP()))
+	c.Header("Retry-After", strconv.FormatInt(int64(math.Ceil(wait.Seconds())), 10))
+	if c.Request.Method != http.MethodPost || c.GetHeader("HX-Request") != "" {
+		c.AbortWithStatus(http.StatusTooManyRequests)
+		return
+	}
+	if errorRedirect == "" {
+		errorRedirect = common.DefaultErrorRedirect(logger, common.ErrorTooManyRequestsKey)
+	}
+	c.Redirect(http.StatusFound, common.CheckRedirect(c, errorRedirect))
+	c.Abort()
+}
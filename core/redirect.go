@@ -0,0 +1,300 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package puzzleweb
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/dvaumoron/puzzleweb/common/config/parser"
+	"github.com/gin-gonic/gin"
+)
+
+type redirectRule struct {
+	from   []string // segments of the pattern
+	to     string
+	status int
+}
+
+func makeRedirectRule(redirectConfig parser.RedirectConfig) (redirectRule, error) {
+	from := redirectConfig.From
+	pattern := splitPath(from)
+	names := map[string]struct{}{}
+	last := len(pattern) - 1
+	for index, segment := range pattern {
+		if segment == "" {
+			return redirectRule{}, errors.New("empty segment in redirect source " + from)
+		}
+		switch segment[0] {
+		case '*':
+			if index != last {
+				return redirectRule{}, errors.New("wildcard not at the end of redirect source " + from)
+			}
+			fallthrough
+		case ':':
+			names[segment[1:]] = struct{}{}
+		}
+	}
+
+	to := redirectConfig.To
+	if to == "" {
+		return redirectRule{}, errors.New("empty redirect target for " + from)
+	}
+	for _, segment := range strings.Split(to, "/") {
+		if isPlaceholder(segment) {
+			if _, ok := names[segment[1:]]; !ok {
+				return redirectRule{}, errors.New("unknown segment " + segment + " in redirect target " + to)
+			}
+		}
+	}
+
+	status := redirectConfig.Status
+	switch status {
+	case 0:
+		status = http.StatusMovedPermanently
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		return redirectRule{}, errors.New("unsupported redirect status for " + from)
+	}
+	return redirectRule{from: pattern, to: to, status: status}, nil
+}
+
+// the captured segments by name, "*name" captures the remaining ones (possibly none)
+func (rule redirectRule) match(segments []string) (map[string]string, bool) {
+	captures := map[string]string{}
+	for index, pattern := range rule.from {
+		switch pattern[0] {
+		case '*':
+			captures[pattern[1:]] = strings.Join(segments[min(index, len(segments)):], "/")
+			return captures, true
+		case ':':
+			if index >= len(segments) {
+				return nil, false
+			}
+			captures[pattern[1:]] = segments[index]
+		default:
+			if index >= len(segments) || segments[index] != pattern {
+				return nil, false
+			}
+		}
+	}
+	return captures, len(segments) == len(rule.from)
+}
+
+func (rule redirectRule) expand(captures map[string]string) string {
+	splitted := strings.Split(rule.to, "/")
+	for index, segment := range splitted {
+		if isPlaceholder(segment) {
+			splitted[index] = captures[segment[1:]]
+		}
+	}
+	return strings.Join(splitted, "/")
+}
+
+// conservative check, true when a path built with the target of rule could match other
+func (rule redirectRule) mayReach(other redirectRule) bool {
+	if isExternalUrl(rule.to) {
+		return false
+	}
+
+	target := splitPath(rule.to)
+	for index, pattern := range other.from {
+		if pattern[0] == '*' {
+			return true
+		}
+		if index >= len(target) {
+			return false
+		}
+		segment := target[index]
+		if segment != "" && segment[0] == '*' {
+			return true
+		}
+		if pattern[0] != ':' && !isPlaceholder(segment) && segment != pattern {
+			return false
+		}
+	}
+	return len(target) == len(other.from)
+}
+
+type redirecter struct {
+	redirects []redirectRule
+	aliases   []redirectRule
+	isLang    func(string) bool // set in lang prefix mode
+}
+
+// Check the rules and refuse the ones which could redirect (or alias) in loop.
+func newRedirecter(redirectConfigs []parser.RedirectConfig) (*redirecter, error) {
+	rules := make([]redirectRule, 0, len(redirectConfigs))
+	var res redirecter
+	for _, redirectConfig := range redirectConfigs {
+		rule, err := makeRedirectRule(redirectConfig)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+		if redirectConfig.Alias {
+			res.aliases = append(res.aliases, rule)
+		} else {
+			res.redirects = append(res.redirects, rule)
+		}
+	}
+
+	// depth first search with 0 (not visited), 1 (in progress) and 2 (done) states
+	states := make([]uint8, len(rules))
+	var visit func(int) error
+	visit = func(current int) error {
+		states[current] = 1
+		for next, rule := range rules {
+			if !rules[current].mayReach(rule) {
+				continue
+			}
+			switch states[next] {
+			case 0:
+				if err := visit(next); err != nil {
+					return err
+				}
+			case 1:
+				return errors.New("redirect loop with the rule from /" + strings.Join(rule.from, "/"))
+			}
+		}
+		states[current] = 2
+		return nil
+	}
+	for index := range rules {
+		if states[index] == 0 {
+			if err := visit(index); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &res, nil
+}
+
+func findRule(rules []redirectRule, path string) (string, redirectRule, bool) {
+	segments := splitPath(path)
+	for _, rule := range rules {
+		if captures, ok := rule.match(segments); ok {
+			return rule.expand(captures), rule, true
+		}
+	}
+	return "", redirectRule{}, false
+}
+
+// in lang prefix mode, the rules match the path without its prefix, returned apart to be kept on the target
+func (r *redirecter) findRule(rules []redirectRule, path string) (string, string, redirectRule, bool) {
+	var langPrefix string
+	if r.isLang != nil {
+		if lang, rest, ok := strings.Cut(strings.TrimPrefix(path, "/"), "/"); ok && r.isLang(lang) {
+			langPrefix, path = "/"+lang, "/"+rest
+		}
+	}
+
+	target, rule, ok := findRule(rules, path)
+	if isExternalUrl(target) {
+		langPrefix = ""
+	}
+	return langPrefix, target, rule, ok
+}
+
+// evaluated before the not found handler
+func (r *redirecter) redirect(c *gin.Context) {
+	langPrefix, target, rule, ok := r.findRule(r.redirects, c.Request.URL.Path)
+	if !ok {
+		return
+	}
+	target = langPrefix + target
+
+	if rawQuery := c.Request.URL.RawQuery; rawQuery != "" && !strings.Contains(target, "?") {
+		target += "?" + rawQuery
+	}
+	c.Redirect(rule.status, target)
+	c.Abort()
+}
+
+// rewrite the path of aliases before the routing (the navigation is the one of the aliased page),
+// isPage tells when the target needs the trailing slash of the page routes
+// (otherwise gin would redirect to it instead of serving the page)
+func (r *redirecter) wrap(handler http.Handler, isPage func(string) bool) http.Handler {
+	if len(r.aliases) == 0 {
+		return handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		path := req.URL.Path
+		langPrefix, target, _, ok := r.findRule(r.aliases, path)
+		if !ok {
+			handler.ServeHTTP(w, req)
+			return
+		}
+
+		if !strings.HasSuffix(target, "/") && (strings.HasSuffix(path, "/") || isPage(target)) {
+			target += "/"
+		}
+		target = langPrefix + target
+		// shallow copies like in http.StripPrefix
+		aliasReq := new(http.Request)
+		*aliasReq = *req
+		aliasReq.URL = new(url.URL)
+		*aliasReq.URL = *req.URL
+		aliasReq.URL.Path = target
+		aliasReq.URL.RawPath = ""
+		handler.ServeHTTP(w, aliasReq)
+	})
+}
+
+func (site *Site) AddRedirects(redirectConfigs []parser.RedirectConfig) error {
+	if len(redirectConfigs) == 0 {
+		return nil
+	}
+
+	r, err := newRedirecter(redirectConfigs)
+	if err != nil {
+		return err
+	}
+
+	if site.localesManager.GetLangInUrl() {
+		r.isLang = site.isDeclaredLang
+	}
+	site.redirecter = r
+	return nil
+}
+
+func (site *Site) isPagePath(path string) bool {
+	if path = strings.Trim(path, "/"); path == "" {
+		return true
+	}
+	_, ok := site.root.GetSubPageWithPath(path)
+	return ok
+}
+
+func splitPath(path string) []string {
+	if path = strings.Trim(path, "/"); path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+func isPlaceholder(segment string) bool {
+	return len(segment) > 1 && (segment[0] == ':' || segment[0] == '*')
+}
+
+func isExternalUrl(target string) bool {
+	return strings.Contains(target, "://")
+}
@@ -19,13 +19,16 @@
 package puzzleweb
 
 import (
+	"context"
 	"encoding/base64"
 	"errors"
 	"net/http"
+	"net/url"
 	"strconv"
 
 	"github.com/dvaumoron/puzzleweb/common/config"
 	"github.com/dvaumoron/puzzleweb/common/log"
+	sessionservice "github.com/dvaumoron/puzzleweb/session/service"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
@@ -35,7 +38,10 @@ const (
 	SessionName = "Session"
 )
 
-var errDecodeTooShort = errors.New("the result from base64 decoding is too short")
+var (
+	errDecodeTooShort = errors.New("the result from base64 decoding is too short")
+	errNoSession      = errors.New("the session is not managed")
+)
 
 type sessionManager config.SessionConfig
 
@@ -59,6 +65,24 @@ func (m sessionManager) getSessionId(logger log.Logger, c *gin.Context) (uint64,
 	return sessionId, nil
 }
 
+// Retrieve the connected user without creating a session (0 for anonymous).
+func (m sessionManager) peekUserId(c *gin.Context) uint64 {
+	cookie, err := c.Cookie(cookieName)
+	if err != nil {
+		return 0
+	}
+	sessionId, err := decodeFromBase64(cookie)
+	if err != nil {
+		return 0
+	}
+	session, err := m.Service.Get(c.Request.Context(), sessionId)
+	if err != nil {
+		return 0
+	}
+	userId, _ := strconv.ParseUint(session[userIdName], 10, 64)
+	return userId
+}
+
 func (m sessionManager) generateSessionCookie(c *gin.Context) (uint64, error) {
 	sessionId, err := m.Service.Generate(c.Request.Context())
 	if err == nil {
@@ -71,6 +95,30 @@ func (m sessionManager) setSessionCookie(sessionId uint64, c *gin.Context) {
 	c.SetCookie(cookieName, encodeToBase64(sessionId), m.TimeOut, "/", m.Domain, true, true)
 }
 
+// Cookie referencing an existing session (allow to reuse a session outside of a browser).
+func MakeSessionCookie(sessionId uint64) *http.Cookie {
+	// escaped like in gin.Context.SetCookie
+	return &http.Cookie{Name: cookieName, Value: url.QueryEscape(encodeToBase64(sessionId))}
+}
+
+// Session id of a cookie sent by the site (false when it is not the session cookie).
+func ParseSessionCookie(cookie *http.Cookie) (uint64, bool) {
+	if cookie.Name != cookieName {
+		return 0, false
+	}
+	value, err := url.QueryUnescape(cookie.Value)
+	if err != nil {
+		return 0, false
+	}
+	sessionId, err := decodeFromBase64(value)
+	return sessionId, err == nil
+}
+
+// Session content of a connected user, as stored by the login page.
+func MakeUserSession(userId uint64, login string) map[string]string {
+	return map[string]string{loginName: login, userIdName: strconv.FormatUint(userId, 10)}
+}
+
 func encodeToBase64(i uint64) string {
 	bs := make([]byte, 8)
 	bs[0] = byte(i)
@@ -111,8 +159,11 @@ func decodeFromBase64(s string) (uint64, error) {
 }
 
 type Session struct {
-	session map[string]string
-	change  bool
+	id          uint64
+	session     map[string]string
+	change      bool
+	manager     *sessionManager // nil when the session is not managed
+	previousIds []uint64        // invalidated at the end of the request
 }
 
 func (s *Session) Load(key string) string {
@@ -135,6 +186,32 @@ func (s *Session) Delete(key string) {
 	}
 }
 
+// Give a new id to the session (against session fixation), the content is kept except the
+// anti-forgery token, and the previous id is invalidated at the end of the request.
+// The cookie is sent with the response, so it must be called before writing the response.
+func (s *Session) Renew(c *gin.Context) error {
+	if s.manager == nil {
+		return errNoSession
+	}
+
+	sessionId, err := s.manager.generateSessionCookie(c)
+	if err != nil {
+		return err
+	}
+	s.previousIds = append(s.previousIds, s.id)
+	s.id = sessionId
+	s.change = true
+	s.Delete(CsrfTokenName)
+	return nil
+}
+
+// errors are only logged, the session keeps its id
+func renewSession(c *gin.Context) {
+	if err := GetSession(c).Renew(c); err != nil {
+		GetLogger(c).Error("Failed to renew session", zap.Error(err))
+	}
+}
+
 // Writing in the returned map will not be saved.
 func (s *Session) AsMap() map[string]string {
 	return s.session
@@ -160,14 +237,33 @@ func (m sessionManager) manage(c *gin.Context) {
 		session = map[string]string{}
 	}
 
-	c.Set(SessionName, &Session{session: session}) // change is false (default bool)
+	c.Set(SessionName, &Session{id: sessionId, session: session, manager: &m}) // change is false (default bool)
 	c.Next()
 
-	if s := GetSession(c); s.change {
-		if m.Service.Update(ctx, sessionId, s.session) != nil {
-			logSessionError(logger, "Failed to save session", sessionId, c)
+	s := GetSession(c)
+	if s.change {
+		if m.Service.Update(ctx, s.id, s.session) != nil {
+			logSessionError(logger, "Failed to save session", s.id, c)
 		}
 	}
+	for _, previousId := range s.previousIds {
+		if m.invalidate(ctx, previousId, s.session) != nil {
+			logger.Error("Failed to invalidate session", zap.Uint64("sessionId", previousId))
+		}
+	}
+}
+
+func (m sessionManager) invalidate(ctx context.Context, sessionId uint64, session map[string]string) error {
+	if deleter, ok := m.Service.(sessionservice.SessionDeleter); ok {
+		return deleter.Delete(ctx, sessionId)
+	}
+
+	// empty values are deleted by the service
+	emptied := make(map[string]string, len(session))
+	for key := range session {
+		emptied[key] = ""
+	}
+	return m.Service.Update(ctx, sessionId, emptied)
 }
 
 func logSessionError(logger log.Logger, msg string, sessionId uint64, c *gin.Context) {
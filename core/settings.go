@@ -21,42 +21,242 @@ package puzzleweb
 import (
 	"context"
 	"errors"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/dvaumoron/puzzleweb/common"
 	"github.com/dvaumoron/puzzleweb/common/config"
+	"github.com/dvaumoron/puzzleweb/common/config/parser"
 	"github.com/dvaumoron/puzzleweb/locale"
 	"go.uber.org/zap"
 
 	"github.com/gin-gonic/gin"
 )
 
-const settingsName = "Settings"
+// kinds of setting value
+const (
+	SettingEnum   = "enum"
+	SettingBool   = "bool"
+	SettingInt    = "int"
+	SettingString = "string"
+)
 
-var errWrongLang = errors.New(common.WrongLangKey)
+const (
+	settingsName       = "Settings"
+	settingsSchemaName = "SettingsSchema"
+)
+
+var (
+	errUnknownSetting = errors.New(common.ErrorUnknownSettingKey)
+	errWrongLang      = errors.New(common.WrongLangKey)
+	errWrongSetting   = errors.New(common.ErrorWrongSettingKey)
+	errWrongTimeZone  = errors.New(common.ErrorWrongTimeZoneKey)
+)
+
+// Declaration of a user setting, used to check the saved values and to generate the edit form.
+type SettingDesc struct {
+	Key     string
+	Type    string // SettingEnum, SettingBool, SettingInt or SettingString
+	Default string
+	Values  []string // allowed values of an enum (the declared locales for lang)
+	Label   string   // message key
+}
+
+func MakeSettingDesc(settingConfig parser.SettingConfig) SettingDesc {
+	settingType := settingConfig.Type
+	if settingType == "" {
+		settingType = SettingString
+	}
+	label := settingConfig.Label
+	if label == "" {
+		label = "Setting" + locale.CamelCase(settingConfig.Key)
+	}
+	return SettingDesc{
+		Key: settingConfig.Key, Type: settingType, Default: settingConfig.Default, Values: settingConfig.Values, Label: label,
+	}
+}
+
+// return the value in its normalized form ("1" gives "true" for a bool)
+func (desc SettingDesc) check(value string) (string, bool) {
+	switch desc.Type {
+	case SettingEnum:
+		// without values (like lang) the validator does the check
+		return value, len(desc.Values) == 0 || slices.Contains(desc.Values, value)
+	case SettingBool:
+		b, err := strconv.ParseBool(value)
+		return strconv.FormatBool(b), err == nil
+	case SettingInt:
+		i, err := strconv.ParseInt(value, 10, 64)
+		return strconv.FormatInt(i, 10), err == nil
+	}
+	return value, true
+}
+
+// Check a value (already matching the type of the setting) and return the one to save.
+type SettingValidator func(string, *gin.Context) (string, error)
 
 type SettingsManager struct {
 	config.SettingsConfig
 	InitSettings  func(*gin.Context) map[string]string
 	CheckSettings func(map[string]string, *gin.Context) error
+	schema        []SettingDesc
+	validators    map[string]SettingValidator
+}
+
+// lang and timeZone are always declared, the schema of the configuration is added after them
+func NewSettingsManager(settingsConfig config.SettingsConfig) (*SettingsManager, bool) {
+	m := &SettingsManager{
+		SettingsConfig: settingsConfig,
+		schema: []SettingDesc{
+			{Key: locale.LangName, Type: SettingEnum, Label: "SettingLang"},
+			{Key: locale.TimeZoneName, Type: SettingString, Label: "SettingTimeZone"},
+		},
+		validators: map[string]SettingValidator{locale.LangName: validateLang, locale.TimeZoneName: validateTimeZone},
+	}
+	m.InitSettings = m.initSettings
+	m.CheckSettings = m.checkSettings
+	for _, settingConfig := range settingsConfig.Schema {
+		if !m.AddSetting(MakeSettingDesc(settingConfig)) {
+			return nil, false
+		}
+	}
+	return m, true
 }
 
-func NewSettingsManager(settingsConfig config.SettingsConfig) *SettingsManager {
-	return &SettingsManager{SettingsConfig: settingsConfig, InitSettings: initSettings, CheckSettings: checkSettings}
+// Declare a setting, the declaration of an existing key replaces it (its validator is kept).
+func (m *SettingsManager) AddSetting(desc SettingDesc) bool {
+	switch desc.Type {
+	case SettingEnum:
+		if len(desc.Values) == 0 {
+			m.Logger.Error("Enum setting without values", zap.String("setting", desc.Key))
+			return false
+		}
+		if desc.Default == "" {
+			desc.Default = desc.Values[0]
+		}
+	case SettingBool:
+		if desc.Default == "" {
+			desc.Default = "false"
+		}
+	case SettingInt:
+		if desc.Default == "" {
+			desc.Default = "0"
+		}
+	case SettingString:
+	default:
+		m.Logger.Error("Unknown setting type", zap.String("setting", desc.Key), zap.String("type", desc.Type))
+		return false
+	}
+
+	defaultValue, ok := desc.check(desc.Default)
+	if desc.Key == "" || !ok {
+		m.Logger.Error("Wrong setting declaration", zap.String("setting", desc.Key), zap.String("default", desc.Default))
+		return false
+	}
+	desc.Default = defaultValue
+
+	if index := m.indexOf(desc.Key); index != -1 {
+		m.schema[index] = desc
+	} else {
+		m.schema = append(m.schema, desc)
+	}
+	return true
+}
+
+// Register the validator of a setting (replacing the previous one).
+func (m *SettingsManager) SetValidator(key string, validator SettingValidator) {
+	m.validators[key] = validator
+}
+
+// The declared settings, the values of lang are the locales of the site.
+func (m *SettingsManager) Schema(c *gin.Context) []SettingDesc {
+	schema := slices.Clone(m.schema)
+	if index := m.indexOf(locale.LangName); index != -1 && len(schema[index].Values) == 0 {
+		schema[index].Values = GetLocalesManager(c).GetAllLang()
+	}
+	return schema
 }
 
-func initSettings(c *gin.Context) map[string]string {
-	return map[string]string{locale.LangName: GetLocalesManager(c).GetLang(c)}
+func (m *SettingsManager) indexOf(key string) int {
+	return slices.IndexFunc(m.schema, func(desc SettingDesc) bool {
+		return desc.Key == key
+	})
 }
 
-func checkSettings(settings map[string]string, c *gin.Context) error {
-	askedLang := settings[locale.LangName]
+func (m *SettingsManager) initSettings(c *gin.Context) map[string]string {
+	settings := make(map[string]string, len(m.schema))
+	m.fillDefaults(settings)
+	settings[locale.LangName] = GetLocalesManager(c).GetLang(c)
+	return settings
+}
+
+// settings saved before the declaration of a key do not have it
+func (m *SettingsManager) fillDefaults(settings map[string]string) {
+	for _, desc := range m.schema {
+		if _, ok := settings[desc.Key]; !ok {
+			settings[desc.Key] = desc.Default
+		}
+	}
+}
+
+func (m *SettingsManager) checkSettings(settings map[string]string, c *gin.Context) error {
+	for key := range settings {
+		if m.indexOf(key) == -1 {
+			return errUnknownSetting
+		}
+	}
+
+	for _, desc := range m.schema {
+		value, ok := settings[desc.Key]
+		if !ok {
+			value = desc.Default
+			if desc.Type == SettingBool {
+				// unchecked boxes are not sent
+				value = "false"
+			}
+		}
+
+		if value, ok = desc.check(value); !ok {
+			return errWrongSetting
+		}
+		settings[desc.Key] = value
+
+		if validator := m.validators[desc.Key]; validator != nil {
+			checked, err := validator(value, c)
+			settings[desc.Key] = checked
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func validateLang(askedLang string, c *gin.Context) (string, error) {
 	lang := GetLocalesManager(c).SetLangCookie(askedLang, c)
-	settings[locale.LangName] = lang
 	if lang != askedLang {
-		return errWrongLang
+		return lang, errWrongLang
+	}
+	return lang, nil
+}
+
+// empty for the server timezone
+func validateTimeZone(timeZone string, c *gin.Context) (string, error) {
+	if _, err := time.LoadLocation(timeZone); err != nil {
+		return "", errWrongTimeZone
+	}
+	return timeZone, nil
+}
+
+// the timezone is kept in session to format the dates of each page
+func storeTimeZone(c *gin.Context, settings map[string]string) {
+	if timeZone := settings[locale.TimeZoneName]; timeZone == "" {
+		GetSession(c).Delete(locale.TimeZoneName)
+	} else {
+		GetSession(c).Store(locale.TimeZoneName, timeZone)
 	}
-	return nil
 }
 
 func (m *SettingsManager) Get(ctx context.Context, userId uint64, c *gin.Context) map[string]string {
@@ -76,6 +276,8 @@ func (m *SettingsManager) Get(ctx context.Context, userId uint64, c *gin.Context
 		if err != nil {
 			m.LoggerGetter.Logger(ctx).Warn("Failed to create user settings", zap.Error(err))
 		}
+	} else {
+		m.fillDefaults(userSettings)
 	}
 	c.Set(settingsName, userSettings)
 	return userSettings
@@ -101,13 +303,13 @@ func newSettingsPage(settingsConfig config.ServiceConfig[*SettingsManager]) Page
 	p := MakeHiddenPage("settings")
 	p.Widget = settingsWidget{
 		editHandler: CreateTemplate(func(data gin.H, c *gin.Context) (string, string) {
-			logger := GetLogger(c)
 			userId, _ := data[common.UserIdName].(uint64)
 			if userId == 0 {
-				return "", common.DefaultErrorRedirect(logger, unknownUserKey)
+				return common.ErrorPage(c, errUnknownUser)
 			}
 
-			data["Settings"] = settingsManager.Get(c.Request.Context(), userId, c)
+			data[settingsName] = settingsManager.Get(c.Request.Context(), userId, c)
+			data[settingsSchemaName] = settingsManager.Schema(c)
 			return "settings/edit", ""
 		}),
 		saveHandler: common.CreateRedirect(func(c *gin.Context) string {
@@ -122,8 +324,18 @@ func newSettingsPage(settingsConfig config.ServiceConfig[*SettingsManager]) Page
 			if err == nil {
 				err = settingsManager.Update(c.Request.Context(), userId, settings)
 			}
+			if err == nil {
+				storeTimeZone(c, settings)
+			}
+
+			langPrefix := getLangPrefix(c)
+			if langPrefix != "" && err == nil {
+				// the saved lang has been validated (the prefix of the current url is the previous lang)
+				langPrefix = "/" + settings[locale.LangName]
+			}
 
 			var targetBuilder strings.Builder
+			targetBuilder.WriteString(langPrefix)
 			targetBuilder.WriteString("/settings")
 			if err != nil {
 				common.WriteError(&targetBuilder, logger, err.Error())
@@ -0,0 +1,872 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package puzzleweb_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	adminservice "github.com/dvaumoron/puzzleweb/admin/service"
+	"github.com/dvaumoron/puzzleweb/common"
+	"github.com/dvaumoron/puzzleweb/common/config/parser"
+	puzzleweb "github.com/dvaumoron/puzzleweb/core"
+	"github.com/dvaumoron/puzzleweb/locale"
+	"github.com/dvaumoron/puzzleweb/puzzlewebtest"
+	"github.com/dvaumoron/puzzleweb/ratelimit"
+	"github.com/gin-gonic/gin"
+)
+
+type listWidget struct {
+	count int
+	calls int
+}
+
+func (*listWidget) LoadInto(router gin.IRouter) {}
+
+func (w *listWidget) SitemapEntries(ctx context.Context, baseUrl string, allLang []string) ([]puzzleweb.SitemapEntry, error) {
+	w.calls++
+	entries := make([]puzzleweb.SitemapEntry, 0, w.count)
+	for index := 0; index < w.count; index++ {
+		entries = append(entries, puzzleweb.SitemapEntry{Url: baseUrl + strconv.Itoa(index)})
+	}
+	return entries, nil
+}
+
+type helloWidget struct{}
+
+func (helloWidget) LoadInto(router gin.IRouter) {
+	router.GET("/", puzzleweb.CreateTemplate(func(data gin.H, c *gin.Context) (string, string) {
+		data["Greeting"] = "hello " + c.Query("name")
+		return "hello", ""
+	}))
+}
+
+// write the CSRF token of the session, with a cache of the pages of kind "token"
+type tokenWidget struct {
+	renders int
+}
+
+func (w *tokenWidget) LoadInto(router gin.IRouter) {
+	router.GET("/", puzzleweb.CachePage("token"), func(c *gin.Context) {
+		w.renders++
+		c.String(http.StatusOK, "token:"+puzzleweb.GetCsrfToken(c))
+	})
+	router.POST("/save", func(c *gin.Context) {
+		puzzleweb.InvalidatePages(c, "/token/")
+		c.Status(http.StatusNoContent)
+	})
+}
+
+func TestStaticPages(t *testing.T) {
+	builder := puzzlewebtest.NewSiteBuilder(t)
+	builder.AddStaticPages(adminservice.PublicGroupId, "about", "docs/")
+	builder.AddStaticPages(adminservice.PublicGroupId, "docs/install")
+	site := builder.Build()
+	client := site.NewClient()
+
+	response := client.Get("/")
+	response.AssertStatus(t, http.StatusOK)
+	response.AssertTemplate(t, "index")
+	response.AssertData(t, "PageTitle", "PageTitleRoot")
+	response.AssertData(t, "SubPages", []puzzleweb.PageDesc{
+		{Name: "PageTitleAbout", Url: "/about"}, {Name: "PageTitleDocs", Url: "/docs"},
+	})
+	response.AssertData(t, "LoginUrl", "/login?Redirect=%2F")
+
+	response = client.Get("/docs/install/")
+	response.AssertTemplate(t, "docs/install")
+	response.AssertData(t, "Ariane", []puzzleweb.PageDesc{
+		{Name: "PageTitleDocs", Url: "/docs"}, {Name: "PageTitleInstall", Url: "/docs/install"},
+	})
+
+	client.Get("/docs/").AssertTemplate(t, "docs/index")
+}
+
+func TestNotFound(t *testing.T) {
+	site := puzzlewebtest.NewSiteBuilder(t).Build()
+
+	site.NewClient().Get("/unknown").AssertError(t, common.ErrNotFound)
+}
+
+func TestStaticPageAccess(t *testing.T) {
+	const privateGroupId = 5
+
+	builder := puzzlewebtest.NewSiteBuilder(t)
+	builder.AddStaticPages(privateGroupId, "private")
+	builder.Rights.Authorize = func(userId uint64, groupId uint64, action string) bool {
+		return groupId != privateGroupId || userId != 0
+	}
+	site := builder.Build()
+
+	site.NewClient().Get("/private/").AssertError(t, common.ErrNotAuthorized)
+
+	response := site.NewLoggedClient("alice").Get("/private/")
+	response.AssertStatus(t, http.StatusOK)
+	response.AssertTemplate(t, "private")
+}
+
+func TestCustomPage(t *testing.T) {
+	builder := puzzlewebtest.NewSiteBuilder(t)
+	page := puzzleweb.MakePage("hello")
+	page.Widget = helloWidget{}
+	builder.AddPage(page)
+	site := builder.Build()
+
+	response := site.NewClient().Get("/hello/?name=bob")
+	response.AssertTemplate(t, "hello")
+	response.AssertData(t, "Greeting", "hello bob")
+	response.AssertData(t, "Ariane", []puzzleweb.PageDesc{{Name: "PageTitleHello", Url: "/hello"}})
+}
+
+func TestLoggedSession(t *testing.T) {
+	site := puzzlewebtest.NewSiteBuilder(t).Build()
+	client := site.NewLoggedClient("alice")
+
+	response := client.Get("/")
+	response.AssertData(t, "Login", "alice")
+	response.AssertData(t, common.UserIdName, client.UserId)
+	response.AssertData(t, "LoginUrl", "/login/logout?Redirect=%2F")
+
+	client.Get("/login/logout?Redirect=/").AssertRedirect(t, "/")
+	if _, ok := client.Get("/").Data()["Login"]; ok {
+		t.Error("user still connected after logout")
+	}
+}
+
+func TestLogin(t *testing.T) {
+	site := puzzlewebtest.NewSiteBuilder(t).Build()
+	userId := site.Logins.AddUser("alice", "secret")
+	client := site.NewClient()
+
+	response := client.Get("/login/")
+	response.AssertTemplate(t, "login")
+	if _, ok := response.Data()["LoginUrl"]; ok {
+		t.Error("login page should not display the login link")
+	}
+
+	form := url.Values{"Login": {"alice"}, "Password": {"wrong"}, "Redirect": {"/"}, "PrevUrlWithError": {"/login?error="}}
+	client.PostForm("/login/submit", form).AssertRedirect(t, "/login?error="+common.ErrorWrongLoginKey)
+
+	form.Set("Password", "secret")
+	client.PostForm("/login/submit", form).AssertRedirect(t, "/")
+	client.Get("/").AssertData(t, common.UserIdName, userId)
+
+	if calls := site.Logins.CallsTo("Verify"); len(calls) != 2 {
+		t.Errorf("Verify called %d times, expected 2", len(calls))
+	}
+}
+
+func TestLoginRateLimit(t *testing.T) {
+	builder := puzzlewebtest.NewSiteBuilder(t)
+	builder.SiteConfig.RateLimiter = ratelimit.NewLimiter(ratelimit.NewMemoryStore(), map[string]ratelimit.Limit{
+		ratelimit.LoginClass: ratelimit.MakeLimit(2, time.Minute, 0),
+	})
+	site := builder.Build()
+	site.Logins.AddUser("alice", "secret")
+
+	form := url.Values{"Login": {"alice"}, "Password": {"wrong"}, "Redirect": {"/"}, "PrevUrlWithError": {"/login?error="}}
+	attacker := site.NewClient()
+	attacker.RemoteAddr = "192.0.2.10:1234"
+	for index := 0; index < 2; index++ {
+		attacker.PostForm("/login/submit", form).AssertRedirect(t, "/login?error="+common.ErrorWrongLoginKey)
+	}
+	attacker.PostForm("/login/submit", form).AssertRedirect(t, "/login?error="+common.ErrorTooManyRequestsKey)
+
+	// neither a new session nor a forged header escape the limit of the IP (no proxy is trusted)
+	attacker = site.NewClient()
+	attacker.RemoteAddr = "192.0.2.10:1234"
+	attacker.Header = http.Header{"X-Forwarded-For": {"198.51.100.7"}, "Hx-Request": {"true"}}
+	response := attacker.PostForm("/login/submit", form)
+	response.AssertStatus(t, http.StatusTooManyRequests)
+	if response.Header().Get("Retry-After") == "" {
+		t.Error("missing Retry-After header")
+	}
+	if calls := site.Logins.CallsTo("Verify"); len(calls) != 2 {
+		t.Errorf("Verify called %d times, expected 2", len(calls))
+	}
+
+	// the user is not locked out by the attempts from another IP
+	user := site.NewClient()
+	user.RemoteAddr = "203.0.113.5:1234"
+	form.Set("Password", "secret")
+	user.PostForm("/login/submit", form).AssertRedirect(t, "/")
+}
+
+func TestUnsafeLoginRedirect(t *testing.T) {
+	site := puzzlewebtest.NewSiteBuilder(t).Build()
+	site.Logins.AddUser("alice", "secret")
+	client := site.NewClient()
+
+	client.Get("/login/?Redirect=//evil.com").AssertData(t, common.RedirectName, "/")
+	form := url.Values{"Login": {"alice"}, "Password": {"secret"}, "Redirect": {"//evil.com"}, "PrevUrlWithError": {"/login?error="}}
+	client.PostForm("/login/submit", form).AssertRedirect(t, "/")
+	client.Get("/login/logout?Redirect=%2F%5Cevil.com").AssertRedirect(t, "/")
+}
+
+func TestCsrfProtection(t *testing.T) {
+	site := puzzlewebtest.NewSiteBuilder(t).Build()
+	client := site.NewClient()
+
+	request, _ := http.NewRequest(http.MethodPost, "/login/submit", nil)
+	client.Do(request).AssertRedirect(t, "/?error="+common.ErrorWrongCsrfTokenKey)
+	if calls := site.Logins.Calls(); len(calls) != 0 {
+		t.Errorf("login service called without valid token : %v", calls)
+	}
+}
+
+func TestSessionFailure(t *testing.T) {
+	site := puzzlewebtest.NewSiteBuilder(t).Build()
+	client := site.NewClient()
+
+	site.Sessions.FailWith("Get", errors.New("session store down"))
+	response := client.Get("/")
+	response.AssertStatus(t, http.StatusInternalServerError)
+	if len(response.Renders) != 0 {
+		t.Error("no template should be rendered without session")
+	}
+}
+
+func TestTemplateFailure(t *testing.T) {
+	site := puzzlewebtest.NewSiteBuilder(t).Build()
+
+	site.Templates.FailWith("Render", errors.New("template service down"))
+	if response := site.NewClient().Get("/"); response.Body.Len() != 0 {
+		t.Errorf("unexpected body : %q", response.Body.String())
+	}
+}
+
+func TestMarkdownPages(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "docs", "index.md"), "---\ntitle: Documentation\norder: 2\n---\n# Docs\n")
+	writeFile(t, filepath.Join(dir, "docs", "install.md"), "Run it")
+	writeFile(t, filepath.Join(dir, "about.md"), "---\ntitle: About\ndescription: Who we are\norder: 1\n---\nAbout us")
+	writeFile(t, filepath.Join(dir, "about.fr.md"), "---\ntitle: A propos\n---\nQui sommes nous")
+	writeFile(t, filepath.Join(dir, "secret.md"), "---\nhidden: true\ngroup: 7\n---\nHidden")
+
+	builder := puzzlewebtest.NewSiteBuilder(t)
+	builder.AllLang = []string{"en", "fr"}
+	builder.AddMarkdownPages(parser.MarkdownPagesConfig{Path: dir, Layout: "page"})
+	builder.Rights.Authorize = func(userId uint64, groupId uint64, action string) bool {
+		return groupId != 7
+	}
+	site := builder.Build()
+	client := site.NewClient()
+
+	response := client.Get("/")
+	response.AssertData(t, "SubPages", []puzzleweb.PageDesc{
+		{MenuInfo: puzzleweb.MenuInfo{Weight: 1}, Name: "PageTitleAbout", Url: "/about"},
+		{MenuInfo: puzzleweb.MenuInfo{Weight: 2}, Name: "PageTitleDocs", Url: "/docs"},
+	})
+
+	response = client.Get("/about/")
+	response.AssertTemplate(t, "page")
+	response.AssertData(t, "MarkdownTitle", "About")
+	response.AssertData(t, "MarkdownDescription", "Who we are")
+	response.AssertData(t, "MarkdownContent", "<p>About us</p>\n")
+
+	client.Get("/docs/").AssertData(t, "MarkdownContent", "<h1>Docs</h1>\n")
+	client.Get("/docs/install/").AssertData(t, "MarkdownContent", "<p>Run it</p>\n")
+	client.Get("/secret/").AssertError(t, common.ErrNotAuthorized)
+
+	request := httptest.NewRequest(http.MethodGet, "/about/", nil)
+	request.AddCookie(&http.Cookie{Name: "lang", Value: "fr"})
+	client.Do(request).AssertData(t, "MarkdownTitle", "A propos")
+
+	// the file is read again when modified
+	aboutPath := filepath.Join(dir, "about.md")
+	writeFile(t, aboutPath, "Updated")
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(aboutPath, later, later); err != nil {
+		t.Fatal(err)
+	}
+	client.Get("/about/").AssertData(t, "MarkdownContent", "<p>Updated</p>\n")
+	if calls := site.Markdown.CallsTo("Apply"); len(calls) != 6 {
+		t.Errorf("Apply called %d times, expected 6", len(calls))
+	}
+}
+
+func writeFile(t *testing.T, path string, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMenu(t *testing.T) {
+	builder := puzzlewebtest.NewSiteBuilder(t)
+	builder.AddStaticPagesConfig(parser.StaticPagesConfig{
+		Locations: []string{"about", "docs/", "contact"},
+		Menus: []parser.MenuConfig{
+			{Location: "about", Weight: 2, Icon: "info"},
+			{Location: "docs/", Weight: 1, Group: "Help", Separator: true},
+		},
+	})
+	builder.AddStaticPagesConfig(parser.StaticPagesConfig{
+		Locations: []string{"docs/install", "docs/source"},
+		Menus:     []parser.MenuConfig{{Location: "docs/source", Weight: -1, Link: "https://example.com/source", NewTab: true}},
+	})
+	site := builder.Build()
+
+	response := site.NewClient().Get("/")
+	response.AssertData(t, "SubPages", []puzzleweb.PageDesc{
+		{Name: "PageTitleContact", Url: "/contact"},
+		{MenuInfo: puzzleweb.MenuInfo{Weight: 1, Group: "Help", Separator: true}, Name: "PageTitleDocs", Url: "/docs"},
+		{MenuInfo: puzzleweb.MenuInfo{Weight: 2, Icon: "info"}, Name: "PageTitleAbout", Url: "/about"},
+	})
+	response.AssertData(t, "Menu", []puzzleweb.PageDesc{
+		{Name: "PageTitleContact", Url: "/contact"},
+		{
+			MenuInfo: puzzleweb.MenuInfo{Weight: 1, Group: "Help", Separator: true}, Name: "PageTitleDocs", Url: "/docs",
+			SubPages: []puzzleweb.PageDesc{
+				{
+					MenuInfo: puzzleweb.MenuInfo{Weight: -1, Link: "https://example.com/source", NewTab: true},
+					Name:     "PageTitleSource", Url: "https://example.com/source",
+				},
+				{Name: "PageTitleInstall", Url: "/docs/install"},
+			},
+		},
+		{MenuInfo: puzzleweb.MenuInfo{Weight: 2, Icon: "info"}, Name: "PageTitleAbout", Url: "/about"},
+	})
+
+	unknownMenu := parser.StaticPagesConfig{Locations: []string{"faq"}, Menus: []parser.MenuConfig{{Location: "docs/faq"}}}
+	if site.Site.AddStaticPages(unknownMenu) {
+		t.Error("menu with an unknown location accepted")
+	}
+	if _, ok := site.Site.GetPage("faq"); ok {
+		t.Error("pages added despite the unknown menu location")
+	}
+}
+
+func TestNavigationAccess(t *testing.T) {
+	const membersGroupId = 5
+
+	builder := puzzlewebtest.NewSiteBuilder(t)
+	builder.AddStaticPages(adminservice.PublicGroupId, "about", "docs/", "docs/install")
+	builder.AddStaticPages(membersGroupId, "members/", "docs/internal")
+	builder.AddStaticPages(adminservice.PublicGroupId, "members/faq")
+	builder.Rights.Authorize = func(userId uint64, groupId uint64, action string) bool {
+		return groupId == adminservice.PublicGroupId || (groupId == membersGroupId && userId != 0)
+	}
+	site := builder.Build()
+
+	site.Rights.Reset()
+	response := site.NewClient().Get("/")
+	response.AssertData(t, "SubPages", []puzzleweb.PageDesc{
+		{Name: "PageTitleAbout", Url: "/about"}, {Name: "PageTitleDocs", Url: "/docs"},
+	})
+	response.AssertData(t, "Menu", []puzzleweb.PageDesc{
+		{Name: "PageTitleAbout", Url: "/about"},
+		{Name: "PageTitleDocs", Url: "/docs", SubPages: []puzzleweb.PageDesc{
+			{Name: "PageTitleInstall", Url: "/docs/install"},
+		}},
+	})
+	// one query by group (public, members and admin)
+	if calls := site.Rights.CallsTo("AuthQuery"); len(calls) != 3 {
+		t.Errorf("AuthQuery called %d times, expected 3", len(calls))
+	}
+
+	site.NewClient().Get("/members/faq/").AssertData(t, "Ariane", []puzzleweb.PageDesc{
+		{Name: "PageTitleFaq", Url: "/members/faq"},
+	})
+
+	response = site.NewLoggedClient("alice").Get("/members/faq/")
+	response.AssertData(t, "Ariane", []puzzleweb.PageDesc{
+		{Name: "PageTitleMembers", Url: "/members"}, {Name: "PageTitleFaq", Url: "/members/faq"},
+	})
+	response.AssertData(t, "Menu", []puzzleweb.PageDesc{
+		{Name: "PageTitleAbout", Url: "/about"},
+		{Name: "PageTitleDocs", Url: "/docs", SubPages: []puzzleweb.PageDesc{
+			{Name: "PageTitleInstall", Url: "/docs/install"}, {Name: "PageTitleInternal", Url: "/docs/internal"},
+		}},
+		{Name: "PageTitleMembers", Url: "/members", SubPages: []puzzleweb.PageDesc{
+			{Name: "PageTitleFaq", Url: "/members/faq"},
+		}},
+	})
+}
+
+func TestRedirects(t *testing.T) {
+	builder := puzzlewebtest.NewSiteBuilder(t)
+	builder.AddStaticPages(adminservice.PublicGroupId, "about", "docs/", "docs/install")
+	builder.AddRedirects(
+		parser.RedirectConfig{From: "/old-docs/*rest", To: "/docs/*rest"},
+		parser.RedirectConfig{From: "/news/:year/:title", To: "/blog/:title", Status: http.StatusFound},
+		parser.RedirectConfig{From: "/about", To: "/somewhere"}, // the page wins
+		parser.RedirectConfig{From: "/guide", To: "/docs/install", Alias: true},
+	)
+	site := builder.Build()
+
+	client := site.NewClient()
+	response := client.Get("/old-docs/install?lang=fr")
+	response.AssertStatus(t, http.StatusMovedPermanently)
+	if location := response.Header().Get("Location"); location != "/docs/install?lang=fr" {
+		t.Errorf("redirected to %q", location)
+	}
+
+	client.Get("/news/2023/hello").AssertRedirect(t, "/blog/hello")
+	client.Get("/about/").AssertTemplate(t, "about")
+	client.Get("/news/2023").AssertStatus(t, http.StatusNotFound)
+
+	for _, aliasPath := range []string{"/guide/", "/guide"} {
+		response = client.Get(aliasPath)
+		response.AssertStatus(t, http.StatusOK)
+		response.AssertTemplate(t, "docs/install")
+		response.AssertData(t, "CurrentUrl", "/docs/install/")
+	}
+
+	loops := [][]parser.RedirectConfig{
+		{{From: "/a", To: "/a/"}},
+		{{From: "/a/*rest", To: "/b/*rest"}, {From: "/b/:name", To: "/a/:name/more"}},
+		{{From: "/a", To: "/b", Alias: true}, {From: "/b", To: "/a"}},
+	}
+	for _, redirectConfigs := range loops {
+		if err := site.Site.AddRedirects(redirectConfigs); err == nil {
+			t.Error("loop not detected", redirectConfigs)
+		}
+	}
+	if err := site.Site.AddRedirects([]parser.RedirectConfig{{From: "/a/:name", To: "/b/:other"}}); err == nil {
+		t.Error("unknown segment not detected")
+	}
+	if err := site.Site.AddRedirects([]parser.RedirectConfig{{From: "/a", To: "https://example.com/a", Status: http.StatusTemporaryRedirect}}); err != nil {
+		t.Error("unexpected error", err)
+	}
+}
+
+func TestLangInUrl(t *testing.T) {
+	builder := puzzlewebtest.NewSiteBuilder(t)
+	builder.AllLang = []string{"en", "fr"}
+	builder.LangInUrl = true
+	builder.AddStaticPages(adminservice.PublicGroupId, "docs/", "docs/install")
+	builder.AddRedirects(
+		parser.RedirectConfig{From: "/old-docs/*rest", To: "/docs/*rest"},
+		parser.RedirectConfig{From: "/guide", To: "/docs/install", Alias: true},
+	)
+	site := builder.Build()
+	client := site.NewClient()
+
+	client.Get("/docs/install?x=1").AssertRedirect(t, "/en/docs/install?x=1")
+	client.Get("/unknown/").AssertRedirect(t, "/en/unknown/")
+	client.Get("/fr/unknown/").AssertStatus(t, http.StatusNotFound)
+
+	response := client.Get("/fr/docs/install/")
+	response.AssertTemplate(t, "fr/docs/install")
+	response.AssertData(t, "lang", "fr")
+	response.AssertData(t, "Ariane", []puzzleweb.PageDesc{
+		{Name: "PageTitleDocs", Url: "/fr/docs"}, {Name: "PageTitleInstall", Url: "/fr/docs/install"},
+	})
+	response.AssertData(t, "LangAlternates", []puzzleweb.LangAlternate{
+		{Lang: "en", Url: "http://localhost/en/docs/install/"}, {Lang: "fr", Url: "http://localhost/fr/docs/install/"},
+	})
+	response.AssertData(t, "LoginUrl", "/fr/login?Redirect=%2Ffr%2Fdocs%2Finstall%2F")
+
+	response = client.Get("/fr/docs/")
+	response.AssertData(t, "SubPages", []puzzleweb.PageDesc{{Name: "PageTitleInstall", Url: "/fr/docs/install"}})
+	response.AssertData(t, "Menu", []puzzleweb.PageDesc{
+		{Name: "PageTitleDocs", Url: "/fr/docs", SubPages: []puzzleweb.PageDesc{
+			{Name: "PageTitleInstall", Url: "/fr/docs/install"},
+		}},
+	})
+
+	client.Get("/changeLang?lang=en&Redirect=%2Ffr%2Fdocs%2F").AssertRedirect(t, "/en/docs/")
+
+	// the rules apply after the lang prefix, which is kept
+	response = client.Get("/fr/old-docs/install")
+	response.AssertStatus(t, http.StatusMovedPermanently)
+	if location := response.Header().Get("Location"); location != "/fr/docs/install" {
+		t.Errorf("redirected to %q", location)
+	}
+	response = client.Get("/fr/guide")
+	response.AssertStatus(t, http.StatusOK)
+	response.AssertTemplate(t, "fr/docs/install")
+	response.AssertData(t, "CurrentUrl", "/fr/docs/install/")
+}
+
+func TestSitemapParts(t *testing.T) {
+	builder := puzzlewebtest.NewSiteBuilder(t)
+	builder.AllLang = []string{"en", "fr"}
+	builder.LangInUrl = true
+	page := puzzleweb.MakePage("list")
+	widget := &listWidget{count: puzzleweb.SitemapMaxUrls/2 + 1} // one url by lang for each entry
+	page.Widget = widget
+	builder.AddPage(page)
+	site := builder.Build()
+	client := site.NewClient()
+
+	index := client.Get("/sitemap.xml").Body.String()
+	for _, part := range []string{"page=%2Flist", "page=%2Flist&amp;part=1"} {
+		if !strings.Contains(index, part+"<") {
+			t.Errorf("sitemap index without %q", part)
+		}
+	}
+	if strings.Contains(index, "part=2") {
+		t.Error("sitemap index with an empty part")
+	}
+	if client.Get("/sitemap.xml").Body.String() != index || widget.calls != 1 {
+		t.Errorf("sitemap index not cached, widget listed %d times", widget.calls)
+	}
+
+	if count := strings.Count(client.Get("/sitemap.xml?page=%2Flist").Body.String(), "<url>"); count != puzzleweb.SitemapMaxUrls {
+		t.Errorf("%d urls in the first part", count)
+	}
+	if count := strings.Count(client.Get("/sitemap.xml?page=%2Flist&part=1").Body.String(), "<url>"); count != 2 {
+		t.Errorf("%d urls in the second part", count)
+	}
+	client.Get("/sitemap.xml?page=%2Flist&part=2").AssertStatus(t, http.StatusNotFound)
+}
+
+func TestSitemapWithLangCookie(t *testing.T) {
+	builder := puzzlewebtest.NewSiteBuilder(t)
+	builder.AllLang = []string{"en", "fr"}
+	builder.AddStaticPages(adminservice.PublicGroupId, "about")
+	site := builder.Build()
+
+	// the translations share the url, so there is no alternate
+	body := site.NewClient().Get("/sitemap.xml?page=%2F").Body.String()
+	if !strings.Contains(body, "<loc>http://localhost/about</loc>") {
+		t.Errorf("page missing from the sitemap : %s", body)
+	}
+	if strings.Contains(body, "alternate") {
+		t.Errorf("alternates with the lang cookie : %s", body)
+	}
+}
+
+func TestLangFallbacks(t *testing.T) {
+	builder := puzzlewebtest.NewSiteBuilder(t)
+	builder.AllLang = []string{"en", "fr", "fr-CA", "de"}
+	builder.LangFallbacks = map[string][]string{"de": {"fr"}}
+	builder.AddStaticPages(adminservice.PublicGroupId, "about")
+	builder.Templates.Missing = []string{"fr-CA/about", "de/about"}
+	site := builder.Build()
+
+	get := func(acceptLanguage string) *puzzlewebtest.Response {
+		request := httptest.NewRequest(http.MethodGet, "/about/", nil)
+		request.Header.Set("Accept-Language", acceptLanguage)
+		return site.NewClient().Do(request)
+	}
+
+	response := get("fr-CA")
+	response.AssertData(t, "lang", "fr-CA")
+	response.AssertData(t, "LangFallbacks", []string{"fr", "en"})
+	response.AssertTemplate(t, "fr/about")
+
+	response = get("fr-BE")
+	response.AssertData(t, "lang", "fr")
+	response.AssertTemplate(t, "fr/about")
+
+	response = get("de-AT")
+	response.AssertData(t, "LangFallbacks", []string{"fr", "en"})
+	response.AssertTemplate(t, "fr/about")
+
+	get("ja").AssertTemplate(t, "about")
+}
+
+func TestLangFallbacksWithoutTemplateChecker(t *testing.T) {
+	builder := puzzlewebtest.NewSiteBuilder(t)
+	builder.AllLang = []string{"en", "fr", "fr-CA", "de"}
+	builder.LangFallbacks = map[string][]string{"de": {"fr"}}
+	builder.AddStaticPages(adminservice.PublicGroupId, "about")
+	builder.Templates.Missing = []string{"fr-CA/about", "fr/about#content"}
+	builder.HideTemplateChecker = true
+	site := builder.Build()
+
+	get := func(target string, acceptLanguage string) *puzzlewebtest.Response {
+		request := httptest.NewRequest(http.MethodGet, target, nil)
+		request.Header.Set("Accept-Language", acceptLanguage)
+		return site.NewClient().Do(request)
+	}
+
+	// the failed renders are followed by the next template of the chain
+	response := get("/about/", "fr-CA")
+	response.AssertStatus(t, http.StatusOK)
+	response.AssertTemplate(t, "fr/about")
+	if body := response.Body.String(); body != "fr/about" {
+		t.Errorf("body is %q", body)
+	}
+
+	response = get("/about/?pagePart=content", "fr-CA")
+	response.AssertStatus(t, http.StatusOK)
+	response.AssertTemplate(t, "about#content")
+	if len(response.Renders) != 3 {
+		t.Errorf("%d renders, expected 3", len(response.Renders))
+	}
+
+	get("/about/", "de").AssertTemplate(t, "de/about")
+	get("/about/", "en").AssertTemplate(t, "about")
+}
+
+func TestDateFormatting(t *testing.T) {
+	builder := puzzlewebtest.NewSiteBuilder(t)
+	builder.AllLang = []string{"en", "fr", "fr-CA"}
+	builder.DateFormats = map[string]string{"fr": "02/01/2006 15h04"}
+	site := builder.Build()
+	client := site.NewLoggedClient("alice")
+
+	getDates := func() locale.DateFormatter {
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		request.Header.Set("Accept-Language", "fr-CA")
+		dates, _ := client.Do(request).Data()[locale.DatesName].(locale.DateFormatter)
+		return dates
+	}
+
+	date := time.Date(2023, time.March, 5, 14, 30, 0, 0, time.UTC)
+	if formatted := getDates().Format(date); formatted != date.Local().Format("02/01/2006 15h04") {
+		t.Errorf("date formatted as %q with the layout of the fallback and the server timezone", formatted)
+	}
+
+	form := url.Values{"settings[lang]": {"fr-CA"}, "settings[timeZone]": {"Mars/Olympus"}}
+	client.PostForm("/settings/save", form).AssertRedirect(t, "/settings?error="+common.ErrorWrongTimeZoneKey)
+
+	form.Set("settings[timeZone]", "Asia/Tokyo")
+	client.PostForm("/settings/save", form).AssertRedirect(t, "/settings")
+	dates := getDates()
+	if dates.TimeZone != "Asia/Tokyo" || dates.Format(date) != "05/03/2023 23h30" {
+		t.Errorf("date formatted as %q in %q, expected the timezone of the user", dates.Format(date), dates.TimeZone)
+	}
+
+	client.Get("/login/logout?Redirect=/")
+	if timeZone := getDates().TimeZone; timeZone != "" {
+		t.Errorf("timezone %q kept after logout", timeZone)
+	}
+
+	if text := locale.MakeRelativeTime(3*time.Hour + 20*time.Minute).String(); text != "3 hours ago" {
+		t.Errorf("relative time is %q", text)
+	}
+	if key := locale.MakeRelativeTime(-24 * time.Hour).Key(); key != "RelativeInDays" {
+		t.Errorf("relative time key is %q", key)
+	}
+}
+
+func TestSettingsSchema(t *testing.T) {
+	builder := puzzlewebtest.NewSiteBuilder(t)
+	builder.AllLang = []string{"en", "fr"}
+	builder.SettingsSchema = []parser.SettingConfig{
+		{Key: "theme", Type: "enum", Values: []string{"light", "dark"}},
+		{Key: "newsletter", Type: "bool"},
+		{Key: "pageSize", Type: "int", Default: "20"},
+		{Key: "nickname"},
+	}
+	site := builder.Build()
+	site.Site.GetSettingsManager().SetValidator("nickname", func(nickname string, c *gin.Context) (string, error) {
+		if len(nickname) > 8 {
+			return "", errors.New(common.ErrorWrongSettingKey)
+		}
+		return nickname, nil
+	})
+	client := site.NewLoggedClient("alice")
+
+	response := client.Get("/settings/")
+	response.AssertData(t, "Settings", map[string]string{
+		"lang": "en", "timeZone": "", "theme": "light", "newsletter": "false", "pageSize": "20", "nickname": "",
+	})
+	schema, _ := response.Data()["SettingsSchema"].([]puzzleweb.SettingDesc)
+	if len(schema) != 6 || schema[0].Key != "lang" || len(schema[0].Values) != 2 || schema[5].Label != "SettingNickname" {
+		t.Errorf("unexpected schema : %v", schema)
+	}
+
+	form := url.Values{"settings[lang]": {"fr"}, "settings[theme]": {"dark"}, "settings[pageSize]": {"50"}}
+	form.Set("settings[color]", "red")
+	client.PostForm("/settings/save", form).AssertRedirect(t, "/settings?error="+common.ErrorUnknownSettingKey)
+
+	form.Del("settings[color]")
+	form.Set("settings[theme]", "blue")
+	client.PostForm("/settings/save", form).AssertRedirect(t, "/settings?error="+common.ErrorWrongSettingKey)
+
+	form.Set("settings[theme]", "dark")
+	form.Set("settings[nickname]", "too long nickname")
+	client.PostForm("/settings/save", form).AssertRedirect(t, "/settings?error="+common.ErrorWrongSettingKey)
+
+	form.Set("settings[nickname]", "ali")
+	form.Set("settings[newsletter]", "1")
+	client.PostForm("/settings/save", form).AssertRedirect(t, "/settings")
+	saved, _ := site.Settings.Store.Get(context.Background(), client.UserId)
+	if saved["theme"] != "dark" || saved["newsletter"] != "true" || saved["pageSize"] != "50" || saved["nickname"] != "ali" {
+		t.Errorf("unexpected saved settings : %v", saved)
+	}
+}
+
+func TestSettingsLangInUrl(t *testing.T) {
+	builder := puzzlewebtest.NewSiteBuilder(t)
+	builder.AllLang = []string{"en", "fr"}
+	builder.LangInUrl = true
+	site := builder.Build()
+	client := site.NewLoggedClient("alice")
+
+	response := client.PostForm("/en/settings/save", url.Values{"settings[lang]": {"fr"}})
+	response.AssertRedirect(t, "/fr/settings")
+	cookies := response.Result().Cookies()
+	if last := cookies[len(cookies)-1]; last.Name != locale.LangName || last.Value != "fr" {
+		t.Errorf("last cookie is %v", last)
+	}
+
+	form := url.Values{"settings[lang]": {"en"}, "settings[color]": {"red"}}
+	client.PostForm("/fr/settings/save", form).AssertRedirect(t, "/fr/settings?error="+common.ErrorUnknownSettingKey)
+}
+
+func TestSessionRenewal(t *testing.T) {
+	site := puzzlewebtest.NewSiteBuilder(t).Build()
+	userId := site.Logins.AddUser("alice", "secret")
+	client := site.NewClient()
+
+	form := url.Values{"Login": {"alice"}, "Password": {"secret"}, "Redirect": {"/"}, "PrevUrlWithError": {"/login?error="}}
+	plantedId := client.SessionId
+	client.PostForm("/login/submit", form).AssertRedirect(t, "/")
+	loggedId := client.SessionId
+	if loggedId == plantedId {
+		t.Fatal("session id not renewed on login")
+	}
+	if info := site.Sessions.Session(plantedId); len(info) != 0 {
+		t.Errorf("previous session not invalidated : %v", info)
+	}
+	if info := site.Sessions.Session(loggedId); info[puzzleweb.CsrfTokenName] != "" {
+		t.Error("anti-forgery token kept after login")
+	}
+	client.Get("/").AssertData(t, common.UserIdName, userId)
+
+	client.Get("/login/logout?Redirect=/").AssertRedirect(t, "/")
+	if client.SessionId == loggedId {
+		t.Error("session id not renewed on logout")
+	}
+	if calls := site.Sessions.CallsTo("Delete"); len(calls) != 2 {
+		t.Errorf("Delete called %d times, expected 2", len(calls))
+	}
+}
+
+func TestSessionRenewalOnRightsChange(t *testing.T) {
+	site := puzzlewebtest.NewSiteBuilder(t).Build()
+	bobId := site.Logins.AddUser("bob", "password")
+	client := site.NewLoggedClient("alice")
+
+	sessionId := client.SessionId
+	form := url.Values{"RoleName": {"editor"}, "Group": {adminservice.PublicName}, "actions": {adminservice.ActionAccess}}
+	client.PostForm("/admin/role/save", form).AssertRedirect(t, "/admin/role/list")
+	client.PostForm("/admin/user/save/"+strconv.FormatUint(bobId, 10), url.Values{"roles": {"editor/public"}})
+	if client.SessionId != sessionId {
+		t.Error("session id renewed without change of the connected user rights")
+	}
+
+	client.PostForm("/admin/user/save/"+strconv.FormatUint(client.UserId, 10), url.Values{"roles": {"editor/public"}})
+	if client.SessionId == sessionId {
+		t.Error("session id not renewed on change of the connected user rights")
+	}
+}
+
+func TestDatesInJson(t *testing.T) {
+	builder := puzzlewebtest.NewSiteBuilder(t)
+	builder.AllLang = []string{"en", "fr"}
+	builder.DateFormats = map[string]string{"fr": "02/01/2006 15h04"}
+	site := builder.Build()
+	date := time.Date(2023, time.March, 5, 14, 30, 0, 0, time.UTC)
+	site.Site.AddDefaultData(func(data gin.H, c *gin.Context) {
+		data["Events"] = []struct {
+			Name string `json:"name"`
+			At   time.Time
+		}{{Name: "launch", At: date}}
+	})
+	client := site.NewLoggedClient("alice")
+
+	form := url.Values{"settings[lang]": {"fr"}, "settings[timeZone]": {"Asia/Tokyo"}}
+	client.PostForm("/settings/save", form).AssertRedirect(t, "/settings")
+
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.Header.Set("Accept-Language", "fr")
+	// as sent to a remote template service
+	dataBytes, err := json.Marshal(locale.FormatDates(client.Do(request).Data()))
+	if err != nil {
+		t.Fatal("Failed to marshal data :", err)
+	}
+
+	var decoded struct {
+		Events []struct {
+			Name string `json:"name"`
+			At   locale.FormattedDate
+		}
+	}
+	if err = json.Unmarshal(dataBytes, &decoded); err != nil {
+		t.Fatal("Failed to unmarshal data :", err)
+	}
+	if len(decoded.Events) != 1 {
+		t.Fatalf("unexpected events : %s", dataBytes)
+	}
+	event := decoded.Events[0]
+	if event.Name != "launch" || event.At.Formatted != "05/03/2023 23h30" || event.At.RelativeKey != "RelativeYearsAgo" || event.At.RelativeCount < 3 {
+		t.Errorf("unexpected event : %+v", event)
+	}
+}
+
+func TestPageCache(t *testing.T) {
+	builder := puzzlewebtest.NewSiteBuilder(t)
+	builder.SiteConfig.PageCacheSize = 1 << 20
+	builder.SiteConfig.PageCacheTimeOuts = map[string]time.Duration{"token": time.Hour}
+	page := puzzleweb.MakePage("token")
+	widget := &tokenWidget{}
+	page.Widget = widget
+	builder.AddPage(page)
+	site := builder.Build()
+	first := site.NewClient()
+	second := site.NewClient()
+
+	response := first.Get("/token/")
+	response.AssertStatus(t, http.StatusOK)
+	firstToken := site.Sessions.Session(first.SessionId)[puzzleweb.CsrfTokenName]
+	if body := response.Body.String(); body != "token:"+firstToken {
+		t.Errorf("unexpected body %q", body)
+	}
+	etag := response.Header().Get("ETag")
+
+	// the cached page is written with the token of each session
+	response = second.Get("/token/")
+	secondToken := site.Sessions.Session(second.SessionId)[puzzleweb.CsrfTokenName]
+	if body := response.Body.String(); widget.renders != 1 || secondToken == firstToken || body != "token:"+secondToken {
+		t.Errorf("cache not hit (%d renders) or wrong token in %q", widget.renders, body)
+	}
+	if response.Header().Get("ETag") == etag {
+		t.Error("same ETag for the pages of two sessions")
+	}
+
+	first.Header = http.Header{"If-None-Match": {etag}}
+	response = first.Get("/token/")
+	response.AssertStatus(t, http.StatusNotModified)
+	if response.Body.Len() != 0 {
+		t.Errorf("body sent with a 304 : %q", response.Body.String())
+	}
+	first.Header = nil
+
+	site.NewLoggedClient("alice").Get("/token/")
+	if widget.renders != 2 {
+		t.Errorf("%d renders, the page of a logged user must not come from the cache", widget.renders)
+	}
+
+	first.PostForm("/token/save", nil).AssertStatus(t, http.StatusNoContent)
+	first.Get("/token/")
+	if widget.renders != 3 {
+		t.Errorf("%d renders, the cached page has not been invalidated", widget.renders)
+	}
+}
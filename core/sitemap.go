@@ -0,0 +1,307 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package puzzleweb
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	adminservice "github.com/dvaumoron/puzzleweb/admin/service"
+	"github.com/dvaumoron/puzzleweb/common"
+	"github.com/dvaumoron/puzzleweb/common/config"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	SitemapMaxUrls = 50000 // limit from the sitemap protocol
+
+	sitemapIndexTimeOut = 10 * time.Minute
+
+	sitemapPath       = "/sitemap.xml"
+	sitemapPageName   = "page"
+	sitemapPartName   = "part"
+	sitemapNamespace  = "http://www.sitemaps.org/schemas/sitemap/0.9"
+	xhtmlNamespace    = "http://www.w3.org/1999/xhtml"
+	defaultRobotsText = "User-agent: *\nDisallow: /admin/\nDisallow: /settings/\n"
+)
+
+type SitemapEntry struct {
+	Url        string            // path from the site root
+	Alternates map[string]string // path by lang, when nil the lang prefixes are used (no alternate with the lang cookie)
+}
+
+// Widget able to list its content in the sitemap, the services must be called
+// with the anonymous user (0) in order to list only public content
+// (in lang prefix mode, baseUrl starts with the prefix of the default lang).
+type SitemapWidget interface {
+	SitemapEntries(ctx context.Context, baseUrl string, allLang []string) ([]SitemapEntry, error)
+}
+
+type xmlSitemapIndex struct {
+	XMLName  xml.Name `xml:"sitemapindex"`
+	Xmlns    string   `xml:"xmlns,attr"`
+	Sitemaps []xmlLoc `xml:"sitemap"`
+}
+
+type xmlLoc struct {
+	Loc string `xml:"loc"`
+}
+
+type xmlUrlSet struct {
+	XMLName    xml.Name `xml:"urlset"`
+	Xmlns      string   `xml:"xmlns,attr"`
+	XmlnsXhtml string   `xml:"xmlns:xhtml,attr"`
+	Urls       []xmlUrl `xml:"url"`
+}
+
+type xmlUrl struct {
+	Loc   string    `xml:"loc"`
+	Links []xmlLink `xml:"xhtml:link"`
+}
+
+type xmlLink struct {
+	Rel      string `xml:"rel,attr"`
+	Hreflang string `xml:"hreflang,attr"`
+	Href     string `xml:"href,attr"`
+}
+
+type sitemapPages struct {
+	staticUrls  []string
+	widgetPages map[string]SitemapWidget
+	widgetUrls  []string // keep the walk order
+}
+
+// building the index lists the content of every widget (to count the parts), so it is done at most once by time out
+type sitemapIndexCache struct {
+	mutex  sync.Mutex
+	body   []byte
+	expire time.Time
+}
+
+// the build is done with the mutex locked, so concurrent requests wait for it instead of repeating it
+func (ic *sitemapIndexCache) load(build func() ([]byte, error)) ([]byte, error) {
+	ic.mutex.Lock()
+	defer ic.mutex.Unlock()
+
+	now := time.Now()
+	if ic.body != nil && now.Before(ic.expire) {
+		return ic.body, nil
+	}
+
+	body, err := build()
+	if err != nil {
+		return nil, err
+	}
+	ic.body, ic.expire = body, now.Add(sitemapIndexTimeOut)
+	return body, nil
+}
+
+// registered before the session middleware, crawlers do not need session
+func (site *Site) loadSitemapInto(router gin.IRouter, siteConfig config.SiteConfig) {
+	siteUrl := site.siteUrl
+
+	robotsText := siteConfig.RobotsText
+	if robotsText == "" {
+		robotsText = defaultRobotsText
+		if localesManager := site.localesManager; localesManager.GetLangInUrl() {
+			var robotsBuilder strings.Builder
+			robotsBuilder.WriteString("User-agent: *\n")
+			for _, lang := range localesManager.GetAllLang() {
+				robotsBuilder.WriteString("Disallow: /" + lang + "/admin/\nDisallow: /" + lang + "/settings/\n")
+			}
+			robotsText = robotsBuilder.String()
+		}
+	} else if robotsText[len(robotsText)-1] != '\n' {
+		robotsText += "\n"
+	}
+	robotsText += "Sitemap: " + siteUrl + sitemapPath + "\n"
+
+	router.GET("/robots.txt", func(c *gin.Context) {
+		c.String(http.StatusOK, robotsText)
+	})
+	var indexCache sitemapIndexCache
+	router.GET(sitemapPath, func(c *gin.Context) {
+		ctx := c.Request.Context()
+		var langPrefix string
+		if localesManager := site.localesManager; localesManager.GetLangInUrl() {
+			langPrefix = "/" + localesManager.GetDefaultLang()
+		}
+
+		var body []byte
+		var err error
+		if pageUrl := c.Query(sitemapPageName); pageUrl == "" {
+			body, err = indexCache.load(func() ([]byte, error) {
+				return xml.Marshal(site.makeSitemapIndex(ctx, siteUrl, site.collectSitemapPages(ctx), langPrefix))
+			})
+		} else {
+			entries, ok := site.sitemapEntries(ctx, site.collectSitemapPages(ctx), pageUrl, langPrefix)
+			part, partErr := strconv.Atoi(c.DefaultQuery(sitemapPartName, "0"))
+			urls := site.makeUrls(siteUrl, entries)
+			start := part * SitemapMaxUrls
+			if !ok || partErr != nil || part < 0 || (part != 0 && start >= len(urls)) {
+				c.AbortWithStatus(http.StatusNotFound)
+				return
+			}
+			body, err = xml.Marshal(xmlUrlSet{
+				Xmlns: sitemapNamespace, XmlnsXhtml: xhtmlNamespace, Urls: urls[start:min(start+SitemapMaxUrls, len(urls))],
+			})
+		}
+		if err != nil {
+			site.loggerGetter.Logger(ctx).Error("Failed to marshal sitemap", zap.Error(err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		c.Data(http.StatusOK, "application/xml; charset=utf-8", append([]byte(xml.Header), body...))
+	})
+}
+
+func makeSiteUrl(siteConfig config.SiteConfig) string {
+	scheme, defaultPort := "http://", ":80"
+	if siteConfig.CertPath != "" {
+		scheme, defaultPort = "https://", ":443"
+	}
+	siteUrl := scheme + siteConfig.Domain
+	if port := common.CheckPort(siteConfig.Port); port != defaultPort {
+		siteUrl += port
+	}
+	return siteUrl
+}
+
+func (site *Site) collectSitemapPages(ctx context.Context) sitemapPages {
+	pages := sitemapPages{widgetPages: map[string]SitemapWidget{}}
+	site.walkSitemap(ctx, site.root, "/", &pages)
+	return pages
+}
+
+// hidden pages are skipped with their sub pages, like the pages forbidden to anonymous users
+func (site *Site) walkSitemap(ctx context.Context, page Page, pageUrl string, pages *sitemapPages) {
+	accessible := site.authService.AuthQuery(ctx, 0, page.GroupId, adminservice.ActionAccess) == nil
+	switch widget := page.Widget.(type) {
+	case *staticWidget:
+		if accessible {
+			pages.staticUrls = append(pages.staticUrls, pageUrl)
+		}
+
+		baseUrl := pageUrl
+		if baseUrl[len(baseUrl)-1] != '/' {
+			baseUrl += "/"
+		}
+		for _, subPage := range widget.subPages {
+			if subPage.visible {
+				site.walkSitemap(ctx, subPage, baseUrl+subPage.name, pages)
+			}
+		}
+	case SitemapWidget:
+		if accessible {
+			pages.widgetPages[pageUrl] = widget
+			pages.widgetUrls = append(pages.widgetUrls, pageUrl)
+		}
+	}
+}
+
+// the entries of the static pages with "/" as pageUrl, false when pageUrl is unknown
+func (site *Site) sitemapEntries(ctx context.Context, pages sitemapPages, pageUrl string, langPrefix string) ([]SitemapEntry, bool) {
+	if pageUrl == "/" {
+		entries := make([]SitemapEntry, 0, len(pages.staticUrls))
+		for _, staticUrl := range pages.staticUrls {
+			entries = append(entries, SitemapEntry{Url: langPrefix + staticUrl})
+		}
+		return entries, true
+	}
+
+	widget, ok := pages.widgetPages[pageUrl]
+	if !ok {
+		return nil, false
+	}
+
+	entries, err := widget.SitemapEntries(ctx, langPrefix+pageUrl+"/", site.localesManager.GetAllLang())
+	if err != nil {
+		// not authorized for anonymous is an expected case
+		site.loggerGetter.Logger(ctx).Info("Failed to list widget content", zap.String(sitemapPageName, pageUrl), zap.Error(err))
+	}
+	return entries, true
+}
+
+// the urls are counted (with one by lang in lang prefix mode) to split
+// the sitemaps exceeding SitemapMaxUrls in several parts
+func (site *Site) makeSitemapIndex(ctx context.Context, siteUrl string, pages sitemapPages, langPrefix string) xmlSitemapIndex {
+	pageUrls := append([]string{"/"}, pages.widgetUrls...)
+	sitemaps := make([]xmlLoc, 0, len(pageUrls))
+	for _, pageUrl := range pageUrls {
+		entries, _ := site.sitemapEntries(ctx, pages, pageUrl, langPrefix)
+		urlCount := len(site.makeUrls(siteUrl, entries))
+		for part := 0; part == 0 || part*SitemapMaxUrls < urlCount; part++ {
+			sitemaps = append(sitemaps, xmlLoc{Loc: makeChildSitemapUrl(siteUrl, pageUrl, part)})
+		}
+	}
+	return xmlSitemapIndex{Xmlns: sitemapNamespace, Sitemaps: sitemaps}
+}
+
+func makeChildSitemapUrl(siteUrl string, pageUrl string, part int) string {
+	query := url.Values{sitemapPageName: {pageUrl}}
+	if part != 0 {
+		query.Set(sitemapPartName, strconv.Itoa(part))
+	}
+	return siteUrl + sitemapPath + "?" + query.Encode()
+}
+
+func (site *Site) makeUrls(siteUrl string, entries []SitemapEntry) []xmlUrl {
+	localesManager := site.localesManager
+	allLang := localesManager.GetAllLang()
+	multipleLang := localesManager.GetMultipleLang()
+	langInUrl := localesManager.GetLangInUrl()
+	urls := make([]xmlUrl, 0, len(entries))
+	for _, entry := range entries {
+		alternates := entry.Alternates
+		locs := []string{entry.Url}
+		// with the lang cookie, all the translations share the url (so there is no alternate)
+		if alternates == nil && multipleLang && langInUrl {
+			// each translation has its own url
+			alternates = make(map[string]string, len(allLang))
+			locs = make([]string, 0, len(allLang))
+			for _, lang := range allLang {
+				langUrl := ReplaceLangPrefix(entry.Url, lang)
+				alternates[lang] = langUrl
+				locs = append(locs, langUrl)
+			}
+		}
+
+		var links []xmlLink
+		if len(alternates) != 0 {
+			links = make([]xmlLink, 0, len(alternates))
+			// follow the declaration order of locales
+			for _, lang := range allLang {
+				if href, ok := alternates[lang]; ok {
+					links = append(links, xmlLink{Rel: "alternate", Hreflang: lang, Href: siteUrl + href})
+				}
+			}
+		}
+		for _, loc := range locs {
+			urls = append(urls, xmlUrl{Loc: siteUrl + loc, Links: links})
+		}
+	}
+	return urls
+}
@@ -0,0 +1,175 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package puzzleweb
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dvaumoron/puzzleweb/common/log"
+	"go.uber.org/zap"
+)
+
+const certificateCheckInterval = 5 * time.Second
+
+var errNoClientCA = errors.New("no certificate found in client CA file")
+
+// reload certificate files from disk when their modification time change
+type certificateLoader struct {
+	loggerGetter log.LoggerGetter
+	certPath     string
+	keyPath      string
+	clientCAPath string
+
+	mutex       sync.Mutex
+	lastCheck   time.Time
+	modTimes    [3]time.Time
+	certificate *tls.Certificate
+	clientCAs   *x509.CertPool
+}
+
+func newCertificateLoader(loggerGetter log.LoggerGetter, certPath string, keyPath string, clientCAPath string) (*certificateLoader, error) {
+	loader := &certificateLoader{
+		loggerGetter: loggerGetter, certPath: certPath, keyPath: keyPath, clientCAPath: clientCAPath,
+	}
+	if err := loader.load(loader.readModTimes()); err != nil {
+		loggerGetter.Logger(context.Background()).Error("Failed to load TLS certificate", zap.Error(err))
+		return nil, err
+	}
+	return loader, nil
+}
+
+func (l *certificateLoader) tlsConfig() *tls.Config {
+	config := &tls.Config{MinVersion: tls.VersionTLS12, GetCertificate: l.getCertificate}
+	if l.clientCAPath != "" {
+		config.GetConfigForClient = l.getConfigForClient
+	}
+	return config
+}
+
+func (l *certificateLoader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.checkReload()
+	return l.certificate, nil
+}
+
+// used only with mutual TLS, allow to reload the client CA pool
+func (l *certificateLoader) getConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.checkReload()
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12, Certificates: []tls.Certificate{*l.certificate},
+		ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: l.clientCAs, NextProtos: []string{"h2", "http/1.1"},
+	}, nil
+}
+
+// must be called with the mutex locked
+func (l *certificateLoader) checkReload() {
+	now := time.Now()
+	if now.Sub(l.lastCheck) < certificateCheckInterval {
+		return
+	}
+	l.lastCheck = now
+
+	if modTimes := l.readModTimes(); modTimes != l.modTimes {
+		logger := l.loggerGetter.Logger(context.Background())
+		if err := l.load(modTimes); err == nil {
+			logger.Info("TLS certificate reloaded")
+		} else {
+			// keep serving with the previous certificate
+			logger.Warn("Failed to reload TLS certificate", zap.Error(err))
+		}
+	}
+}
+
+func (l *certificateLoader) readModTimes() [3]time.Time {
+	var modTimes [3]time.Time
+	for index, path := range [3]string{l.certPath, l.keyPath, l.clientCAPath} {
+		if path != "" {
+			if info, err := os.Stat(path); err == nil {
+				modTimes[index] = info.ModTime()
+			}
+		}
+	}
+	return modTimes
+}
+
+func (l *certificateLoader) load(modTimes [3]time.Time) error {
+	certificate, err := tls.LoadX509KeyPair(l.certPath, l.keyPath)
+	if err != nil {
+		return err
+	}
+
+	var clientCAs *x509.CertPool
+	if l.clientCAPath != "" {
+		caData, err := os.ReadFile(l.clientCAPath)
+		if err != nil {
+			return err
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(caData) {
+			return errNoClientCA
+		}
+	}
+
+	l.certificate = &certificate
+	l.clientCAs = clientCAs
+	l.modTimes = modTimes
+	return nil
+}
+
+func makeHttpsRedirecter(domain string, httpsPort string) http.HandlerFunc {
+	var hostBuilder strings.Builder
+	hostBuilder.WriteString("https://")
+	hostBuilder.WriteString(domain)
+	if httpsPort != ":443" {
+		hostBuilder.WriteString(httpsPort)
+	}
+	host := hostBuilder.String()
+	return func(w http.ResponseWriter, r *http.Request) {
+		// use the configured domain rather than the Host header
+		http.Redirect(w, r, host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	}
+}
+
+// like makeHttpsRedirecter, with the domain chosen according to the request
+func makeHostHttpsRedirecter(domainGetter func(*http.Request) string, httpsPort string) http.HandlerFunc {
+	if httpsPort == ":443" {
+		httpsPort = ""
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		var targetBuilder strings.Builder
+		targetBuilder.WriteString("https://")
+		targetBuilder.WriteString(domainGetter(r))
+		targetBuilder.WriteString(httpsPort)
+		targetBuilder.WriteString(r.URL.RequestURI())
+		http.Redirect(w, r, targetBuilder.String(), http.StatusMovedPermanently)
+	}
+}
@@ -0,0 +1,113 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package puzzleweb
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/dvaumoron/puzzleweb/common/log"
+	"go.uber.org/zap"
+)
+
+var errNoDomain = errors.New("a virtual host needs a domain")
+
+type virtualHost struct {
+	domain  string
+	handler http.Handler
+}
+
+// Dispatch the requests between sites according to the Host header.
+type hostRouter struct {
+	hosts       map[string]virtualHost
+	defaultHost virtualHost // serve unknown hosts
+}
+
+func (r hostRouter) addSite(logger log.Logger, siteAndConfig SiteAndConfig) (virtualHost, error) {
+	siteConfig := siteAndConfig.Config
+	domain := normalizeHost(siteConfig.Domain)
+	if domain == "" {
+		return virtualHost{}, errNoDomain
+	}
+
+	vh := virtualHost{domain: domain, handler: siteAndConfig.Site.initEngine(siteConfig)}
+	for _, host := range append([]string{domain}, siteConfig.Aliases...) {
+		host = normalizeHost(host)
+		if _, ok := r.hosts[host]; ok {
+			logger.Error("Host declared by several sites", zap.String("host", host))
+			return virtualHost{}, errors.New("duplicate virtual host " + host)
+		}
+		r.hosts[host] = vh
+	}
+	return vh, nil
+}
+
+func (r hostRouter) getHost(req *http.Request) virtualHost {
+	if vh, ok := r.hosts[normalizeHost(req.Host)]; ok {
+		return vh
+	}
+	return r.defaultHost
+}
+
+func (r hostRouter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.getHost(req).handler.ServeHTTP(w, req)
+}
+
+func (r hostRouter) getDomain(req *http.Request) string {
+	return r.getHost(req).domain
+}
+
+// remove the port and the final dot of a fully qualified name
+func normalizeHost(host string) string {
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
+
+// Serve all the sites on the same listener, each request is dispatched to the site whose Domain (or one of Aliases)
+// matches its Host header, and defaultSite serves unknown hosts (port, TLS and shutdown settings come from its configuration,
+// so the certificate has to cover all the domains).
+func RunVirtualHosts(defaultSite SiteAndConfig, sites ...SiteAndConfig) error {
+	loggerGetter := defaultSite.Site.loggerGetter
+	logger := loggerGetter.Logger(context.Background())
+	router := hostRouter{hosts: make(map[string]virtualHost, len(sites)+1)}
+	defaultHost, err := router.addSite(logger, defaultSite)
+	if err != nil {
+		return err
+	}
+	router.defaultHost = defaultHost
+
+	for _, siteAndConfig := range sites {
+		if _, err = router.addSite(logger, siteAndConfig); err != nil {
+			return err
+		}
+	}
+
+	servers, err := makeServers(loggerGetter, defaultSite.Config, router, func(httpsAddr string) http.Handler {
+		return makeHostHttpsRedirecter(router.getDomain, httpsAddr)
+	}, listenAndServe)
+	if err != nil {
+		return err
+	}
+	return runServers(logger, servers...)
+}
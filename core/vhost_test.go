@@ -0,0 +1,81 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package puzzleweb
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func makeTestHost(domain string) virtualHost {
+	return virtualHost{domain: domain, handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(domain))
+	})}
+}
+
+func TestHostRouter(t *testing.T) {
+	defaultHost := makeTestHost("example.com")
+	otherHost := makeTestHost("other.org")
+	router := hostRouter{
+		hosts:       map[string]virtualHost{"example.com": defaultHost, "other.org": otherHost, "www.other.org": otherHost},
+		defaultHost: defaultHost,
+	}
+
+	cases := []struct {
+		host     string
+		expected string
+	}{
+		{host: "example.com", expected: "example.com"},
+		{host: "other.org", expected: "other.org"},
+		{host: "www.other.org", expected: "other.org"},
+		{host: "Other.ORG:8080", expected: "other.org"},
+		{host: "other.org.", expected: "other.org"},
+		{host: "unknown.net", expected: "example.com"},
+		{host: "", expected: "example.com"},
+	}
+	for _, tc := range cases {
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		request.Host = tc.host
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		if body := recorder.Body.String(); body != tc.expected {
+			t.Errorf("host %q served by %q, expected %q", tc.host, body, tc.expected)
+		}
+		if domain := router.getDomain(request); domain != tc.expected {
+			t.Errorf("host %q gives the domain %q, expected %q", tc.host, domain, tc.expected)
+		}
+	}
+}
+
+func TestNormalizeHost(t *testing.T) {
+	cases := map[string]string{
+		"example.com":       "example.com",
+		"EXAMPLE.com:443":   "example.com",
+		"example.com.":      "example.com",
+		"[::1]:8080":        "::1",
+		"localhost:":        "localhost",
+		"sub.Example.Com.:": "sub.example.com",
+	}
+	for host, expected := range cases {
+		if normalized := normalizeHost(host); normalized != expected {
+			t.Errorf("%q normalized to %q, expected %q", host, normalized, expected)
+		}
+	}
+}
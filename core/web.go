@@ -22,6 +22,9 @@ import (
 	"context"
 	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	adminservice "github.com/dvaumoron/puzzleweb/admin/service"
@@ -30,7 +33,9 @@ import (
 	"github.com/dvaumoron/puzzleweb/common/config/parser"
 	"github.com/dvaumoron/puzzleweb/common/log"
 	"github.com/dvaumoron/puzzleweb/locale"
+	"github.com/dvaumoron/puzzleweb/ratelimit"
 	"github.com/dvaumoron/puzzleweb/templates"
+	templateservice "github.com/dvaumoron/puzzleweb/templates/service"
 	"github.com/gin-gonic/gin"
 	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 	"go.uber.org/zap"
@@ -40,13 +45,22 @@ import (
 const siteName = "Site"
 const unknownUserKey = "ErrorUnknownUser"
 
+var errUnknownUser = common.NewStatusError(http.StatusForbidden, unknownUserKey)
+
 type Site struct {
-	loggerGetter   log.LoggerGetter
-	localesManager common.LocalesManager
-	authService    adminservice.AuthService
-	timeOut        time.Duration
-	root           Page
-	adders         []common.DataAdder
+	loggerGetter    log.LoggerGetter
+	localesManager  common.LocalesManager
+	settingsManager *SettingsManager
+	authService     adminservice.AuthService
+	timeOut         time.Duration
+	root            Page
+	adders          []common.DataAdder
+	rateLimiter     *ratelimit.Limiter
+	pageCache       *pageCache  // nil when disabled
+	redirecter      *redirecter // nil without redirect
+	siteUrl         string      // scheme, domain and port
+
+	templateChecker templateservice.TemplateChecker // nil when the template service can not check
 }
 
 func NewSite(configExtracter config.BaseConfigExtracter, localesManager common.LocalesManager, settingsManager *SettingsManager) *Site {
@@ -58,7 +72,7 @@ func NewSite(configExtracter config.BaseConfigExtracter, localesManager common.L
 	root.AddSubPage(newProfilePage(configExtracter.ExtractProfileConfig()))
 
 	return &Site{
-		loggerGetter: configExtracter.GetLoggerGetter(), localesManager: localesManager,
+		loggerGetter: configExtracter.GetLoggerGetter(), localesManager: localesManager, settingsManager: settingsManager,
 		authService: adminConfig.Service, timeOut: configExtracter.GetServiceTimeOut(), root: root,
 	}
 }
@@ -68,6 +82,10 @@ func (site *Site) AddPage(page Page) {
 }
 
 func (site *Site) AddStaticPages(pageGroup parser.StaticPagesConfig) bool {
+	if location, ok := findUnknownMenuLocation(pageGroup); ok {
+		site.loggerGetter.Logger(context.Background()).Error("Menu location not declared in the static pages", zap.String("location", location))
+		return false
+	}
 	return site.root.AddStaticPages(pageGroup)
 }
 
@@ -79,6 +97,11 @@ func (site *Site) GetPageWithPath(path string) (Page, bool) {
 	return site.root.GetSubPageWithPath(path)
 }
 
+// Allow to declare settings and their validators in code.
+func (site *Site) GetSettingsManager() *SettingsManager {
+	return site.settingsManager
+}
+
 func (site *Site) AddDefaultData(adder common.DataAdder) {
 	site.adders = append(site.adders, adder)
 }
@@ -91,9 +114,25 @@ func (site *Site) manageTimeOut(c *gin.Context) {
 	c.Next()
 }
 
-func (site *Site) initEngine(siteConfig config.SiteConfig) *gin.Engine {
+func (site *Site) initEngine(siteConfig config.SiteConfig) http.Handler {
+	site.rateLimiter = siteConfig.RateLimiter
+	site.siteUrl = makeSiteUrl(siteConfig)
+	site.templateChecker, _ = siteConfig.TemplateService.(templateservice.TemplateChecker)
+	if siteConfig.PageCacheSize != 0 {
+		site.pageCache = newPageCache(siteConfig.PageCacheSize, siteConfig.PageCacheTimeOuts)
+	}
+
 	engine := gin.New()
-	engine.Use(site.manageTimeOut, otelgin.Middleware(config.WebKey), gin.Recovery())
+	// the client IP is used as rate limit key, so it must not come from an untrusted header
+	if err := engine.SetTrustedProxies(siteConfig.TrustedProxies); err != nil {
+		site.loggerGetter.Logger(context.Background()).Error("Invalid trusted proxies, none are trusted", zap.Strings("trustedProxies", siteConfig.TrustedProxies), zap.Error(err))
+		engine.SetTrustedProxies(nil)
+	}
+	engine.Use(site.manageTimeOut, otelgin.Middleware(config.WebKey), newRequestRecorder(), gin.Recovery())
+	if siteConfig.Compression {
+		// before the static routes, css and js are compressed too
+		engine.Use(newCompressor(siteConfig.CompressionMinSize).compress)
+	}
 
 	if memorySize := siteConfig.MaxMultipartMemory; memorySize != 0 {
 		engine.MaxMultipartMemory = memorySize
@@ -104,9 +143,18 @@ func (site *Site) initEngine(siteConfig config.SiteConfig) *gin.Engine {
 	engine.StaticFS("/static", siteConfig.StaticFileSystem)
 	engine.StaticFileFS(config.DefaultFavicon, siteConfig.FaviconPath, siteConfig.StaticFileSystem)
 
+	manager := makeSessionManager(siteConfig.ExtractSessionConfig())
+	site.loadHealthInto(engine, siteConfig, manager)
+	site.loadSitemapInto(engine, siteConfig)
+
+	redirectValidator := common.NewRedirectValidator(site.loggerGetter, siteConfig.AllowedRedirectHosts)
 	engine.Use(func(c *gin.Context) {
 		c.Set(siteName, site)
-	}, makeSessionManager(siteConfig.ExtractSessionConfig()).manage)
+	}, redirectValidator.Manage, manager.manage, checkCsrf)
+
+	if metricsHandler := siteConfig.MetricsHandler; metricsHandler != nil {
+		engine.GET("/metrics", site.createMetricsHandler(metricsHandler))
+	}
 
 	if localesManager := site.localesManager; localesManager.GetMultipleLang() {
 		engine.GET("/changeLang", common.CreateRedirect(changeLangRedirecter))
@@ -117,17 +165,82 @@ func (site *Site) initEngine(siteConfig config.SiteConfig) *gin.Engine {
 		}
 	}
 
-	site.root.Widget.LoadInto(engine)
-	engine.NoRoute(common.CreateRedirectString(siteConfig.Page404Url))
-	return engine
+	var notFoundHandlers []gin.HandlerFunc
+	r := site.redirecter
+	if r != nil {
+		notFoundHandlers = append(notFoundHandlers, r.redirect)
+	}
+	if localesManager := site.localesManager; localesManager.GetLangInUrl() {
+		for _, lang := range localesManager.GetAllLang() {
+			site.root.Widget.LoadInto(engine.Group("/"+lang, setUrlLang(lang)))
+		}
+		notFoundHandlers = append(notFoundHandlers, site.redirectToLang)
+	} else {
+		site.root.Widget.LoadInto(engine)
+	}
+	engine.NoRoute(append(notFoundHandlers, notFoundHandler)...)
+
+	if r != nil {
+		return r.wrap(engine.Handler(), site.isPagePath)
+	}
+	return engine.Handler()
+}
+
+// Handler serving the site without starting servers (like in tests).
+func (site *Site) Handler(siteConfig config.SiteConfig) http.Handler {
+	return site.initEngine(siteConfig)
 }
 
 func (site *Site) Run(siteConfig config.SiteConfig) error {
-	return site.initEngine(siteConfig).Run(common.CheckPort(siteConfig.Port))
+	return site.runServers(siteConfig, listenAndServe)
 }
 
 func (site *Site) RunListener(siteConfig config.SiteConfig, listener net.Listener) error {
-	return site.initEngine(siteConfig).RunListener(listener)
+	return site.runServers(siteConfig, func(server *http.Server) error {
+		if server.TLSConfig != nil {
+			return server.ServeTLS(listener, "", "")
+		}
+		return server.Serve(listener)
+	})
+}
+
+func (site *Site) runServers(siteConfig config.SiteConfig, serve func(*http.Server) error) error {
+	servers, err := site.makeServers(siteConfig, serve)
+	if err != nil {
+		return err
+	}
+	return runServers(site.loggerGetter.Logger(context.Background()), servers...)
+}
+
+func (site *Site) makeServers(siteConfig config.SiteConfig, serve func(*http.Server) error) ([]drainableServer, error) {
+	return makeServers(site.loggerGetter, siteConfig, site.initEngine(siteConfig), func(httpsAddr string) http.Handler {
+		return makeHttpsRedirecter(siteConfig.Domain, httpsAddr)
+	}, serve)
+}
+
+// the port, TLS and shutdown settings are read from siteConfig
+func makeServers(loggerGetter log.LoggerGetter, siteConfig config.SiteConfig, handler http.Handler, redirecterMaker func(string) http.Handler, serve func(*http.Server) error) ([]drainableServer, error) {
+	server := &http.Server{Addr: common.CheckPort(siteConfig.Port), Handler: handler}
+	servers := []drainableServer{{server: server, serve: serve, timeOut: siteConfig.ShutdownTimeOut}}
+	if siteConfig.CertPath == "" {
+		return servers, nil
+	}
+
+	loader, err := newCertificateLoader(loggerGetter, siteConfig.CertPath, siteConfig.KeyPath, siteConfig.ClientCAPath)
+	if err != nil {
+		return nil, err
+	}
+	server.TLSConfig = loader.tlsConfig()
+
+	if redirectPort := siteConfig.RedirectPort; redirectPort != "" {
+		redirectServer := &http.Server{
+			Addr: common.CheckPort(redirectPort), Handler: redirecterMaker(server.Addr),
+		}
+		servers = append(servers, drainableServer{
+			server: redirectServer, serve: listenAndServe, timeOut: siteConfig.ShutdownTimeOut,
+		})
+	}
+	return servers, nil
 }
 
 type SiteAndConfig struct {
@@ -136,19 +249,71 @@ type SiteAndConfig struct {
 }
 
 func Run(ginLogger *zap.Logger, sites ...SiteAndConfig) error {
-	var g errgroup.Group
+	servers := make([]drainableServer, 0, len(sites))
 	for _, siteAndConfig := range sites {
-		port := common.CheckPort(siteAndConfig.Config.Port)
-		handler := siteAndConfig.Site.initEngine(siteAndConfig.Config).Handler()
+		siteServers, err := siteAndConfig.Site.makeServers(siteAndConfig.Config, listenAndServe)
+		if err != nil {
+			return err
+		}
+		servers = append(servers, siteServers...)
+	}
+	return runServers(ginLogger, servers...)
+}
+
+func listenAndServe(server *http.Server) error {
+	if server.TLSConfig != nil {
+		return server.ListenAndServeTLS("", "")
+	}
+	return server.ListenAndServe()
+}
+
+type drainableServer struct {
+	server  *http.Server
+	serve   func(*http.Server) error
+	timeOut time.Duration
+}
+
+// Serve until the first failure or the reception of SIGINT or SIGTERM,
+// then drain in-flight requests of every server before returning.
+func runServers(logger log.Logger, servers ...drainableServer) error {
+	signalCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	g, ctx := errgroup.WithContext(signalCtx)
+	for _, ds := range servers {
+		ds := ds
 		g.Go(func() error {
-			server := &http.Server{Addr: port, Handler: handler}
-			return server.ListenAndServe()
+			if err := ds.serve(ds.server); err != http.ErrServerClosed {
+				return err
+			}
+			return nil
+		})
+		g.Go(func() error {
+			<-ctx.Done()
+			logger.Info("Shutting down server", zap.String("addr", ds.server.Addr))
+			return ds.drain()
 		})
 	}
 	return g.Wait()
 }
 
+func (ds drainableServer) drain() error {
+	ctx := context.Background()
+	if timeOut := ds.timeOut; timeOut != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeOut)
+		defer cancel()
+	}
+	return ds.server.Shutdown(ctx)
+}
+
 func changeLangRedirecter(c *gin.Context) string {
-	getSite(c).localesManager.SetLangCookie(c.Query(locale.LangName), c)
-	return c.Query(common.RedirectName)
+	site := getSite(c)
+	localesManager := site.localesManager
+	lang := localesManager.SetLangCookie(c.Query(locale.LangName), c)
+	target := c.Query(common.RedirectName)
+	if localesManager.GetLangInUrl() {
+		target = site.changeLangPrefix(target, lang)
+	}
+	return target
 }
@@ -39,15 +39,14 @@ type forumClient struct {
 	grpcclient.Client
 	forumId        uint64
 	groupId        uint64
-	dateFormat     string
 	authService    adminservice.AuthService
 	profileService profileservice.ProfileService
 	loggerGetter   log.LoggerGetter
 }
 
-func New(serviceAddr string, dialOptions []grpc.DialOption, forumId uint64, groupId uint64, dateFormat string, authService adminservice.AuthService, profileService profileservice.ProfileService, loggerGetter log.LoggerGetter) forumservice.FullForumService {
+func New(serviceAddr string, dialOptions []grpc.DialOption, forumId uint64, groupId uint64, authService adminservice.AuthService, profileService profileservice.ProfileService, loggerGetter log.LoggerGetter) forumservice.FullForumService {
 	return forumClient{
-		Client: grpcclient.Make(serviceAddr, dialOptions...), forumId: forumId, groupId: groupId, dateFormat: dateFormat,
+		Client: grpcclient.Make(serviceAddr, dialOptions...), forumId: forumId, groupId: groupId,
 		authService: authService, profileService: profileService, loggerGetter: loggerGetter,
 	}
 }
@@ -210,9 +209,9 @@ func (client forumClient) GetThread(ctx context.Context, userId uint64, threadId
 		return 0, forumservice.ForumContent{}, nil, err
 	}
 
-	thread := convertContent(response, users[threadCreatorId], client.dateFormat)
+	thread := convertContent(response, users[threadCreatorId])
 	slices.SortFunc(list, cmpContentAsc)
-	messages := convertContents(list, users, client.dateFormat)
+	messages := convertContents(list, users)
 	return response2.Total, thread, messages, nil
 }
 
@@ -246,7 +245,7 @@ func (client forumClient) GetThreads(ctx context.Context, userId uint64, start u
 		return 0, nil, err
 	}
 	slices.SortFunc(list, cmpContentDesc)
-	return total, convertContents(list, users, client.dateFormat), nil
+	return total, convertContents(list, users), nil
 }
 
 func (client forumClient) GetCommentThread(ctx context.Context, userId uint64, elemTitle string, start uint64, end uint64) (uint64, []forumservice.ForumContent, error) {
@@ -290,7 +289,7 @@ func (client forumClient) GetCommentThread(ctx context.Context, userId uint64, e
 		return 0, nil, err
 	}
 	slices.SortFunc(list, cmpContentAsc)
-	return total, convertContents(list, users, client.dateFormat), nil
+	return total, convertContents(list, users), nil
 }
 
 func (client forumClient) DeleteThread(ctx context.Context, userId uint64, threadId uint64) error {
@@ -425,18 +424,17 @@ func deleteMessage(forumClient pb.ForumClient, ctx context.Context, request *pb.
 	return forumClient.DeleteMessage(ctx, request)
 }
 
-func convertContents(list []*pb.Content, users map[uint64]profileservice.UserProfile, dateFormat string) []forumservice.ForumContent {
+func convertContents(list []*pb.Content, users map[uint64]profileservice.UserProfile) []forumservice.ForumContent {
 	contents := make([]forumservice.ForumContent, 0, len(list))
 	for _, content := range list {
-		contents = append(contents, convertContent(content, users[content.UserId], dateFormat))
+		contents = append(contents, convertContent(content, users[content.UserId]))
 	}
 	return contents
 }
 
-func convertContent(content *pb.Content, creator profileservice.UserProfile, dateFormat string) forumservice.ForumContent {
-	createdAt := time.Unix(content.CreatedAt, 0)
+func convertContent(content *pb.Content, creator profileservice.UserProfile) forumservice.ForumContent {
 	return forumservice.ForumContent{
-		Id: content.Id, Creator: creator, Date: createdAt.Format(dateFormat), Text: content.Text,
+		Id: content.Id, Creator: creator, Date: time.Unix(content.CreatedAt, 0), Text: content.Text,
 	}
 }
 
@@ -19,6 +19,7 @@
 package forum
 
 import (
+	"context"
 	"errors"
 	"strconv"
 	"strings"
@@ -26,6 +27,8 @@ import (
 	"github.com/dvaumoron/puzzleweb/common"
 	"github.com/dvaumoron/puzzleweb/common/config"
 	puzzleweb "github.com/dvaumoron/puzzleweb/core"
+	forumservice "github.com/dvaumoron/puzzleweb/forum/service"
+	"github.com/dvaumoron/puzzleweb/ratelimit"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
@@ -40,23 +43,41 @@ var errEmptyMessage = errors.New(emptyMessage)
 
 // TODO preview && markdown ?
 type forumWidget struct {
+	forumService         forumservice.ForumService
 	listThreadHandler    gin.HandlerFunc
 	createThreadHandler  gin.HandlerFunc
 	saveThreadHandler    gin.HandlerFunc
+	confirmDeleteThread  gin.HandlerFunc
 	deleteThreadHandler  gin.HandlerFunc
 	viewThreadHandler    gin.HandlerFunc
 	saveMessageHandler   gin.HandlerFunc
+	confirmDeleteMessage gin.HandlerFunc
 	deleteMessageHandler gin.HandlerFunc
 }
 
 func (w forumWidget) LoadInto(router gin.IRouter) {
-	router.GET("/", w.listThreadHandler)
+	cachePage := puzzleweb.CachePage(config.ForumPageKind)
+	router.GET("/", cachePage, w.listThreadHandler)
 	router.GET("/create", w.createThreadHandler)
-	router.POST("/save", w.saveThreadHandler)
-	router.GET("/delete/:threadId", w.deleteThreadHandler)
-	router.GET("/view/:threadId", w.viewThreadHandler)
-	router.POST("/message/save/:threadId", w.saveMessageHandler)
-	router.GET("/message/delete/:threadId/:messageId", w.deleteMessageHandler)
+	router.POST("/save", puzzleweb.RateLimit(ratelimit.ContentClass), w.saveThreadHandler)
+	puzzleweb.AddDeleteRoutes(router, "/delete/:threadId", w.confirmDeleteThread, w.deleteThreadHandler)
+	router.GET("/view/:threadId", cachePage, w.viewThreadHandler)
+	router.POST("/message/save/:threadId", puzzleweb.RateLimit(ratelimit.ContentClass), w.saveMessageHandler)
+	puzzleweb.AddDeleteRoutes(router, "/message/delete/:threadId/:messageId", w.confirmDeleteMessage, w.deleteMessageHandler)
+}
+
+func (w forumWidget) SitemapEntries(ctx context.Context, baseUrl string, allLang []string) ([]puzzleweb.SitemapEntry, error) {
+	_, threads, err := w.forumService.GetThreads(ctx, 0, 0, puzzleweb.SitemapMaxUrls-1, "")
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]puzzleweb.SitemapEntry, 0, len(threads)+1)
+	entries = append(entries, puzzleweb.SitemapEntry{Url: baseUrl})
+	for _, thread := range threads {
+		entries = append(entries, puzzleweb.SitemapEntry{Url: threadUrlBuilder(baseUrl, thread.Id).String()})
+	}
+	return entries, nil
 }
 
 func MakeForumPage(forumName string, forumConfig config.ForumConfig) puzzleweb.Page {
@@ -90,6 +111,7 @@ func MakeForumPage(forumName string, forumConfig config.ForumConfig) puzzleweb.P
 
 	p := puzzleweb.MakePage(forumName)
 	p.Widget = forumWidget{
+		forumService: forumService,
 		listThreadHandler: puzzleweb.CreateTemplate(func(data gin.H, c *gin.Context) (string, string) {
 			ctx := c.Request.Context()
 			userId, _ := data[common.UserIdName].(uint64)
@@ -98,7 +120,7 @@ func MakeForumPage(forumName string, forumConfig config.ForumConfig) puzzleweb.P
 
 			total, threads, err := forumService.GetThreads(ctx, userId, start, end, filter)
 			if err != nil {
-				return "", common.DefaultErrorRedirect(puzzleweb.GetLogger(c), err.Error())
+				return common.ErrorPage(c, err)
 			}
 
 			common.InitPagination(data, filter, pageNumber, end, total)
@@ -128,7 +150,13 @@ func MakeForumPage(forumName string, forumConfig config.ForumConfig) puzzleweb.P
 			if err != nil {
 				return common.DefaultErrorRedirect(logger, err.Error())
 			}
-			return threadUrlBuilder(common.GetBaseUrl(1, c), threadId).String()
+
+			baseUrl := common.GetBaseUrl(1, c)
+			puzzleweb.InvalidatePages(c, baseUrl)
+			return threadUrlBuilder(baseUrl, threadId).String()
+		}),
+		confirmDeleteThread: puzzleweb.CreateConfirmTemplate("ConfirmDeleteThread", func(c *gin.Context) string {
+			return common.GetBaseUrl(2, c) + "view/" + c.Param(threadIdName)
 		}),
 		deleteThreadHandler: common.CreateRedirect(func(c *gin.Context) string {
 			logger := puzzleweb.GetLogger(c)
@@ -136,13 +164,16 @@ func MakeForumPage(forumName string, forumConfig config.ForumConfig) puzzleweb.P
 			if err == nil {
 				err = forumService.DeleteThread(c.Request.Context(), puzzleweb.GetSessionUserId(c), threadId)
 			} else {
-				logger.Warn(parsingThreadIdErrorMsg, zap.Error(err))
-				err = common.ErrTechnical
+				logger.Debug(parsingThreadIdErrorMsg, zap.Error(err))
+				err = common.ErrNotFound
 			}
 
+			baseUrl := common.GetBaseUrl(2, c)
 			var targetBuilder strings.Builder
-			targetBuilder.WriteString(common.GetBaseUrl(2, c))
-			if err != nil {
+			targetBuilder.WriteString(baseUrl)
+			if err == nil {
+				puzzleweb.InvalidatePages(c, baseUrl)
+			} else {
 				common.WriteError(&targetBuilder, logger, err.Error())
 			}
 			return targetBuilder.String()
@@ -151,8 +182,8 @@ func MakeForumPage(forumName string, forumConfig config.ForumConfig) puzzleweb.P
 			logger := puzzleweb.GetLogger(c)
 			threadId, err := strconv.ParseUint(c.Param(threadIdName), 10, 64)
 			if err != nil {
-				logger.Warn(parsingThreadIdErrorMsg, zap.Error(err))
-				return "", common.DefaultErrorRedirect(logger, common.ErrorTechnicalKey)
+				logger.Debug(parsingThreadIdErrorMsg, zap.Error(err))
+				return common.ErrorPage(c, common.ErrNotFound)
 			}
 
 			pageNumber, start, end, filter := common.GetPagination(defaultPageSize, c)
@@ -161,7 +192,7 @@ func MakeForumPage(forumName string, forumConfig config.ForumConfig) puzzleweb.P
 			userId, _ := data[common.UserIdName].(uint64)
 			total, thread, messages, err := forumService.GetThread(ctx, userId, threadId, start, end, filter)
 			if err != nil {
-				return "", common.DefaultErrorRedirect(logger, err.Error())
+				return common.ErrorPage(c, err)
 			}
 
 			common.InitPagination(data, filter, pageNumber, end, total)
@@ -177,8 +208,8 @@ func MakeForumPage(forumName string, forumConfig config.ForumConfig) puzzleweb.P
 			logger := puzzleweb.GetLogger(c)
 			threadId, err := strconv.ParseUint(c.Param(threadIdName), 10, 64)
 			if err != nil {
-				logger.Warn(parsingThreadIdErrorMsg, zap.Error(err))
-				return common.DefaultErrorRedirect(logger, common.ErrorTechnicalKey)
+				logger.Debug(parsingThreadIdErrorMsg, zap.Error(err))
+				return common.DefaultErrorRedirect(logger, common.ErrorNotFoundKey)
 			}
 			message := c.PostForm("message")
 
@@ -187,29 +218,38 @@ func MakeForumPage(forumName string, forumConfig config.ForumConfig) puzzleweb.P
 				err = forumService.CreateMessage(c.Request.Context(), puzzleweb.GetSessionUserId(c), threadId, message)
 			}
 
-			targetBuilder := threadUrlBuilder(common.GetBaseUrl(3, c), threadId)
-			if err != nil {
+			baseUrl := common.GetBaseUrl(3, c)
+			targetBuilder := threadUrlBuilder(baseUrl, threadId)
+			if err == nil {
+				puzzleweb.InvalidatePages(c, baseUrl)
+			} else {
 				common.WriteError(targetBuilder, logger, err.Error())
 			}
 			return targetBuilder.String()
 		}),
+		confirmDeleteMessage: puzzleweb.CreateConfirmTemplate("ConfirmDeleteMessage", func(c *gin.Context) string {
+			return common.GetBaseUrl(4, c) + "view/" + c.Param(threadIdName)
+		}),
 		deleteMessageHandler: common.CreateRedirect(func(c *gin.Context) string {
 			logger := puzzleweb.GetLogger(c)
 			threadId, err := strconv.ParseUint(c.Param(threadIdName), 10, 64)
 			if err != nil {
-				logger.Warn(parsingThreadIdErrorMsg, zap.Error(err))
-				return common.DefaultErrorRedirect(logger, common.ErrorTechnicalKey)
+				logger.Debug(parsingThreadIdErrorMsg, zap.Error(err))
+				return common.DefaultErrorRedirect(logger, common.ErrorNotFoundKey)
 			}
 			messageId, err := strconv.ParseUint(c.Param("messageId"), 10, 64)
 			if err != nil {
-				logger.Warn("Failed to parse messageId", zap.Error(err))
-				return common.DefaultErrorRedirect(logger, common.ErrorTechnicalKey)
+				logger.Debug("Failed to parse messageId", zap.Error(err))
+				return common.DefaultErrorRedirect(logger, common.ErrorNotFoundKey)
 			}
 
 			err = forumService.DeleteMessage(c.Request.Context(), puzzleweb.GetSessionUserId(c), threadId, messageId)
 
-			targetBuilder := threadUrlBuilder(common.GetBaseUrl(4, c), threadId)
-			if err != nil {
+			baseUrl := common.GetBaseUrl(4, c)
+			targetBuilder := threadUrlBuilder(baseUrl, threadId)
+			if err == nil {
+				puzzleweb.InvalidatePages(c, baseUrl)
+			} else {
 				common.WriteError(targetBuilder, logger, err.Error())
 			}
 			return targetBuilder.String()
@@ -0,0 +1,108 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package forum_test
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/dvaumoron/puzzleweb/common"
+	forumservice "github.com/dvaumoron/puzzleweb/forum/service"
+	"github.com/dvaumoron/puzzleweb/puzzlewebtest"
+)
+
+const forumGroupId = 11
+
+func TestThreadLifecycle(t *testing.T) {
+	builder := puzzlewebtest.NewSiteBuilder(t)
+	forum := builder.AddForum("forum", 1, forumGroupId)
+	site := builder.Build()
+	client := site.NewLoggedClient("alice")
+
+	client.Get("/forum/create").AssertTemplate(t, "forum/create")
+
+	form := url.Values{"title": {"Question"}, "message": {"How to start ?"}}
+	client.PostForm("/forum/save", form).AssertRedirect(t, "/forum/view/1")
+
+	client.PostForm("/forum/message/save/1", url.Values{"message": {"Read the doc"}}).AssertRedirect(t, "/forum/view/1")
+
+	response := client.Get("/forum/view/1")
+	response.AssertStatus(t, http.StatusOK)
+	response.AssertTemplate(t, "forum/view")
+	response.AssertData(t, common.AllowedToCreateName, true)
+	thread, _ := response.Data()["Thread"].(forumservice.ForumContent)
+	if thread.Text != "Question" {
+		t.Errorf("unexpected thread : %+v", thread)
+	}
+	if messages, _ := response.Data()["ForumMessages"].([]forumservice.ForumContent); len(messages) != 2 {
+		t.Errorf("unexpected messages : %+v", messages)
+	}
+
+	client.Get("/forum/delete/1").AssertTemplate(t, "confirm")
+	client.PostForm("/forum/delete/1", nil).AssertRedirect(t, "/forum/")
+	if calls := forum.CallsTo("DeleteThread"); len(calls) != 1 {
+		t.Errorf("DeleteThread called %d times, expected 1", len(calls))
+	}
+
+	response = client.Get("/forum/")
+	response.AssertTemplate(t, "forum/list")
+	if threads, _ := response.Data()["Threads"].([]forumservice.ForumContent); len(threads) != 0 {
+		t.Errorf("unexpected threads : %+v", threads)
+	}
+}
+
+func TestEmptyMessage(t *testing.T) {
+	builder := puzzlewebtest.NewSiteBuilder(t)
+	forum := builder.AddForum("forum", 1, forumGroupId)
+	site := builder.Build()
+	client := site.NewLoggedClient("alice")
+
+	assertErrorRedirect(t, client.PostForm("/forum/save", url.Values{"title": {"Question"}}), "/?error=")
+	client.PostForm("/forum/save", url.Values{"title": {"Question"}, "message": {"Text"}})
+	assertErrorRedirect(t, client.PostForm("/forum/message/save/1", nil), "/forum/view/1?error=")
+
+	if calls := forum.CallsTo("CreateMessage"); len(calls) != 0 {
+		t.Errorf("CreateMessage called %d times, expected 0", len(calls))
+	}
+}
+
+func assertErrorRedirect(t *testing.T, response *puzzlewebtest.Response, prefix string) {
+	t.Helper()
+	response.AssertStatus(t, http.StatusFound)
+	if location := response.Header().Get("Location"); !strings.HasPrefix(location, prefix) {
+		t.Errorf("redirected to %q, expected an error on %q", location, prefix)
+	}
+}
+
+func TestThreadAccess(t *testing.T) {
+	builder := puzzlewebtest.NewSiteBuilder(t)
+	builder.AddForum("forum", 1, forumGroupId)
+	builder.Rights.Authorize = func(userId uint64, groupId uint64, action string) bool {
+		return userId != 0
+	}
+	site := builder.Build()
+
+	site.NewLoggedClient("alice").PostForm("/forum/save", url.Values{"title": {"Question"}, "message": {"Text"}})
+
+	site.NewClient().Get("/forum/view/1").AssertError(t, common.ErrNotAuthorized)
+	site.NewClient().Get("/forum/view/x").AssertError(t, common.ErrNotFound)
+	site.NewLoggedClient("alice").PostForm("/forum/message/save/x", url.Values{"message": {"Text"}}).AssertRedirect(t, "/?error="+common.ErrorNotFoundKey)
+}
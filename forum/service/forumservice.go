@@ -20,6 +20,7 @@ package forumservice
 
 import (
 	"context"
+	"time"
 
 	profileservice "github.com/dvaumoron/puzzleweb/profile/service"
 )
@@ -27,7 +28,7 @@ import (
 type ForumContent struct {
 	Id      uint64
 	Creator profileservice.UserProfile
-	Date    string
+	Date    time.Time
 	Text    string
 }
 
@@ -27,6 +27,7 @@ import (
 	"github.com/dvaumoron/puzzleweb/common/config"
 	globalconfig "github.com/dvaumoron/puzzleweb/common/config/global"
 	"github.com/dvaumoron/puzzleweb/common/config/parser"
+	puzzleweb "github.com/dvaumoron/puzzleweb/core"
 	"go.uber.org/zap"
 )
 
@@ -41,36 +42,64 @@ func main() {
 
 	parsedConfig, err := parser.ParseConfig(confPath)
 	globalConfig, initSpan := globalconfig.Init(config.WebKey, version, parsedConfig, err)
-	site, ok := build.BuildDefaultSite(globalConfig)
+	widgets := parsedConfig.WidgetsAsMap()
+	site, ok := buildSite(globalConfig, parsedConfig.StaticPages, parsedConfig.MarkdownPages, parsedConfig.WidgetPages, parsedConfig.Redirects, widgets)
 	if !ok {
 		return
 	}
+	defaultSite := puzzleweb.SiteAndConfig{Site: site, Config: globalConfig.ExtractSiteConfig()}
 
-	// create group for permissions
-	rightClient := globalConfig.RightClient
-	for _, group := range parsedConfig.PermissionGroups {
-		if !rightClient.RegisterGroup(group.Id, group.Name) {
+	hasDefault := false
+	virtualSites := make([]puzzleweb.SiteAndConfig, 0, len(parsedConfig.Sites))
+	for _, hostConfig := range parsedConfig.Sites {
+		hostGlobalConfig, ok := globalConfig.MakeVirtualHostConfig(hostConfig)
+		if !ok {
 			return
 		}
-	}
-
-	logger := globalConfig.Logger
-	for _, pageGroup := range parsedConfig.StaticPages {
-		if !site.AddStaticPages(pageGroup) {
-			logger.Error("Failure during static pages creation")
+		hostSite, ok := buildSite(hostGlobalConfig, hostConfig.StaticPages, hostConfig.MarkdownPages, hostConfig.WidgetPages, hostConfig.Redirects, widgets)
+		if !ok {
 			return
 		}
+
+		siteAndConfig := puzzleweb.SiteAndConfig{Site: hostSite, Config: hostGlobalConfig.ExtractSiteConfig()}
+		if hostConfig.Default {
+			if hasDefault {
+				globalConfig.Logger.Error("Only one site can be the default")
+				return
+			}
+			hasDefault = true
+			siteAndConfig, defaultSite = defaultSite, siteAndConfig
+		}
+		virtualSites = append(virtualSites, siteAndConfig)
 	}
 
-	if !build.AddWidgetPages(site, globalConfig.InitCtx, parsedConfig.WidgetPages, globalConfig, parsedConfig.WidgetsAsMap()) {
-		return
+	// create group for permissions
+	rightClient := globalConfig.RightClient
+	for _, group := range parsedConfig.PermissionGroups {
+		if !rightClient.RegisterGroup(group.Id, group.Name) {
+			return
+		}
 	}
 
 	initSpan.End()
 
 	loggerGetter, tracerProvider, tracer := globalConfig.LoggerGetter, globalConfig.TracerProvider, globalConfig.Tracer
+	meterProvider, standaloneServices := globalConfig.MeterProvider, globalConfig.Standalone
+	// deferred to run after site.Run, which returns once the HTTP server has drained
 	defer func() {
 		ctx := context.Background()
+		if standaloneServices != nil {
+			if err := standaloneServices.Close(); err != nil {
+				ctx, stopSpan := tracer.Start(ctx, "shutdown")
+				loggerGetter.Logger(ctx).Warn("Failed to close standalone storage", zap.Error(err))
+				stopSpan.End()
+			}
+		}
+		if err := meterProvider.Shutdown(ctx); err != nil {
+			ctx, stopSpan := tracer.Start(ctx, "shutdown")
+			loggerGetter.Logger(ctx).Warn("Failed to shutdown meter provider", zap.Error(err))
+			stopSpan.End()
+		}
 		if err := tracerProvider.Shutdown(ctx); err != nil {
 			ctx, stopSpan := tracer.Start(ctx, "shutdown")
 			loggerGetter.Logger(ctx).Warn("Failed to shutdown trace provider", zap.Error(err))
@@ -78,11 +107,42 @@ func main() {
 		}
 	}()
 
-	siteConfig := globalConfig.ExtractSiteConfig()
+	logger := globalConfig.Logger
 	// emptying data no longer useful for GC cleaning
 	globalConfig = nil
 
-	if err := site.Run(siteConfig); err != nil {
+	if len(virtualSites) == 0 {
+		err = defaultSite.Site.Run(defaultSite.Config)
+	} else {
+		err = puzzleweb.RunVirtualHosts(defaultSite, virtualSites...)
+	}
+	if err != nil {
 		logger.Error("Failed to serve", zap.Error(err))
 	}
 }
+
+func buildSite(globalConfig *globalconfig.GlobalConfig, staticPages []parser.StaticPagesConfig, markdownPages []parser.MarkdownPagesConfig, widgetPages []parser.WidgetPageConfig, redirects []parser.RedirectConfig, widgets map[string]parser.WidgetConfig) (*puzzleweb.Site, bool) {
+	site, ok := build.BuildDefaultSite(globalConfig)
+	if !ok {
+		return nil, false
+	}
+
+	for _, pageGroup := range staticPages {
+		if !site.AddStaticPages(pageGroup) {
+			globalConfig.Logger.Error("Failure during static pages creation")
+			return nil, false
+		}
+	}
+	for _, pagesConfig := range markdownPages {
+		markdownConfig, ok := globalConfig.MakeMarkdownPagesConfig(pagesConfig)
+		if !ok || !site.AddMarkdownPages(markdownConfig) {
+			globalConfig.Logger.Error("Failure during markdown pages creation")
+			return nil, false
+		}
+	}
+	if err := site.AddRedirects(redirects); err != nil {
+		globalConfig.Logger.Error("Failure during redirects creation", zap.Error(err))
+		return nil, false
+	}
+	return site, build.AddWidgetPages(site, globalConfig.InitCtx, widgetPages, globalConfig, widgets)
+}
@@ -0,0 +1,105 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	grpcclient "github.com/dvaumoron/puzzlegrpcclient"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+var errNotServing = errors.New("service not serving")
+
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+type Probe struct {
+	Name    string
+	Checker Checker
+}
+
+type Status struct {
+	Name  string `json:"name"`
+	Ready bool   `json:"ready"`
+	Error string `json:"error,omitempty"`
+}
+
+type grpcChecker struct {
+	grpcclient.Client
+}
+
+// Use the standard gRPC health protocol.
+func NewGrpcChecker(serviceAddr string, dialOptions []grpc.DialOption) Checker {
+	return grpcChecker{Client: grpcclient.Make(serviceAddr, dialOptions...)}
+}
+
+func (checker grpcChecker) Check(ctx context.Context) error {
+	conn, err := checker.Dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	response, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		// the server answered, so it is reachable even without health service
+		if status.Code(err) == codes.Unimplemented {
+			return nil
+		}
+		return err
+	}
+	if response.Status != healthpb.HealthCheckResponse_SERVING {
+		return errNotServing
+	}
+	return nil
+}
+
+// Run every probe concurrently, each with its own time out.
+func CheckAll(ctx context.Context, timeOut time.Duration, probes []Probe) ([]Status, bool) {
+	statuses := make([]Status, len(probes))
+	var wg sync.WaitGroup
+	for index, probe := range probes {
+		wg.Add(1)
+		go func(index int, probe Probe) {
+			defer wg.Done()
+			probeCtx, cancel := context.WithTimeout(ctx, timeOut)
+			defer cancel()
+
+			statuses[index] = Status{Name: probe.Name, Ready: true}
+			if err := probe.Checker.Check(probeCtx); err != nil {
+				statuses[index] = Status{Name: probe.Name, Error: err.Error()}
+			}
+		}(index, probe)
+	}
+	wg.Wait()
+
+	ready := true
+	for _, status := range statuses {
+		ready = ready && status.Ready
+	}
+	return statuses, ready
+}
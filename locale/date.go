@@ -0,0 +1,114 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package locale
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DatesName    = "Dates"
+	TimeZoneName = "timeZone" // settings and session key of the user timezone
+)
+
+var relativeUnits = []struct {
+	name     string
+	duration time.Duration
+}{
+	{name: "Years", duration: 365 * 24 * time.Hour},
+	{name: "Months", duration: 30 * 24 * time.Hour},
+	{name: "Days", duration: 24 * time.Hour},
+	{name: "Hours", duration: time.Hour},
+	{name: "Minutes", duration: time.Minute},
+}
+
+// Format the dates of the template data with the layout of the lang
+// in the timezone of the user (the server one when not setted).
+type DateFormatter struct {
+	Layout   string
+	TimeZone string
+	location *time.Location
+}
+
+func NewDateFormatter(layout string, timeZone string) DateFormatter {
+	location, err := time.LoadLocation(timeZone)
+	if err != nil {
+		// the timezone is checked when saving the settings
+		timeZone, location = "", time.Local
+	}
+	return DateFormatter{Layout: layout, TimeZone: timeZone, location: location}
+}
+
+func (f DateFormatter) Format(date time.Time) string {
+	if date.IsZero() {
+		return ""
+	}
+	location := f.location
+	if location == nil {
+		// zero value
+		location = time.Local
+	}
+	return date.In(location).Format(f.Layout)
+}
+
+func (f DateFormatter) Since(date time.Time) RelativeTime {
+	return MakeRelativeTime(time.Since(date))
+}
+
+// A duration rounded down to its biggest unit, Key gives the message key (like "RelativeHoursAgo"
+// or "RelativeInHours") and String an english text (like "3 hours ago").
+type RelativeTime struct {
+	Count  int64
+	Unit   string // Years, Months, Days, Hours, Minutes or Seconds
+	Future bool
+}
+
+func MakeRelativeTime(elapsed time.Duration) RelativeTime {
+	future := elapsed < 0
+	if future {
+		elapsed = -elapsed
+	}
+	for _, unit := range relativeUnits {
+		if elapsed >= unit.duration {
+			return RelativeTime{Count: int64(elapsed / unit.duration), Unit: unit.name, Future: future}
+		}
+	}
+	return RelativeTime{Count: int64(elapsed / time.Second), Unit: "Seconds", Future: future}
+}
+
+func (r RelativeTime) Key() string {
+	if r.Future {
+		return "RelativeIn" + r.Unit
+	}
+	return "Relative" + r.Unit + "Ago"
+}
+
+func (r RelativeTime) String() string {
+	unit := strings.ToLower(r.Unit)
+	if r.Count == 1 {
+		unit = unit[:len(unit)-1]
+	}
+	text := strconv.FormatInt(r.Count, 10) + " " + unit
+	if r.Future {
+		return "in " + text
+	}
+	return text + " ago"
+}
@@ -0,0 +1,157 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package locale
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// Date as sent to the services which receive the data in JSON
+// (they can not call the methods of DateFormatter).
+type FormattedDate struct {
+	Time          time.Time
+	Formatted     string // with the layout of the lang in the timezone of the user
+	RelativeKey   string // message key, like "RelativeHoursAgo"
+	RelativeCount int64
+	Relative      string // english text, like "3 hours ago"
+}
+
+func (f DateFormatter) MakeFormattedDate(date time.Time) FormattedDate {
+	relative := f.Since(date)
+	return FormattedDate{
+		Time: date, Formatted: f.Format(date), RelativeKey: relative.Key(), RelativeCount: relative.Count,
+		Relative: relative.String(),
+	}
+}
+
+// Copy of data ready for JSON marshaling, where every time.Time (even in structs, maps or slices)
+// is replaced by a FormattedDate built with the DateFormatter of data.
+func FormatDates(data any) any {
+	var formatter DateFormatter
+	if h, ok := data.(gin.H); ok {
+		formatter, _ = h[DatesName].(DateFormatter)
+	}
+	return dateConverter{formatter: formatter}.convert(reflect.ValueOf(data))
+}
+
+type dateConverter struct {
+	formatter DateFormatter
+}
+
+func (c dateConverter) convert(value reflect.Value) any {
+	if !value.IsValid() {
+		return nil
+	}
+
+	valueType := value.Type()
+	switch {
+	case valueType == timeType:
+		return c.formatter.MakeFormattedDate(value.Interface().(time.Time))
+	case valueType.Implements(jsonMarshalerType), valueType.Implements(textMarshalerType):
+		// the type knows its encoding
+		return value.Interface()
+	}
+
+	switch value.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if value.IsNil() {
+			return nil
+		}
+		return c.convert(value.Elem())
+	case reflect.Map:
+		if value.IsNil() || valueType.Key().Kind() != reflect.String {
+			return value.Interface()
+		}
+		res := make(map[string]any, value.Len())
+		iter := value.MapRange()
+		for iter.Next() {
+			res[iter.Key().String()] = c.convert(iter.Value())
+		}
+		return res
+	case reflect.Slice, reflect.Array:
+		if valueType.Elem().Kind() == reflect.Uint8 || (value.Kind() == reflect.Slice && value.IsNil()) {
+			// []byte are encoded in base64
+			return value.Interface()
+		}
+		size := value.Len()
+		res := make([]any, 0, size)
+		for index := 0; index < size; index++ {
+			res = append(res, c.convert(value.Index(index)))
+		}
+		return res
+	case reflect.Struct:
+		res := map[string]any{}
+		c.convertStruct(value, res, true)
+		return res
+	}
+	return value.Interface()
+}
+
+// follow the rules of encoding/json for the field names (the fields of the embedded structs
+// are promoted without overriding the ones of the outer struct)
+func (c dateConverter) convertStruct(value reflect.Value, res map[string]any, outer bool) {
+	valueType := value.Type()
+	for index := 0; index < valueType.NumField(); index++ {
+		field := valueType.Field(index)
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" && options == "" {
+			continue
+		}
+
+		fieldValue := value.Field(index)
+		if field.Anonymous && name == "" {
+			embeddedType := field.Type
+			if embeddedType.Kind() == reflect.Pointer {
+				if fieldValue.IsNil() {
+					continue
+				}
+				fieldValue, embeddedType = fieldValue.Elem(), embeddedType.Elem()
+			}
+			if embeddedType.Kind() == reflect.Struct {
+				c.convertStruct(fieldValue, res, false)
+				continue
+			}
+		}
+
+		if !field.IsExported() || !fieldValue.CanInterface() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if strings.Contains(options, "omitempty") && fieldValue.IsZero() {
+			continue
+		}
+		if _, exists := res[name]; outer || !exists {
+			res[name] = c.convert(fieldValue)
+		}
+	}
+}
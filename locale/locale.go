@@ -30,8 +30,10 @@ import (
 )
 
 const (
-	LangName = "lang"
-	pathName = "Path"
+	LangName      = "lang"
+	FallbacksName = "LangFallbacks"
+	UrlLangName   = "UrlLang" // context key of the lang from the url prefix
+	pathName      = "Path"
 )
 
 type localesManager struct {
@@ -41,7 +43,10 @@ type localesManager struct {
 	AllLang        []string
 	DefaultLang    string
 	MultipleLang   bool
+	LangInUrl      bool
 	matcher        language.Matcher
+	fallbacks      map[string][]string
+	dateFormats    map[string]string
 }
 
 func NewManager(localesConfig config.LocalesConfig) (common.LocalesManager, bool) {
@@ -57,12 +62,73 @@ func NewManager(localesConfig config.LocalesConfig) (common.LocalesManager, bool
 		tags = append(tags, language.MustParse(lang))
 	}
 
+	fallbacks, ok := buildFallbacks(localesConfig, tags)
+	if !ok {
+		return nil, false
+	}
+
 	return &localesManager{
 		LoggerGetter: localesConfig.LoggerGetter, Domain: localesConfig.Domain, SessionTimeOut: localesConfig.SessionTimeOut,
-		AllLang: localesConfig.AllLang, DefaultLang: allLang[0], MultipleLang: size > 1, matcher: language.NewMatcher(tags),
+		AllLang: localesConfig.AllLang, DefaultLang: allLang[0], MultipleLang: size > 1, LangInUrl: localesConfig.LangInUrl,
+		matcher: language.NewMatcher(tags), fallbacks: fallbacks, dateFormats: buildDateFormats(localesConfig, fallbacks),
 	}, true
 }
 
+// the chain of a lang is its configured fallbacks, then its declared parent languages
+// (like "fr" for "fr-CA") and the default lang, without duplicate
+func buildFallbacks(localesConfig config.LocalesConfig, tags []language.Tag) (map[string][]string, bool) {
+	allLang := localesConfig.AllLang
+	declared := common.MakeSet(allLang)
+	for lang, configured := range localesConfig.Fallbacks {
+		for _, fallback := range append([]string{lang}, configured...) {
+			if !declared.Contains(fallback) {
+				localesConfig.Logger.Error("Fallback with not declared locale", zap.String("locale", fallback))
+				return nil, false
+			}
+		}
+	}
+
+	defaultLang := allLang[0]
+	fallbacks := make(map[string][]string, len(allLang))
+	for index, lang := range allLang {
+		seen := common.MakeSet([]string{lang})
+		var chain []string
+		add := func(fallback string) {
+			if declared.Contains(fallback) && !seen.Contains(fallback) {
+				seen.Add(fallback)
+				chain = append(chain, fallback)
+			}
+		}
+
+		for _, fallback := range localesConfig.Fallbacks[lang] {
+			add(fallback)
+		}
+		for tag := tags[index].Parent(); tag != language.Und; tag = tag.Parent() {
+			add(tag.String())
+		}
+		add(defaultLang)
+		fallbacks[lang] = chain
+	}
+	return fallbacks, true
+}
+
+// the layout of a lang is the configured one of the first lang of its chain which has one,
+// the global layout otherwise
+func buildDateFormats(localesConfig config.LocalesConfig, fallbacks map[string][]string) map[string]string {
+	dateFormats := make(map[string]string, len(localesConfig.AllLang))
+	for _, lang := range localesConfig.AllLang {
+		dateFormat := localesConfig.DateFormat
+		for _, candidate := range append([]string{lang}, fallbacks[lang]...) {
+			if configured := localesConfig.DateFormats[candidate]; configured != "" {
+				dateFormat = configured
+				break
+			}
+		}
+		dateFormats[lang] = dateFormat
+	}
+	return dateFormats
+}
+
 func (m *localesManager) GetDefaultLang() string {
 	return m.DefaultLang
 }
@@ -75,26 +141,53 @@ func (m *localesManager) GetMultipleLang() bool {
 	return m.MultipleLang
 }
 
+func (m *localesManager) GetLangInUrl() bool {
+	return m.LangInUrl
+}
+
+// the lang of the url prefix has priority over the cookie
 func (m *localesManager) GetLang(c *gin.Context) string {
+	if lang := c.GetString(UrlLangName); lang != "" {
+		return lang
+	}
+
 	lang, err := c.Cookie(LangName)
 	if err != nil {
-		tag, _ := language.MatchStrings(m.matcher, c.GetHeader("Accept-Language"))
-		return m.setLangCookie(tag.String(), c)
+		// the index gives the declared form (the matched tag can have extensions)
+		_, index := language.MatchStrings(m.matcher, c.GetHeader("Accept-Language"))
+		return m.setLangCookie(m.AllLang[index], c)
 	}
 	// check & refresh cookie
 	return m.SetLangCookie(lang, c)
 }
 
+// A declared lang is returned unchanged, otherwise the closest one is chosen
+// with BCP 47 matching ("fr-CA" gives "fr"), and the default one when none is close.
 func (m *localesManager) CheckLang(lang string, c *gin.Context) string {
 	for _, l := range m.AllLang {
 		if lang == l {
 			return lang
 		}
 	}
+
+	if tag, err := language.Parse(lang); err == nil {
+		if _, index, confidence := m.matcher.Match(tag); confidence != language.No {
+			return m.AllLang[index]
+		}
+	}
 	m.LoggerGetter.Logger(c.Request.Context()).Info("Asked not declared locale", zap.String("askedLocale", lang))
 	return m.DefaultLang
 }
 
+// The langs to try in order when a content is missing in lang (the default lang ends the chain).
+func (m *localesManager) GetFallbacks(lang string) []string {
+	return m.fallbacks[lang]
+}
+
+func (m *localesManager) GetDateFormat(lang string) string {
+	return m.dateFormats[lang]
+}
+
 func (m *localesManager) setLangCookie(lang string, c *gin.Context) string {
 	c.SetCookie(LangName, lang, m.SessionTimeOut, "/", m.Domain, false, false)
 	return lang
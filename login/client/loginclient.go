@@ -36,14 +36,13 @@ var errWeakPassword = errors.New("WeakPassword")
 
 type loginClient struct {
 	grpcclient.Client
-	dateFormat      string
 	saltService     loginservice.SaltService
 	strengthService strengthservice.PasswordStrengthService
 }
 
-func New(serviceAddr string, dialOptions []grpc.DialOption, dateFormat string, saltService loginservice.SaltService, strengthService strengthservice.PasswordStrengthService) loginservice.FullLoginService {
+func New(serviceAddr string, dialOptions []grpc.DialOption, saltService loginservice.SaltService, strengthService strengthservice.PasswordStrengthService) loginservice.FullLoginService {
 	return loginClient{
-		Client: grpcclient.Make(serviceAddr, dialOptions...), dateFormat: dateFormat,
+		Client:      grpcclient.Make(serviceAddr, dialOptions...),
 		saltService: saltService, strengthService: strengthService,
 	}
 }
@@ -129,7 +128,7 @@ func (client loginClient) GetUsers(ctx context.Context, userIds []uint64) (map[u
 
 	logins := map[uint64]loginservice.User{}
 	for _, value := range response.List {
-		logins[value.Id] = convertUser(value, client.dateFormat)
+		logins[value.Id] = convertUser(value)
 	}
 	return logins, nil
 }
@@ -218,7 +217,7 @@ func (client loginClient) ListUsers(ctx context.Context, start uint64, end uint6
 	sort.Sort(sortableContents(list))
 	users := make([]loginservice.User, 0, len(list))
 	for _, user := range list {
-		users = append(users, convertUser(user, client.dateFormat))
+		users = append(users, convertUser(user))
 	}
 	return response.Total, users, nil
 }
@@ -241,7 +240,6 @@ func (client loginClient) Delete(ctx context.Context, userId uint64) error {
 	return nil
 }
 
-func convertUser(user *pb.User, dateFormat string) loginservice.User {
-	registredAt := time.Unix(user.RegistredAt, 0)
-	return loginservice.User{Id: user.Id, Login: user.Login, RegistredAt: registredAt.Format(dateFormat)}
+func convertUser(user *pb.User) loginservice.User {
+	return loginservice.User{Id: user.Id, Login: user.Login, RegistredAt: time.Unix(user.RegistredAt, 0)}
 }
@@ -18,12 +18,15 @@
 
 package loginservice
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 type User struct {
 	Id          uint64
 	Login       string
-	RegistredAt string
+	RegistredAt time.Time
 }
 
 type UserService interface {
@@ -0,0 +1,362 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package puzzlewebtest
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	adminservice "github.com/dvaumoron/puzzleweb/admin/service"
+	blogservice "github.com/dvaumoron/puzzleweb/blog/service"
+	"github.com/dvaumoron/puzzleweb/common/build"
+	"github.com/dvaumoron/puzzleweb/common/config"
+	"github.com/dvaumoron/puzzleweb/common/config/parser"
+	"github.com/dvaumoron/puzzleweb/common/log"
+	puzzleweb "github.com/dvaumoron/puzzleweb/core"
+	forumservice "github.com/dvaumoron/puzzleweb/forum/service"
+	loginservice "github.com/dvaumoron/puzzleweb/login/service"
+	markdownservice "github.com/dvaumoron/puzzleweb/markdown/service"
+	profileservice "github.com/dvaumoron/puzzleweb/profile/service"
+	widgetservice "github.com/dvaumoron/puzzleweb/remotewidget/service"
+	sessionservice "github.com/dvaumoron/puzzleweb/session/service"
+	"github.com/dvaumoron/puzzleweb/standalone"
+	templateservice "github.com/dvaumoron/puzzleweb/templates/service"
+	wikiservice "github.com/dvaumoron/puzzleweb/wiki/service"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+)
+
+const remotePrefix = "remote/"
+
+type loggerWrapper struct {
+	logger *zap.Logger
+}
+
+func (lg loggerWrapper) Logger(ctx context.Context) log.Logger {
+	return lg.logger
+}
+
+// only Render is promoted
+type uncheckedTemplateService struct {
+	templateservice.TemplateService
+}
+
+// Assemble a site backed by fakes, the exported fields can be modified before Build.
+type SiteBuilder struct {
+	AllLang        []string // the first is the default
+	LangInUrl      bool
+	LangFallbacks  map[string][]string
+	DateFormat     string
+	DateFormats    map[string]string
+	SettingsSchema []parser.SettingConfig
+	PageSize       uint64
+	ExtractSize    uint64
+	ServiceTimeOut time.Duration
+	// the template service can not tell if a template exists (like the remote one)
+	HideTemplateChecker bool
+	SiteConfig          config.SiteConfig // the services and empty mandatory fields are filled by Build
+
+	Sessions  *FakeSessionService
+	Settings  *FakeSessionService
+	Templates *FakeTemplateService
+	Strength  *FakePasswordStrengthService
+	Logins    *FakeLoginService
+	Rights    *FakeRightService
+	Profiles  *FakeProfileService
+	Markdown  *FakeMarkdownService
+
+	t             testing.TB
+	logger        *zap.Logger
+	services      *standalone.Services
+	pages         []puzzleweb.Page
+	staticPages   []parser.StaticPagesConfig
+	markdownPages []parser.MarkdownPagesConfig
+	widgetPages   []parser.WidgetPageConfig
+	redirects     []parser.RedirectConfig
+	widgets       map[string]parser.WidgetConfig
+	wikis         map[uint64]*FakeWikiService
+	forums        map[uint64]*FakeForumService
+	blogs         map[uint64]*FakeBlogService
+	remoteFakes   map[string]*FakeWidgetService
+}
+
+// The logs are written in the test output.
+func NewSiteBuilder(t testing.TB) *SiteBuilder {
+	gin.SetMode(gin.TestMode)
+
+	logger := zaptest.NewLogger(t)
+	// in memory storage never fails
+	services, _ := standalone.New("", loggerWrapper{logger: logger})
+
+	sessionStore, _ := services.SessionService("session", 0)
+	settingsStore, _ := services.SessionService("settings", 0)
+	strength := &FakePasswordStrengthService{}
+	loginStore, _ := services.LoginService(strength)
+	logins := &FakeLoginService{Store: loginStore}
+	rights := NewFakeRightService()
+	profileStore, _ := services.ProfileService(adminservice.PublicGroupId, []byte("picture"), logins, rights)
+
+	return &SiteBuilder{
+		AllLang: []string{"en"}, DateFormat: "2/1/2006 15:04:05", PageSize: 10, ExtractSize: 200, ServiceTimeOut: 5 * time.Second,
+
+		Sessions: &FakeSessionService{Store: sessionStore}, Settings: &FakeSessionService{Store: settingsStore},
+		Templates: &FakeTemplateService{}, Strength: strength, Logins: logins, Rights: rights,
+		Profiles: &FakeProfileService{Store: profileStore}, Markdown: &FakeMarkdownService{Store: services.MarkdownService()},
+
+		t: t, logger: logger, services: services, widgets: map[string]parser.WidgetConfig{},
+		wikis: map[uint64]*FakeWikiService{}, forums: map[uint64]*FakeForumService{},
+		blogs: map[uint64]*FakeBlogService{}, remoteFakes: map[string]*FakeWidgetService{},
+	}
+}
+
+func (b *SiteBuilder) AddPage(page puzzleweb.Page) {
+	b.pages = append(b.pages, page)
+}
+
+// Locations are template names like in the configuration ("about" or "docs/").
+func (b *SiteBuilder) AddStaticPages(groupId uint64, locations ...string) {
+	b.AddStaticPagesConfig(parser.StaticPagesConfig{GroupId: groupId, Locations: locations})
+}
+
+func (b *SiteBuilder) AddStaticPagesConfig(pageGroup parser.StaticPagesConfig) {
+	b.staticPages = append(b.staticPages, pageGroup)
+}
+
+// The layout defaults to "markdown" like in the configuration.
+func (b *SiteBuilder) AddMarkdownPages(pagesConfig parser.MarkdownPagesConfig) {
+	b.markdownPages = append(b.markdownPages, pagesConfig)
+}
+
+// Declare a page with a widget configuration, the fakes are created by kind and object id.
+func (b *SiteBuilder) AddWidgetPage(path string, widgetConfig parser.WidgetConfig) {
+	if widgetConfig.Name == "" {
+		widgetConfig.Name = path
+	}
+	b.widgets[widgetConfig.Name] = widgetConfig
+	b.widgetPages = append(b.widgetPages, parser.WidgetPageConfig{Path: path, WidgetRef: widgetConfig.Name})
+}
+
+func (b *SiteBuilder) AddWiki(path string, objectId uint64, groupId uint64, templates ...string) *FakeWikiService {
+	widgetConfig := parser.WidgetConfig{Kind: "wiki", ObjectId: objectId, GroupId: groupId, Templates: templates}
+	b.AddWidgetPage(path, widgetConfig)
+	return b.getWiki(widgetConfig)
+}
+
+func (b *SiteBuilder) AddForum(path string, objectId uint64, groupId uint64, templates ...string) *FakeForumService {
+	widgetConfig := parser.WidgetConfig{Kind: "forum", ObjectId: objectId, GroupId: groupId, Templates: templates}
+	b.AddWidgetPage(path, widgetConfig)
+	return b.getForum(widgetConfig)
+}
+
+// The fake of the comments is in the Comments field of the returned blog.
+func (b *SiteBuilder) AddBlog(path string, objectId uint64, groupId uint64, templates ...string) *FakeBlogService {
+	widgetConfig := parser.WidgetConfig{Kind: "blog", ObjectId: objectId, GroupId: groupId, Templates: templates}
+	b.AddWidgetPage(path, widgetConfig)
+	return b.getBlog(widgetConfig)
+}
+
+// The actions are read by Build (they can be completed before).
+func (b *SiteBuilder) AddRemoteWidget(path string, objectId uint64, groupId uint64, actions ...widgetservice.Action) *FakeWidgetService {
+	widgetConfig := parser.WidgetConfig{Name: path, Kind: remotePrefix + path, ObjectId: objectId, GroupId: groupId}
+	b.AddWidgetPage(path, widgetConfig)
+	fake := b.getRemoteWidget(widgetConfig)
+	fake.Actions = append(fake.Actions, actions...)
+	return fake
+}
+
+func (b *SiteBuilder) AddRedirects(redirectConfigs ...parser.RedirectConfig) {
+	b.redirects = append(b.redirects, redirectConfigs...)
+}
+
+// Create the site like the frame does, the test fails when it is not possible.
+func (b *SiteBuilder) Build() *TestSite {
+	b.t.Helper()
+
+	site, ok := build.BuildDefaultSite(b)
+	if !ok {
+		b.t.Fatal("Failed to build site")
+	}
+	for _, page := range b.pages {
+		site.AddPage(page)
+	}
+	for _, pageGroup := range b.staticPages {
+		if !site.AddStaticPages(pageGroup) {
+			b.t.Fatal("Failed to add static pages", pageGroup.Locations)
+		}
+	}
+	for _, pagesConfig := range b.markdownPages {
+		if pagesConfig.Layout == "" {
+			pagesConfig.Layout = "markdown"
+		}
+		markdownConfig := config.MarkdownPagesConfig{
+			ServiceConfig: config.MakeServiceConfig[markdownservice.MarkdownService](b, b.Markdown),
+			Path:          pagesConfig.Path, GroupId: pagesConfig.GroupId, Layout: pagesConfig.Layout,
+		}
+		if !site.AddMarkdownPages(markdownConfig) {
+			b.t.Fatal("Failed to add markdown pages", pagesConfig.Path)
+		}
+	}
+	if err := site.AddRedirects(b.redirects); err != nil {
+		b.t.Fatal("Failed to add redirects", err)
+	}
+	if !build.AddWidgetPages(site, context.Background(), b.widgetPages, b, b.widgets) {
+		b.t.Fatal("Failed to add widget pages")
+	}
+
+	siteConfig := b.SiteConfig
+	siteConfig.ServiceConfig = config.MakeServiceConfig[sessionservice.SessionService](b, b.Sessions)
+	siteConfig.TemplateService = b.Templates
+	if b.HideTemplateChecker {
+		siteConfig.TemplateService = uncheckedTemplateService{TemplateService: b.Templates}
+	}
+	if siteConfig.Domain == "" {
+		siteConfig.Domain = "localhost"
+	}
+	if siteConfig.Port == "" {
+		siteConfig.Port = "80"
+	}
+	if siteConfig.SessionTimeOut == 0 {
+		siteConfig.SessionTimeOut = 1200
+	}
+	if siteConfig.StaticFileSystem == nil {
+		siteConfig.StaticFileSystem = http.FS(fstest.MapFS{})
+	}
+	if siteConfig.FaviconPath == "" {
+		siteConfig.FaviconPath = config.DefaultFavicon
+	}
+	return &TestSite{SiteBuilder: b, Site: site, Handler: site.Handler(siteConfig)}
+}
+
+func (b *SiteBuilder) GetLogger() log.Logger {
+	return b.logger
+}
+
+func (b *SiteBuilder) GetLoggerGetter() log.LoggerGetter {
+	return loggerWrapper{logger: b.logger}
+}
+
+func (b *SiteBuilder) GetServiceTimeOut() time.Duration {
+	return b.ServiceTimeOut
+}
+
+func (b *SiteBuilder) ExtractLocalesConfig() config.LocalesConfig {
+	return config.LocalesConfig{
+		Logger: b.logger, LoggerGetter: b.GetLoggerGetter(), Domain: "localhost", SessionTimeOut: 1200, AllLang: b.AllLang,
+		LangInUrl: b.LangInUrl, Fallbacks: b.LangFallbacks, DateFormat: b.DateFormat, DateFormats: b.DateFormats,
+	}
+}
+
+func (b *SiteBuilder) ExtractLoginConfig() config.LoginConfig {
+	return config.MakeServiceConfig[loginservice.LoginService](b, b.Logins)
+}
+
+func (b *SiteBuilder) ExtractAdminConfig() config.AdminConfig {
+	return config.AdminConfig{
+		ServiceConfig: config.MakeServiceConfig[adminservice.AdminService](b, b.Rights),
+		UserService:   b.Logins, ProfileService: b.Profiles, PageSize: b.PageSize,
+	}
+}
+
+func (b *SiteBuilder) ExtractSettingsConfig() config.SettingsConfig {
+	return config.SettingsConfig{
+		ServiceConfig: config.MakeServiceConfig[sessionservice.SessionService](b, b.Settings), Schema: b.SettingsSchema,
+	}
+}
+
+func (b *SiteBuilder) ExtractProfileConfig() config.ProfileConfig {
+	return config.ProfileConfig{
+		ServiceConfig: config.MakeServiceConfig[profileservice.AdvancedProfileService](b, b.Profiles),
+		AdminService:  b.Rights, LoginService: b.Logins,
+	}
+}
+
+func (b *SiteBuilder) MakeWikiConfig(widgetConfig parser.WidgetConfig) (config.WikiConfig, bool) {
+	return config.WikiConfig{
+		ServiceConfig:   config.MakeServiceConfig[wikiservice.WikiService](b, b.getWiki(widgetConfig)),
+		MarkdownService: b.Markdown, LangInUrl: b.LangInUrl, Args: widgetConfig.Templates,
+	}, true
+}
+
+func (b *SiteBuilder) MakeForumConfig(widgetConfig parser.WidgetConfig) (config.ForumConfig, bool) {
+	return config.ForumConfig{
+		ServiceConfig: config.MakeServiceConfig[forumservice.ForumService](b, b.getForum(widgetConfig)),
+		PageSize:      b.PageSize, Args: widgetConfig.Templates,
+	}, true
+}
+
+func (b *SiteBuilder) MakeBlogConfig(widgetConfig parser.WidgetConfig) (config.BlogConfig, bool) {
+	blog := b.getBlog(widgetConfig)
+	return config.BlogConfig{
+		ServiceConfig:   config.MakeServiceConfig[blogservice.BlogService](b, blog),
+		MarkdownService: b.Markdown, CommentService: blog.Comments, Domain: "localhost", Port: "80",
+		PageSize: b.PageSize, ExtractSize: b.ExtractSize, FeedFormat: "atom",
+		FeedSize: b.PageSize, Args: widgetConfig.Templates,
+	}, true
+}
+
+func (b *SiteBuilder) MakeWidgetConfig(widgetConfig parser.WidgetConfig) (config.RemoteWidgetConfig, bool) {
+	return config.MakeServiceConfig[widgetservice.WidgetService](
+		b, b.getRemoteWidget(widgetConfig),
+	), strings.HasPrefix(widgetConfig.Kind, remotePrefix)
+}
+
+func (b *SiteBuilder) getWiki(widgetConfig parser.WidgetConfig) *FakeWikiService {
+	wiki, ok := b.wikis[widgetConfig.ObjectId]
+	if !ok {
+		store, _ := b.services.WikiService(widgetConfig.ObjectId, widgetConfig.GroupId, b.Rights, b.Profiles)
+		wiki = &FakeWikiService{Store: store}
+		b.wikis[widgetConfig.ObjectId] = wiki
+	}
+	return wiki
+}
+
+func (b *SiteBuilder) getForum(widgetConfig parser.WidgetConfig) *FakeForumService {
+	forum, ok := b.forums[widgetConfig.ObjectId]
+	if !ok {
+		store, _ := b.services.ForumService(widgetConfig.ObjectId, widgetConfig.GroupId, b.Rights, b.Profiles)
+		forum = &FakeForumService{Store: store}
+		b.forums[widgetConfig.ObjectId] = forum
+	}
+	return forum
+}
+
+func (b *SiteBuilder) getBlog(widgetConfig parser.WidgetConfig) *FakeBlogService {
+	blog, ok := b.blogs[widgetConfig.ObjectId]
+	if !ok {
+		store, _ := b.services.BlogService(widgetConfig.ObjectId, widgetConfig.GroupId, b.Rights, b.Profiles)
+		// like with the remote services, the comments are in the forum with the same id
+		blog = &FakeBlogService{Store: store, Comments: b.getForum(widgetConfig)}
+		b.blogs[widgetConfig.ObjectId] = blog
+	}
+	return blog
+}
+
+func (b *SiteBuilder) getRemoteWidget(widgetConfig parser.WidgetConfig) *FakeWidgetService {
+	fake, ok := b.remoteFakes[widgetConfig.Name]
+	if !ok {
+		fake = &FakeWidgetService{Results: map[string]WidgetResult{}}
+		b.remoteFakes[widgetConfig.Name] = fake
+	}
+	return fake
+}
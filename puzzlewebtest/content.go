@@ -0,0 +1,238 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package puzzlewebtest
+
+import (
+	"context"
+	"encoding/json"
+
+	blogservice "github.com/dvaumoron/puzzleweb/blog/service"
+	forumservice "github.com/dvaumoron/puzzleweb/forum/service"
+	widgetservice "github.com/dvaumoron/puzzleweb/remotewidget/service"
+	wikiservice "github.com/dvaumoron/puzzleweb/wiki/service"
+	"github.com/gin-gonic/gin"
+)
+
+type FakeWikiService struct {
+	Recorder
+	Store wikiservice.WikiService
+}
+
+func (s *FakeWikiService) LoadContent(ctx context.Context, userId uint64, lang string, title string, version string) (*wikiservice.WikiContent, error) {
+	if err := s.record("LoadContent", userId, lang, title, version); err != nil {
+		return nil, err
+	}
+	return s.Store.LoadContent(ctx, userId, lang, title, version)
+}
+
+func (s *FakeWikiService) StoreContent(ctx context.Context, userId uint64, lang string, title string, last string, markdown string) error {
+	if err := s.record("StoreContent", userId, lang, title, last, markdown); err != nil {
+		return err
+	}
+	return s.Store.StoreContent(ctx, userId, lang, title, last, markdown)
+}
+
+func (s *FakeWikiService) GetVersions(ctx context.Context, userId uint64, lang string, title string) ([]wikiservice.Version, error) {
+	if err := s.record("GetVersions", userId, lang, title); err != nil {
+		return nil, err
+	}
+	return s.Store.GetVersions(ctx, userId, lang, title)
+}
+
+func (s *FakeWikiService) DeleteContent(ctx context.Context, userId uint64, lang string, title string, version string) error {
+	if err := s.record("DeleteContent", userId, lang, title, version); err != nil {
+		return err
+	}
+	return s.Store.DeleteContent(ctx, userId, lang, title, version)
+}
+
+func (s *FakeWikiService) DeleteRight(ctx context.Context, userId uint64) bool {
+	return s.record("DeleteRight", userId) == nil && s.Store.DeleteRight(ctx, userId)
+}
+
+// Fake forum service, also used for the comments of a blog.
+type FakeForumService struct {
+	Recorder
+	Store forumservice.FullForumService
+}
+
+func (s *FakeForumService) CreateThread(ctx context.Context, userId uint64, title string, message string) (uint64, error) {
+	if err := s.record("CreateThread", userId, title, message); err != nil {
+		return 0, err
+	}
+	return s.Store.CreateThread(ctx, userId, title, message)
+}
+
+func (s *FakeForumService) CreateMessage(ctx context.Context, userId uint64, threadId uint64, message string) error {
+	if err := s.record("CreateMessage", userId, threadId, message); err != nil {
+		return err
+	}
+	return s.Store.CreateMessage(ctx, userId, threadId, message)
+}
+
+func (s *FakeForumService) GetThread(ctx context.Context, userId uint64, threadId uint64, start uint64, end uint64, filter string) (uint64, forumservice.ForumContent, []forumservice.ForumContent, error) {
+	if err := s.record("GetThread", userId, threadId, start, end, filter); err != nil {
+		return 0, forumservice.ForumContent{}, nil, err
+	}
+	return s.Store.GetThread(ctx, userId, threadId, start, end, filter)
+}
+
+func (s *FakeForumService) GetThreads(ctx context.Context, userId uint64, start uint64, end uint64, filter string) (uint64, []forumservice.ForumContent, error) {
+	if err := s.record("GetThreads", userId, start, end, filter); err != nil {
+		return 0, nil, err
+	}
+	return s.Store.GetThreads(ctx, userId, start, end, filter)
+}
+
+func (s *FakeForumService) DeleteThread(ctx context.Context, userId uint64, threadId uint64) error {
+	if err := s.record("DeleteThread", userId, threadId); err != nil {
+		return err
+	}
+	return s.Store.DeleteThread(ctx, userId, threadId)
+}
+
+func (s *FakeForumService) DeleteMessage(ctx context.Context, userId uint64, threadId uint64, messageId uint64) error {
+	if err := s.record("DeleteMessage", userId, threadId, messageId); err != nil {
+		return err
+	}
+	return s.Store.DeleteMessage(ctx, userId, threadId, messageId)
+}
+
+func (s *FakeForumService) CreateCommentThread(ctx context.Context, userId uint64, elemTitle string) error {
+	if err := s.record("CreateCommentThread", userId, elemTitle); err != nil {
+		return err
+	}
+	return s.Store.CreateCommentThread(ctx, userId, elemTitle)
+}
+
+func (s *FakeForumService) CreateComment(ctx context.Context, userId uint64, elemTitle string, message string) error {
+	if err := s.record("CreateComment", userId, elemTitle, message); err != nil {
+		return err
+	}
+	return s.Store.CreateComment(ctx, userId, elemTitle, message)
+}
+
+func (s *FakeForumService) GetCommentThread(ctx context.Context, userId uint64, elemTitle string, start uint64, end uint64) (uint64, []forumservice.ForumContent, error) {
+	if err := s.record("GetCommentThread", userId, elemTitle, start, end); err != nil {
+		return 0, nil, err
+	}
+	return s.Store.GetCommentThread(ctx, userId, elemTitle, start, end)
+}
+
+func (s *FakeForumService) DeleteCommentThread(ctx context.Context, userId uint64, elemTitle string) error {
+	if err := s.record("DeleteCommentThread", userId, elemTitle); err != nil {
+		return err
+	}
+	return s.Store.DeleteCommentThread(ctx, userId, elemTitle)
+}
+
+func (s *FakeForumService) DeleteComment(ctx context.Context, userId uint64, elemTitle string, commentId uint64) error {
+	if err := s.record("DeleteComment", userId, elemTitle, commentId); err != nil {
+		return err
+	}
+	return s.Store.DeleteComment(ctx, userId, elemTitle, commentId)
+}
+
+func (s *FakeForumService) CreateThreadRight(ctx context.Context, userId uint64) bool {
+	return s.record("CreateThreadRight", userId) == nil && s.Store.CreateThreadRight(ctx, userId)
+}
+
+func (s *FakeForumService) CreateMessageRight(ctx context.Context, userId uint64) bool {
+	return s.record("CreateMessageRight", userId) == nil && s.Store.CreateMessageRight(ctx, userId)
+}
+
+func (s *FakeForumService) DeleteRight(ctx context.Context, userId uint64) bool {
+	return s.record("DeleteRight", userId) == nil && s.Store.DeleteRight(ctx, userId)
+}
+
+type FakeBlogService struct {
+	Recorder
+	Store    blogservice.BlogService
+	Comments *FakeForumService // the forum sharing the blog object id
+}
+
+func (s *FakeBlogService) CreatePost(ctx context.Context, userId uint64, title string, content string) (uint64, error) {
+	if err := s.record("CreatePost", userId, title, content); err != nil {
+		return 0, err
+	}
+	return s.Store.CreatePost(ctx, userId, title, content)
+}
+
+func (s *FakeBlogService) GetPost(ctx context.Context, userId uint64, postId uint64) (blogservice.BlogPost, error) {
+	if err := s.record("GetPost", userId, postId); err != nil {
+		return blogservice.BlogPost{}, err
+	}
+	return s.Store.GetPost(ctx, userId, postId)
+}
+
+func (s *FakeBlogService) GetPosts(ctx context.Context, userId uint64, start uint64, end uint64, filter string) (uint64, []blogservice.BlogPost, error) {
+	if err := s.record("GetPosts", userId, start, end, filter); err != nil {
+		return 0, nil, err
+	}
+	return s.Store.GetPosts(ctx, userId, start, end, filter)
+}
+
+func (s *FakeBlogService) DeletePost(ctx context.Context, userId uint64, postId uint64) error {
+	if err := s.record("DeletePost", userId, postId); err != nil {
+		return err
+	}
+	return s.Store.DeletePost(ctx, userId, postId)
+}
+
+func (s *FakeBlogService) CreateRight(ctx context.Context, userId uint64) bool {
+	return s.record("CreateRight", userId) == nil && s.Store.CreateRight(ctx, userId)
+}
+
+func (s *FakeBlogService) DeleteRight(ctx context.Context, userId uint64) bool {
+	return s.record("DeleteRight", userId) == nil && s.Store.DeleteRight(ctx, userId)
+}
+
+// Answer of a fake remote widget action (Data is sent as JSON).
+type WidgetResult struct {
+	Redirect     string
+	TemplateName string
+	Data         gin.H
+}
+
+// Fake remote widget service, actions without result render nothing.
+type FakeWidgetService struct {
+	Recorder
+	Actions []widgetservice.Action
+	Results map[string]WidgetResult // by action name
+}
+
+func (s *FakeWidgetService) GetDesc(ctx context.Context) ([]widgetservice.Action, error) {
+	if err := s.record("GetDesc"); err != nil {
+		return nil, err
+	}
+	return s.Actions, nil
+}
+
+func (s *FakeWidgetService) Process(ctx context.Context, actionName string, data gin.H, files map[string][]byte) (string, string, []byte, error) {
+	if err := s.record("Process", actionName, data, files); err != nil {
+		return "", "", nil, err
+	}
+
+	result := s.Results[actionName]
+	if result.Data == nil {
+		return result.Redirect, result.TemplateName, nil, nil
+	}
+	resData, err := json.Marshal(result.Data)
+	return result.Redirect, result.TemplateName, resData, err
+}
@@ -0,0 +1,417 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package puzzlewebtest
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+
+	adminservice "github.com/dvaumoron/puzzleweb/admin/service"
+	"github.com/dvaumoron/puzzleweb/common"
+	loginservice "github.com/dvaumoron/puzzleweb/login/service"
+	markdownservice "github.com/dvaumoron/puzzleweb/markdown/service"
+	profileservice "github.com/dvaumoron/puzzleweb/profile/service"
+	sessionservice "github.com/dvaumoron/puzzleweb/session/service"
+	"github.com/gin-gonic/gin"
+)
+
+// Most fakes wrap the in memory implementations of the standalone package,
+// their Store field allow to prepare data without recording calls.
+
+// Fake session service (also used for settings), sessions never expire.
+type FakeSessionService struct {
+	Recorder
+	Store sessionservice.SessionService
+}
+
+func (s *FakeSessionService) Generate(ctx context.Context) (uint64, error) {
+	if err := s.record("Generate"); err != nil {
+		return 0, err
+	}
+	return s.Store.Generate(ctx)
+}
+
+func (s *FakeSessionService) Get(ctx context.Context, id uint64) (map[string]string, error) {
+	if err := s.record("Get", id); err != nil {
+		return nil, err
+	}
+	return s.Store.Get(ctx, id)
+}
+
+func (s *FakeSessionService) Update(ctx context.Context, id uint64, info map[string]string) error {
+	if err := s.record("Update", id, info); err != nil {
+		return err
+	}
+	return s.Store.Update(ctx, id, info)
+}
+
+func (s *FakeSessionService) Delete(ctx context.Context, id uint64) error {
+	if err := s.record("Delete", id); err != nil {
+		return err
+	}
+	if deleter, ok := s.Store.(sessionservice.SessionDeleter); ok {
+		return deleter.Delete(ctx, id)
+	}
+	return s.Store.Update(ctx, id, map[string]string{})
+}
+
+// Create a session containing info, without recording calls.
+func (s *FakeSessionService) Create(info map[string]string) uint64 {
+	ctx := context.Background()
+	// the in memory implementation never fails
+	id, _ := s.Store.Generate(ctx)
+	s.Store.Update(ctx, id, info)
+	return id
+}
+
+// Content of a session, without recording calls.
+func (s *FakeSessionService) Session(id uint64) map[string]string {
+	info, _ := s.Store.Get(context.Background(), id)
+	return info
+}
+
+// Template name and data received by the template service.
+type Render struct {
+	Name string
+	Data gin.H
+}
+
+// Fake template service, the rendered output is the template name.
+type FakeTemplateService struct {
+	Recorder
+	Missing []string // unknown templates (HasTemplate is false and Render fails)
+}
+
+func (s *FakeTemplateService) Render(ctx context.Context, templateName string, data any) ([]byte, error) {
+	if err := s.record("Render", templateName, data); err != nil {
+		return nil, err
+	}
+	// a part of a missing page is missing too
+	if pageName, _, _ := strings.Cut(templateName, "#"); !s.HasTemplate(pageName) || !s.HasTemplate(templateName) {
+		return nil, common.ErrTechnical
+	}
+	return []byte(templateName), nil
+}
+
+// Not recorded (the calls are the renders).
+func (s *FakeTemplateService) HasTemplate(templateName string) bool {
+	return !slices.Contains(s.Missing, templateName)
+}
+
+// Renders received since the last Reset, in order.
+func (s *FakeTemplateService) Renders() []Render {
+	calls := s.CallsTo("Render")
+	renders := make([]Render, 0, len(calls))
+	for _, call := range calls {
+		data, _ := call.Args[1].(gin.H)
+		renders = append(renders, Render{Name: call.Args[0].(string), Data: data})
+	}
+	return renders
+}
+
+// Fake password strength service, every password is accepted.
+type FakePasswordStrengthService struct {
+	Recorder
+}
+
+func (s *FakePasswordStrengthService) Validate(ctx context.Context, password string) (bool, error) {
+	if err := s.record("Validate", password); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *FakePasswordStrengthService) GetRules(ctx context.Context, lang string) (string, error) {
+	return "", s.record("GetRules", lang)
+}
+
+type FakeLoginService struct {
+	Recorder
+	Store loginservice.FullLoginService
+}
+
+func (s *FakeLoginService) Verify(ctx context.Context, login string, password string) (uint64, error) {
+	if err := s.record("Verify", login, password); err != nil {
+		return 0, err
+	}
+	return s.Store.Verify(ctx, login, password)
+}
+
+func (s *FakeLoginService) Register(ctx context.Context, login string, password string) (uint64, error) {
+	if err := s.record("Register", login, password); err != nil {
+		return 0, err
+	}
+	return s.Store.Register(ctx, login, password)
+}
+
+func (s *FakeLoginService) ChangeLogin(ctx context.Context, userId uint64, oldLogin string, newLogin string, password string) error {
+	if err := s.record("ChangeLogin", userId, oldLogin, newLogin, password); err != nil {
+		return err
+	}
+	return s.Store.ChangeLogin(ctx, userId, oldLogin, newLogin, password)
+}
+
+func (s *FakeLoginService) ChangePassword(ctx context.Context, userId uint64, login string, oldPassword string, newPassword string) error {
+	if err := s.record("ChangePassword", userId, login, oldPassword, newPassword); err != nil {
+		return err
+	}
+	return s.Store.ChangePassword(ctx, userId, login, oldPassword, newPassword)
+}
+
+func (s *FakeLoginService) GetUsers(ctx context.Context, userIds []uint64) (map[uint64]loginservice.User, error) {
+	if err := s.record("GetUsers", userIds); err != nil {
+		return nil, err
+	}
+	return s.Store.GetUsers(ctx, userIds)
+}
+
+func (s *FakeLoginService) ListUsers(ctx context.Context, start uint64, end uint64, filter string) (uint64, []loginservice.User, error) {
+	if err := s.record("ListUsers", start, end, filter); err != nil {
+		return 0, nil, err
+	}
+	return s.Store.ListUsers(ctx, start, end, filter)
+}
+
+func (s *FakeLoginService) Delete(ctx context.Context, userId uint64) error {
+	if err := s.record("Delete", userId); err != nil {
+		return err
+	}
+	return s.Store.Delete(ctx, userId)
+}
+
+// Register a user without recording calls, the first one get the id 1.
+func (s *FakeLoginService) AddUser(login string, password string) uint64 {
+	userId, _ := s.Store.Register(context.Background(), login, password)
+	return userId
+}
+
+// Fake right service, every action is allowed when Authorize is nil.
+type FakeRightService struct {
+	Recorder
+	Authorize func(userId uint64, groupId uint64, action string) bool
+
+	mutex     sync.Mutex
+	groups    []adminservice.Group
+	userRoles map[uint64][]adminservice.Group
+}
+
+func NewFakeRightService() *FakeRightService {
+	return &FakeRightService{
+		groups: []adminservice.Group{
+			{Id: adminservice.PublicGroupId, Name: adminservice.PublicName},
+			{Id: adminservice.AdminGroupId, Name: adminservice.AdminName},
+		},
+		userRoles: map[uint64][]adminservice.Group{},
+	}
+}
+
+// Authorize function allowing everything to the listed users and only access to the others.
+func ReadOnlyExcept(userIds ...uint64) func(uint64, uint64, string) bool {
+	allowed := common.MakeSet(userIds)
+	return func(userId uint64, groupId uint64, action string) bool {
+		return action == adminservice.ActionAccess || allowed.Contains(userId)
+	}
+}
+
+func (s *FakeRightService) RegisterGroup(groupId uint64, groupName string) bool {
+	if s.record("RegisterGroup", groupId, groupName) != nil {
+		return false
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, group := range s.groups {
+		if group.Id == groupId {
+			return group.Name == groupName
+		}
+	}
+	s.groups = append(s.groups, adminservice.Group{Id: groupId, Name: groupName})
+	return true
+}
+
+func (s *FakeRightService) AuthQuery(ctx context.Context, userId uint64, groupId uint64, action string) error {
+	if err := s.record("AuthQuery", userId, groupId, action); err != nil {
+		return err
+	}
+	if s.Authorize == nil || s.Authorize(userId, groupId, action) {
+		return nil
+	}
+	return common.ErrNotAuthorized
+}
+
+func (s *FakeRightService) GetAllGroups(ctx context.Context, adminId uint64) ([]adminservice.Group, error) {
+	if err := s.record("GetAllGroups", adminId); err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]adminservice.Group(nil), s.groups...), nil
+}
+
+func (s *FakeRightService) GetActions(ctx context.Context, adminId uint64, roleName string, groupName string) ([]string, error) {
+	if err := s.record("GetActions", adminId, roleName, groupName); err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, group := range s.groups {
+		if group.Name == groupName {
+			for _, role := range group.Roles {
+				if role.Name == roleName {
+					return role.Actions, nil
+				}
+			}
+		}
+	}
+	return nil, nil
+}
+
+func (s *FakeRightService) UpdateUser(ctx context.Context, adminId uint64, userId uint64, roles []adminservice.Group) error {
+	if err := s.record("UpdateUser", adminId, userId, roles); err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.userRoles[userId] = roles
+	return nil
+}
+
+func (s *FakeRightService) UpdateRole(ctx context.Context, adminId uint64, roleName string, groupName string, actions []string) error {
+	if err := s.record("UpdateRole", adminId, roleName, groupName, actions); err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for index, group := range s.groups {
+		if group.Name == groupName {
+			roles := make([]adminservice.Role, 0, len(group.Roles)+1)
+			for _, role := range group.Roles {
+				if role.Name != roleName {
+					roles = append(roles, role)
+				}
+			}
+			if len(actions) != 0 {
+				roles = append(roles, adminservice.Role{Name: roleName, Actions: actions})
+			}
+			s.groups[index].Roles = roles
+			return nil
+		}
+	}
+	return common.ErrUpdate
+}
+
+func (s *FakeRightService) GetUserRoles(ctx context.Context, adminId uint64, userId uint64) ([]adminservice.Group, error) {
+	if err := s.record("GetUserRoles", adminId, userId); err != nil {
+		return nil, err
+	}
+	return s.getUserRoles(userId), nil
+}
+
+func (s *FakeRightService) ViewUserRoles(ctx context.Context, adminId uint64, userId uint64) (bool, []adminservice.Group, error) {
+	if err := s.record("ViewUserRoles", adminId, userId); err != nil {
+		return false, nil, err
+	}
+	return true, s.getUserRoles(userId), nil
+}
+
+func (s *FakeRightService) EditUserRoles(ctx context.Context, adminId uint64, userId uint64) ([]adminservice.Group, []adminservice.Group, error) {
+	if err := s.record("EditUserRoles", adminId, userId); err != nil {
+		return nil, nil, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.userRoles[userId], append([]adminservice.Group(nil), s.groups...), nil
+}
+
+func (s *FakeRightService) getUserRoles(userId uint64) []adminservice.Group {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.userRoles[userId]
+}
+
+type FakeProfileService struct {
+	Recorder
+	Store profileservice.AdvancedProfileService
+}
+
+func (s *FakeProfileService) GetProfiles(ctx context.Context, userIds []uint64) (map[uint64]profileservice.UserProfile, error) {
+	if err := s.record("GetProfiles", userIds); err != nil {
+		return nil, err
+	}
+	return s.Store.GetProfiles(ctx, userIds)
+}
+
+func (s *FakeProfileService) GetPicture(ctx context.Context, userId uint64) []byte {
+	s.record("GetPicture", userId)
+	return s.Store.GetPicture(ctx, userId)
+}
+
+func (s *FakeProfileService) UpdateProfile(ctx context.Context, userId uint64, desc string, info map[string]string) error {
+	if err := s.record("UpdateProfile", userId, desc, info); err != nil {
+		return err
+	}
+	return s.Store.UpdateProfile(ctx, userId, desc, info)
+}
+
+func (s *FakeProfileService) UpdatePicture(ctx context.Context, userId uint64, data []byte) error {
+	if err := s.record("UpdatePicture", userId, data); err != nil {
+		return err
+	}
+	return s.Store.UpdatePicture(ctx, userId, data)
+}
+
+func (s *FakeProfileService) Delete(ctx context.Context, userId uint64) error {
+	if err := s.record("Delete", userId); err != nil {
+		return err
+	}
+	return s.Store.Delete(ctx, userId)
+}
+
+func (s *FakeProfileService) ViewRight(ctx context.Context, userId uint64) error {
+	if err := s.record("ViewRight", userId); err != nil {
+		return err
+	}
+	return s.Store.ViewRight(ctx, userId)
+}
+
+type FakeMarkdownService struct {
+	Recorder
+	Store markdownservice.MarkdownService
+}
+
+func (s *FakeMarkdownService) Apply(ctx context.Context, text string) (string, error) {
+	if err := s.record("Apply", text); err != nil {
+		return "", err
+	}
+	return s.Store.Apply(ctx, text)
+}
@@ -0,0 +1,86 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package puzzlewebtest
+
+import "sync"
+
+// Call received by a fake service.
+type Call struct {
+	Method string
+	Args   []any
+}
+
+// Record the calls of a fake service and return the injected errors.
+type Recorder struct {
+	mutex  sync.Mutex
+	calls  []Call
+	errors map[string]error
+}
+
+// The next calls to method fail with err (a nil err removes the injection).
+func (r *Recorder) FailWith(method string, err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if err == nil {
+		delete(r.errors, method)
+		return
+	}
+	if r.errors == nil {
+		r.errors = map[string]error{}
+	}
+	r.errors[method] = err
+}
+
+func (r *Recorder) Calls() []Call {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return append([]Call(nil), r.calls...)
+}
+
+func (r *Recorder) CallsTo(method string) []Call {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var calls []Call
+	for _, call := range r.calls {
+		if call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// Forget the recorded calls and the injected errors.
+func (r *Recorder) Reset() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.calls = nil
+	r.errors = nil
+}
+
+func (r *Recorder) record(method string, args ...any) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.calls = append(r.calls, Call{Method: method, Args: args})
+	return r.errors[method]
+}
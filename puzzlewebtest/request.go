@@ -0,0 +1,183 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package puzzlewebtest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dvaumoron/puzzleweb/common"
+	puzzleweb "github.com/dvaumoron/puzzleweb/core"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	testCsrfToken = "testCsrfToken"
+	errorMsgName  = "ErrorMsg" // key of the message in the data of the error page
+)
+
+// Site under test, the fakes are reachable through the embedded builder.
+type TestSite struct {
+	*SiteBuilder
+	Site    *puzzleweb.Site
+	Handler http.Handler
+}
+
+// Client keeping its session between requests.
+type Client struct {
+	site       *TestSite
+	SessionId  uint64
+	UserId     uint64      // zero for anonymous
+	RemoteAddr string      // the one of httptest when empty
+	Header     http.Header // added to each request (like X-Forwarded-For)
+}
+
+func (s *TestSite) NewClient() *Client {
+	return &Client{site: s, SessionId: s.Sessions.Create(map[string]string{})}
+}
+
+// Client with the session of a connected user, the user is registered when unknown.
+func (s *TestSite) NewLoggedClient(login string) *Client {
+	userId := s.Logins.AddUser(login, login)
+	if userId == 0 {
+		// already registered
+		userId, _ = s.Logins.Store.Verify(context.Background(), login, login)
+	}
+	return &Client{site: s, SessionId: s.Sessions.Create(puzzleweb.MakeUserSession(userId, login)), UserId: userId}
+}
+
+// Serve the request with the session of the client (the session id is updated when the site renews it).
+func (c *Client) Do(request *http.Request) *Response {
+	templates := c.site.Templates
+	before := len(templates.Renders())
+
+	request.AddCookie(puzzleweb.MakeSessionCookie(c.SessionId))
+	if c.RemoteAddr != "" {
+		request.RemoteAddr = c.RemoteAddr
+	}
+	for name, values := range c.Header {
+		request.Header[name] = values
+	}
+	recorder := httptest.NewRecorder()
+	c.site.Handler.ServeHTTP(recorder, request)
+	// follow the renewal of the session
+	for _, cookie := range recorder.Result().Cookies() {
+		if sessionId, ok := puzzleweb.ParseSessionCookie(cookie); ok {
+			c.SessionId = sessionId
+		}
+	}
+	return &Response{ResponseRecorder: recorder, Renders: templates.Renders()[before:]}
+}
+
+func (c *Client) Get(target string) *Response {
+	return c.Do(httptest.NewRequest(http.MethodGet, target, nil))
+}
+
+// The anti-forgery token of the session is added to values.
+func (c *Client) PostForm(target string, values url.Values) *Response {
+	if values == nil {
+		values = url.Values{}
+	}
+	values.Set(puzzleweb.CsrfTokenName, c.csrfToken())
+
+	request := httptest.NewRequest(http.MethodPost, target, strings.NewReader(values.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return c.Do(request)
+}
+
+func (c *Client) csrfToken() string {
+	sessions := c.site.Sessions
+	info := sessions.Session(c.SessionId)
+	token := info[puzzleweb.CsrfTokenName]
+	if token == "" {
+		token = testCsrfToken
+		info[puzzleweb.CsrfTokenName] = token
+		sessions.Store.Update(context.Background(), c.SessionId, info)
+	}
+	return token
+}
+
+// Recorded response with the renders asked to the template service during the request.
+type Response struct {
+	*httptest.ResponseRecorder
+	Renders []Render
+}
+
+// Last render of the request (zero value when nothing was rendered).
+func (r *Response) Render() Render {
+	if last := len(r.Renders) - 1; last >= 0 {
+		return r.Renders[last]
+	}
+	return Render{}
+}
+
+func (r *Response) AssertStatus(t testing.TB, status int) {
+	t.Helper()
+	if r.Code != status {
+		t.Errorf("status is %d, expected %d", r.Code, status)
+	}
+}
+
+func (r *Response) AssertTemplate(t testing.TB, templateName string) {
+	t.Helper()
+	if name := r.Render().Name; name != templateName {
+		t.Errorf("rendered template is %q, expected %q", name, templateName)
+	}
+}
+
+// Compare (with reflect.DeepEqual) the value of key in the data given to the template.
+func (r *Response) AssertData(t testing.TB, key string, expected any) {
+	t.Helper()
+	value, ok := r.Render().Data[key]
+	if !ok {
+		t.Errorf("template data has no %q", key)
+		return
+	}
+	if !reflect.DeepEqual(value, expected) {
+		t.Errorf("template data %q is %#v, expected %#v", key, value, expected)
+	}
+}
+
+// Rendering of the error page with the status and message key corresponding to err.
+func (r *Response) AssertError(t testing.TB, err error) {
+	t.Helper()
+	status, errorKey := common.ExtractStatusAndKey(zap.NewNop(), err)
+	r.AssertStatus(t, status)
+	r.AssertTemplate(t, puzzleweb.ErrorTemplateName)
+	r.AssertData(t, errorMsgName, errorKey)
+}
+
+func (r *Response) AssertRedirect(t testing.TB, location string) {
+	t.Helper()
+	r.AssertStatus(t, http.StatusFound)
+	if actual := r.Header().Get("Location"); actual != location {
+		t.Errorf("redirected to %q, expected %q", actual, location)
+	}
+}
+
+// Data given to the template in the last render.
+func (r *Response) Data() gin.H {
+	return r.Render().Data
+}
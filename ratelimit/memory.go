@@ -0,0 +1,85 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const purgeInterval = time.Minute
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	full   time.Time // after it, the bucket is equivalent to a new one
+}
+
+type memoryStore struct {
+	mutex     sync.Mutex
+	buckets   map[string]*bucket
+	lastPurge time.Time
+}
+
+// Buckets are kept in process memory, so each replica has its own limits.
+func NewMemoryStore() Store {
+	return &memoryStore{buckets: map[string]*bucket{}, lastPurge: time.Now()}
+}
+
+func (s *memoryStore) Take(ctx context.Context, key string, limit Limit) (time.Duration, error) {
+	now := time.Now()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.purge(now)
+
+	b := s.buckets[key]
+	if b == nil {
+		b = &bucket{tokens: limit.Burst}
+		s.buckets[key] = b
+	} else {
+		b.tokens = min(limit.Burst, b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+	}
+	b.last = now
+
+	var wait time.Duration
+	if b.tokens >= 1 {
+		b.tokens--
+	} else {
+		wait = time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second))
+	}
+	b.full = now.Add(time.Duration((limit.Burst - b.tokens) / limit.Rate * float64(time.Second)))
+	return wait, nil
+}
+
+// keep memory bounded by forgetting the full buckets, must be called with the mutex locked
+func (s *memoryStore) purge(now time.Time) {
+	if now.Sub(s.lastPurge) < purgeInterval {
+		return
+	}
+	s.lastPurge = now
+
+	for key, b := range s.buckets {
+		if now.After(b.full) {
+			delete(s.buckets, key)
+		}
+	}
+}
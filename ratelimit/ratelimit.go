@@ -0,0 +1,78 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// route classes configurable in frame.hcl
+const (
+	LoginClass        = "login"
+	RegisterClass     = "register"
+	ContentClass      = "content"
+	RemoteWidgetClass = "remoteWidget"
+)
+
+type Limit struct {
+	Rate  float64 // tokens added by second
+	Burst float64 // bucket capacity
+}
+
+// The burst defaults to the number of requests by period.
+func MakeLimit(requests uint64, period time.Duration, burst uint64) Limit {
+	if burst == 0 {
+		burst = requests
+	}
+	return Limit{Rate: float64(requests) / period.Seconds(), Burst: float64(burst)}
+}
+
+// A shared implementation allows to apply the limits across replicas.
+type Store interface {
+	// Take a token in the bucket identified by key, return the duration to wait when there is none (zero otherwise).
+	Take(ctx context.Context, key string, limit Limit) (time.Duration, error)
+}
+
+type Limiter struct {
+	store  Store
+	limits map[string]Limit
+}
+
+func NewLimiter(store Store, limits map[string]Limit) *Limiter {
+	return &Limiter{store: store, limits: limits}
+}
+
+// Take a token in the bucket of each key, there is no limit for an unconfigured class.
+func (l *Limiter) Allow(ctx context.Context, class string, keys ...string) (time.Duration, error) {
+	limit, ok := l.limits[class]
+	if !ok {
+		return 0, nil
+	}
+
+	var wait time.Duration
+	for _, key := range keys {
+		keyWait, err := l.store.Take(ctx, class+":"+key, limit)
+		if err != nil {
+			return 0, err
+		}
+		wait = max(wait, keyWait)
+	}
+	return wait, nil
+}
@@ -0,0 +1,75 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMakeLimit(t *testing.T) {
+	if limit := MakeLimit(10, time.Minute, 0); limit.Burst != 10 || limit.Rate != 10.0/60 {
+		t.Errorf("unexpected limit : %+v", limit)
+	}
+	if limit := MakeLimit(1, time.Second, 5); limit.Burst != 5 || limit.Rate != 1 {
+		t.Errorf("unexpected limit : %+v", limit)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	limit := MakeLimit(2, time.Minute, 0)
+
+	for index := 0; index < 2; index++ {
+		if wait, err := store.Take(ctx, "a", limit); err != nil || wait != 0 {
+			t.Fatalf("request %d refused : %v, %v", index, wait, err)
+		}
+	}
+	wait, err := store.Take(ctx, "a", limit)
+	if err != nil || wait <= 0 || wait > 30*time.Second {
+		t.Errorf("unexpected wait : %v, %v", wait, err)
+	}
+	if wait, _ = store.Take(ctx, "b", limit); wait != 0 {
+		t.Error("buckets are not independent")
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	ctx := context.Background()
+	limiter := NewLimiter(NewMemoryStore(), map[string]Limit{LoginClass: MakeLimit(1, time.Minute, 0)})
+
+	if wait, err := limiter.Allow(ctx, LoginClass, "ip:1", "login:alice"); err != nil || wait != 0 {
+		t.Fatalf("first request refused : %v, %v", wait, err)
+	}
+	// a single exhausted bucket is enough to wait
+	if wait, _ := limiter.Allow(ctx, LoginClass, "ip:2", "login:alice"); wait == 0 {
+		t.Error("exhausted bucket not detected")
+	}
+	if wait, _ := limiter.Allow(ctx, LoginClass, "ip:3", "login:bob"); wait != 0 {
+		t.Error("classes and keys are not independent")
+	}
+	// no limit for an unconfigured class
+	for index := 0; index < 3; index++ {
+		if wait, _ := limiter.Allow(ctx, ContentClass, "ip:1"); wait != 0 {
+			t.Error("unconfigured class limited")
+		}
+	}
+}
@@ -26,6 +26,7 @@ import (
 	grpcclient "github.com/dvaumoron/puzzlegrpcclient"
 	"github.com/dvaumoron/puzzleweb/common"
 	"github.com/dvaumoron/puzzleweb/common/log"
+	"github.com/dvaumoron/puzzleweb/locale"
 	widgetservice "github.com/dvaumoron/puzzleweb/remotewidget/service"
 	pb "github.com/dvaumoron/puzzlewidgetservice"
 	"github.com/gin-gonic/gin"
@@ -65,7 +66,7 @@ func (client widgetClient) GetDesc(ctx context.Context) ([]widgetservice.Action,
 func (client widgetClient) Process(ctx context.Context, actionName string, data gin.H, files map[string][]byte) (string, string, []byte, error) {
 	data[widgetservice.ObjectIdKey] = client.objectId
 	data[widgetservice.GroupIdKey] = client.groupId
-	dataBytes, err := json.Marshal(data)
+	dataBytes, err := json.Marshal(locale.FormatDates(data))
 	if err != nil {
 		client.loggerGetter.Logger(ctx).Error("Failed to marshal data", zap.Error(err))
 		return "", "", nil, common.ErrTechnical
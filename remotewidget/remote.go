@@ -29,6 +29,7 @@ import (
 	"github.com/dvaumoron/puzzleweb/common"
 	"github.com/dvaumoron/puzzleweb/common/config"
 	puzzleweb "github.com/dvaumoron/puzzleweb/core"
+	"github.com/dvaumoron/puzzleweb/ratelimit"
 	widgetservice "github.com/dvaumoron/puzzleweb/remotewidget/service"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -42,9 +43,10 @@ var (
 )
 
 type handlerDesc struct {
-	httpMethod string
-	path       string
-	handler    gin.HandlerFunc
+	httpMethod  string
+	path        string
+	handler     gin.HandlerFunc
+	rateLimited bool
 }
 
 type remoteWidget struct {
@@ -52,8 +54,13 @@ type remoteWidget struct {
 }
 
 func (w remoteWidget) LoadInto(router gin.IRouter) {
+	rateLimiter := puzzleweb.RateLimit(ratelimit.RemoteWidgetClass)
 	for _, desc := range w.handlers {
-		router.Handle(desc.httpMethod, desc.path, desc.handler)
+		if desc.rateLimited {
+			router.Handle(desc.httpMethod, desc.path, rateLimiter, desc.handler)
+		} else {
+			router.Handle(desc.httpMethod, desc.path, desc.handler)
+		}
 	}
 }
 
@@ -71,18 +78,21 @@ func MakeRemotePage(pageName string, initCtx context.Context, remoteConfig confi
 		pathKeys := extractKeysFromPath(action.Path)
 		queryKeys := extractQueryKeys(action.QueryNames)
 		var handler gin.HandlerFunc
+		rateLimited := false
 		switch httpMethod {
 		case http.MethodGet, http.MethodHead, http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
 			dataAdder := func(data gin.H, c *gin.Context) {
 				retrieveContextData(pathKeys, queryKeys, data, c)
 			}
 			handler = createHandler(action.Name, dataAdder, widgetService)
+			rateLimited = httpMethod == http.MethodDelete
 		case http.MethodPost, http.MethodPut, http.MethodPatch:
 			dataAdder := func(data gin.H, c *gin.Context) {
 				data[widgetservice.FormKey] = c.PostFormMap(widgetservice.FormKey)
 				retrieveContextData(pathKeys, queryKeys, data, c)
 			}
 			handler = createHandler(action.Name, dataAdder, widgetService)
+			rateLimited = true
 		case widgetservice.RawResult:
 			httpMethod = http.MethodGet
 			handler = createRawHandler(action.Name, pathKeys, queryKeys, widgetService)
@@ -90,7 +100,9 @@ func MakeRemotePage(pageName string, initCtx context.Context, remoteConfig confi
 			remoteConfig.Logger.Error(initMsg, zap.String("unknownActionKind", httpMethod))
 			return puzzleweb.Page{}, false
 		}
-		handlers = append(handlers, handlerDesc{httpMethod: httpMethod, path: action.Path, handler: handler})
+		handlers = append(handlers, handlerDesc{
+			httpMethod: httpMethod, path: action.Path, handler: handler, rateLimited: rateLimited,
+		})
 	}
 
 	p := puzzleweb.MakePage(pageName)
@@ -176,11 +188,11 @@ func createHandler(actionName string, dataAdder common.DataAdder, widgetService
 		files, err := readFiles(c)
 		if err != nil {
 			logger.Error("Failed to retrieve post file", zap.Error(err))
-			return "", common.DefaultErrorRedirect(logger, common.ErrorTechnicalKey)
+			return common.ErrorPage(c, common.ErrTechnical)
 		}
 		redirect, templateName, resData, err := widgetService.Process(c.Request.Context(), actionName, data, files)
 		if err != nil {
-			return "", common.DefaultErrorRedirect(logger, err.Error())
+			return common.ErrorPage(c, err)
 		}
 		if redirect != "" {
 			return "", redirect
@@ -188,7 +200,7 @@ func createHandler(actionName string, dataAdder common.DataAdder, widgetService
 
 		if err = updateDataAndSession(data, resData, c); err != nil {
 			logger.Error("Failed to unmarshal json from remote widget", zap.Error(err))
-			return "", common.DefaultErrorRedirect(logger, common.ErrorTechnicalKey)
+			return common.ErrorPage(c, common.ErrTechnical)
 		}
 		return templateName, ""
 	})
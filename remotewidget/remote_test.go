@@ -0,0 +1,104 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package remotewidget_test
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	adminservice "github.com/dvaumoron/puzzleweb/admin/service"
+	"github.com/dvaumoron/puzzleweb/common"
+	puzzleweb "github.com/dvaumoron/puzzleweb/core"
+	"github.com/dvaumoron/puzzleweb/puzzlewebtest"
+	widgetservice "github.com/dvaumoron/puzzleweb/remotewidget/service"
+	"github.com/gin-gonic/gin"
+)
+
+func TestGetAction(t *testing.T) {
+	builder := puzzlewebtest.NewSiteBuilder(t)
+	widget := builder.AddRemoteWidget("shop", 1, adminservice.PublicGroupId, widgetservice.Action{
+		Kind: http.MethodGet, Name: "item", Path: "/item/:itemId", QueryNames: []string{"color"},
+	})
+	widget.Results["item"] = puzzlewebtest.WidgetResult{
+		TemplateName: "shop/item", Data: gin.H{"Price": "12", puzzleweb.SessionName: map[string]string{"LastItem": "42"}},
+	}
+	site := builder.Build()
+	client := site.NewClient()
+
+	response := client.Get("/shop/item/42?color=red")
+	response.AssertStatus(t, http.StatusOK)
+	response.AssertTemplate(t, "shop/item")
+	response.AssertData(t, "Price", "12")
+
+	calls := widget.CallsTo("Process")
+	if len(calls) != 1 {
+		t.Fatalf("Process called %d times, expected 1", len(calls))
+	}
+	sent := calls[0].Args[1].(gin.H)
+	if sent[widgetservice.PathKeySlash+"itemId"] != "42" || sent[widgetservice.QueryKeySlash+"color"] != "red" {
+		t.Errorf("unexpected data sent : %v", sent)
+	}
+	if lastItem := site.Sessions.Session(client.SessionId)["LastItem"]; lastItem != "42" {
+		t.Errorf("session not updated : %q", lastItem)
+	}
+}
+
+func TestPostAction(t *testing.T) {
+	builder := puzzlewebtest.NewSiteBuilder(t)
+	widget := builder.AddRemoteWidget("shop", 1, adminservice.PublicGroupId, widgetservice.Action{
+		Kind: http.MethodPost, Name: "buy", Path: "/buy",
+	})
+	widget.Results["buy"] = puzzlewebtest.WidgetResult{Redirect: "/shop/done"}
+	site := builder.Build()
+
+	response := site.NewClient().PostForm("/shop/buy", url.Values{"formData[quantity]": {"3"}})
+	response.AssertRedirect(t, "/shop/done")
+
+	sent := widget.CallsTo("Process")[0].Args[1].(gin.H)
+	if form, _ := sent[widgetservice.FormKey].(map[string]string); form["quantity"] != "3" {
+		t.Errorf("unexpected form sent : %v", sent[widgetservice.FormKey])
+	}
+}
+
+func TestRawAction(t *testing.T) {
+	builder := puzzlewebtest.NewSiteBuilder(t)
+	widget := builder.AddRemoteWidget("shop", 1, adminservice.PublicGroupId, widgetservice.Action{
+		Kind: widgetservice.RawResult, Name: "price", Path: "/price",
+	})
+	widget.Results["price"] = puzzlewebtest.WidgetResult{Data: gin.H{"Price": "12"}}
+	site := builder.Build()
+
+	response := site.NewClient().Get("/shop/price")
+	response.AssertStatus(t, http.StatusOK)
+	if body := response.Body.String(); body != `{"Price":"12"}` {
+		t.Errorf("unexpected body : %s", body)
+	}
+}
+
+func TestProcessFailure(t *testing.T) {
+	builder := puzzlewebtest.NewSiteBuilder(t)
+	widget := builder.AddRemoteWidget("shop", 1, adminservice.PublicGroupId, widgetservice.Action{
+		Kind: http.MethodGet, Name: "list", Path: "/",
+	})
+	site := builder.Build()
+
+	widget.FailWith("Process", common.ErrNotFound)
+	site.NewClient().Get("/shop/").AssertError(t, common.ErrNotFound)
+}
@@ -25,3 +25,8 @@ type SessionService interface {
 	Get(ctx context.Context, id uint64) (map[string]string, error)
 	Update(ctx context.Context, id uint64, info map[string]string) error
 }
+
+// Optional interface, without it the renewed sessions are emptied instead of deleted.
+type SessionDeleter interface {
+	Delete(ctx context.Context, id uint64) error
+}
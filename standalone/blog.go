@@ -0,0 +1,164 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package standalone
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	adminservice "github.com/dvaumoron/puzzleweb/admin/service"
+	blogservice "github.com/dvaumoron/puzzleweb/blog/service"
+	"github.com/dvaumoron/puzzleweb/common"
+	profileservice "github.com/dvaumoron/puzzleweb/profile/service"
+)
+
+type blogPost struct {
+	Id        uint64
+	UserId    uint64
+	Title     string
+	Text      string
+	CreatedAt int64
+}
+
+type blogState struct {
+	Posts  map[uint64]blogPost
+	LastId uint64
+}
+
+type blogService struct {
+	*persisted[blogState]
+	groupId        uint64
+	authService    adminservice.AuthService
+	profileService profileservice.ProfileService
+}
+
+func (s blogService) CreatePost(ctx context.Context, userId uint64, title string, content string) (uint64, error) {
+	err := s.authService.AuthQuery(ctx, userId, s.groupId, adminservice.ActionCreate)
+	if err != nil {
+		return 0, err
+	}
+
+	var postId uint64
+	err = s.update(func(state *blogState) error {
+		state.LastId++
+		postId = state.LastId
+		state.Posts[postId] = blogPost{
+			Id: postId, UserId: userId, Title: title, Text: content, CreatedAt: time.Now().Unix(),
+		}
+		return nil
+	})
+	return postId, err
+}
+
+func (s blogService) GetPost(ctx context.Context, userId uint64, postId uint64) (blogservice.BlogPost, error) {
+	err := s.authService.AuthQuery(ctx, userId, s.groupId, adminservice.ActionAccess)
+	if err != nil {
+		return blogservice.BlogPost{}, err
+	}
+
+	var post blogPost
+	var found bool
+	s.read(func(state *blogState) {
+		post, found = state.Posts[postId]
+	})
+	if !found {
+		return blogservice.BlogPost{}, common.ErrNotFound
+	}
+
+	users, err := s.profileService.GetProfiles(ctx, []uint64{post.UserId})
+	if err != nil {
+		return blogservice.BlogPost{}, err
+	}
+	return s.convertPost(post, users[post.UserId]), nil
+}
+
+func (s blogService) GetPosts(ctx context.Context, userId uint64, start uint64, end uint64, filter string) (uint64, []blogservice.BlogPost, error) {
+	err := s.authService.AuthQuery(ctx, userId, s.groupId, adminservice.ActionAccess)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var posts []blogPost
+	s.read(func(state *blogState) {
+		for _, post := range state.Posts {
+			if strings.Contains(post.Title, filter) {
+				posts = append(posts, post)
+			}
+		}
+	})
+
+	// most recent first
+	slices.SortFunc(posts, func(a blogPost, b blogPost) int {
+		return cmp.Compare(b.Id, a.Id)
+	})
+	total := uint64(len(posts))
+	posts = extractRange(posts, start, end)
+	if len(posts) == 0 {
+		return total, nil, nil
+	}
+
+	// no duplicate check, there is one in GetProfiles
+	userIds := make([]uint64, 0, len(posts))
+	for _, post := range posts {
+		userIds = append(userIds, post.UserId)
+	}
+	users, err := s.profileService.GetProfiles(ctx, userIds)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	converted := make([]blogservice.BlogPost, 0, len(posts))
+	for _, post := range posts {
+		converted = append(converted, s.convertPost(post, users[post.UserId]))
+	}
+	return total, converted, nil
+}
+
+func (s blogService) DeletePost(ctx context.Context, userId uint64, postId uint64) error {
+	err := s.authService.AuthQuery(ctx, userId, s.groupId, adminservice.ActionDelete)
+	if err != nil {
+		return err
+	}
+
+	return s.update(func(state *blogState) error {
+		if _, found := state.Posts[postId]; !found {
+			return common.ErrUpdate
+		}
+		delete(state.Posts, postId)
+		return nil
+	})
+}
+
+func (s blogService) CreateRight(ctx context.Context, userId uint64) bool {
+	return s.authService.AuthQuery(ctx, userId, s.groupId, adminservice.ActionCreate) == nil
+}
+
+func (s blogService) DeleteRight(ctx context.Context, userId uint64) bool {
+	return s.authService.AuthQuery(ctx, userId, s.groupId, adminservice.ActionDelete) == nil
+}
+
+func (s blogService) convertPost(post blogPost, creator profileservice.UserProfile) blogservice.BlogPost {
+	return blogservice.BlogPost{
+		PostId: post.Id, Creator: creator, Date: time.Unix(post.CreatedAt, 0),
+		Title: post.Title, Content: post.Text,
+	}
+}
@@ -0,0 +1,329 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package standalone
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	adminservice "github.com/dvaumoron/puzzleweb/admin/service"
+	"github.com/dvaumoron/puzzleweb/common"
+	forumservice "github.com/dvaumoron/puzzleweb/forum/service"
+	profileservice "github.com/dvaumoron/puzzleweb/profile/service"
+)
+
+type forumMessage struct {
+	Id        uint64
+	UserId    uint64
+	Text      string
+	CreatedAt int64
+}
+
+type forumThread struct {
+	UserId    uint64
+	Title     string
+	CreatedAt int64
+	Messages  []forumMessage // in creation order
+}
+
+type forumState struct {
+	Threads map[uint64]*forumThread
+	LastId  uint64 // shared by threads and messages
+}
+
+// Forum and comments of the objects (like blog post) of a container.
+type forumService struct {
+	*persisted[forumState]
+	groupId        uint64
+	authService    adminservice.AuthService
+	profileService profileservice.ProfileService
+}
+
+func (s forumService) CreateThread(ctx context.Context, userId uint64, title string, message string) (uint64, error) {
+	err := s.authService.AuthQuery(ctx, userId, s.groupId, adminservice.ActionCreate)
+	if err != nil {
+		return 0, err
+	}
+	return s.createThread(userId, title, message)
+}
+
+func (s forumService) CreateCommentThread(ctx context.Context, userId uint64, elemTitle string) error {
+	err := s.authService.AuthQuery(ctx, userId, s.groupId, adminservice.ActionCreate)
+	if err != nil {
+		return err
+	}
+	_, err = s.createThread(userId, elemTitle, "")
+	return err
+}
+
+func (s forumService) CreateMessage(ctx context.Context, userId uint64, threadId uint64, message string) error {
+	err := s.authService.AuthQuery(ctx, userId, s.groupId, adminservice.ActionUpdate)
+	if err != nil {
+		return err
+	}
+
+	return s.update(func(state *forumState) error {
+		thread := state.Threads[threadId]
+		if thread == nil {
+			return common.ErrUpdate
+		}
+		addMessage(state, thread, userId, message)
+		return nil
+	})
+}
+
+func (s forumService) CreateComment(ctx context.Context, userId uint64, elemTitle string, comment string) error {
+	err := s.authService.AuthQuery(ctx, userId, s.groupId, adminservice.ActionAccess)
+	if err != nil {
+		return err
+	}
+
+	return s.update(func(state *forumState) error {
+		if _, thread := searchCommentThread(state, elemTitle); thread != nil {
+			addMessage(state, thread, userId, comment)
+		} else {
+			createThread(state, userId, elemTitle, comment)
+		}
+		return nil
+	})
+}
+
+func (s forumService) GetThread(ctx context.Context, userId uint64, threadId uint64, start uint64, end uint64, filter string) (uint64, forumservice.ForumContent, []forumservice.ForumContent, error) {
+	err := s.authService.AuthQuery(ctx, userId, s.groupId, adminservice.ActionAccess)
+	if err != nil {
+		return 0, forumservice.ForumContent{}, nil, err
+	}
+
+	var threadMessage forumMessage
+	var messages []forumMessage
+	s.read(func(state *forumState) {
+		if thread := state.Threads[threadId]; thread != nil {
+			threadMessage = forumMessage{Id: threadId, UserId: thread.UserId, Text: thread.Title, CreatedAt: thread.CreatedAt}
+			messages = filterMessages(thread.Messages, filter)
+		}
+	})
+	if threadMessage.Id == 0 {
+		return 0, forumservice.ForumContent{}, nil, common.ErrNotFound
+	}
+
+	total := uint64(len(messages))
+	messages = extractRange(messages, start, end)
+	contents, err := s.convertMessages(ctx, append(messages, threadMessage))
+	if err != nil {
+		return 0, forumservice.ForumContent{}, nil, err
+	}
+	last := len(contents) - 1
+	return total, contents[last], contents[:last], nil
+}
+
+func (s forumService) GetThreads(ctx context.Context, userId uint64, start uint64, end uint64, filter string) (uint64, []forumservice.ForumContent, error) {
+	err := s.authService.AuthQuery(ctx, userId, s.groupId, adminservice.ActionAccess)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var threads []forumMessage
+	s.read(func(state *forumState) {
+		for threadId, thread := range state.Threads {
+			if strings.Contains(thread.Title, filter) {
+				threads = append(threads, forumMessage{
+					Id: threadId, UserId: thread.UserId, Text: thread.Title, CreatedAt: thread.CreatedAt,
+				})
+			}
+		}
+	})
+
+	// most recent first
+	slices.SortFunc(threads, func(a forumMessage, b forumMessage) int {
+		return cmp.Compare(b.Id, a.Id)
+	})
+	total := uint64(len(threads))
+	contents, err := s.convertMessages(ctx, extractRange(threads, start, end))
+	return total, contents, err
+}
+
+func (s forumService) GetCommentThread(ctx context.Context, userId uint64, elemTitle string, start uint64, end uint64) (uint64, []forumservice.ForumContent, error) {
+	err := s.authService.AuthQuery(ctx, userId, s.groupId, adminservice.ActionAccess)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var messages []forumMessage
+	s.read(func(state *forumState) {
+		if _, thread := searchCommentThread(state, elemTitle); thread != nil {
+			messages = slices.Clone(thread.Messages)
+		}
+	})
+
+	total := uint64(len(messages))
+	contents, err := s.convertMessages(ctx, extractRange(messages, start, end))
+	return total, contents, err
+}
+
+func (s forumService) DeleteThread(ctx context.Context, userId uint64, threadId uint64) error {
+	err := s.authService.AuthQuery(ctx, userId, s.groupId, adminservice.ActionDelete)
+	if err != nil {
+		return err
+	}
+
+	return s.update(func(state *forumState) error {
+		if state.Threads[threadId] == nil {
+			return common.ErrUpdate
+		}
+		delete(state.Threads, threadId)
+		return nil
+	})
+}
+
+func (s forumService) DeleteCommentThread(ctx context.Context, userId uint64, elemTitle string) error {
+	err := s.authService.AuthQuery(ctx, userId, s.groupId, adminservice.ActionDelete)
+	if err != nil {
+		return err
+	}
+
+	return s.update(func(state *forumState) error {
+		if threadId, thread := searchCommentThread(state, elemTitle); thread != nil {
+			delete(state.Threads, threadId)
+		}
+		return nil
+	})
+}
+
+func (s forumService) DeleteMessage(ctx context.Context, userId uint64, threadId uint64, messageId uint64) error {
+	err := s.authService.AuthQuery(ctx, userId, s.groupId, adminservice.ActionDelete)
+	if err != nil {
+		return err
+	}
+
+	return s.update(func(state *forumState) error {
+		return deleteMessage(state.Threads[threadId], messageId)
+	})
+}
+
+func (s forumService) DeleteComment(ctx context.Context, userId uint64, elemTitle string, commentId uint64) error {
+	err := s.authService.AuthQuery(ctx, userId, s.groupId, adminservice.ActionDelete)
+	if err != nil {
+		return err
+	}
+
+	return s.update(func(state *forumState) error {
+		_, thread := searchCommentThread(state, elemTitle)
+		return deleteMessage(thread, commentId)
+	})
+}
+
+func (s forumService) CreateThreadRight(ctx context.Context, userId uint64) bool {
+	return s.authService.AuthQuery(ctx, userId, s.groupId, adminservice.ActionCreate) == nil
+}
+
+func (s forumService) CreateMessageRight(ctx context.Context, userId uint64) bool {
+	return s.authService.AuthQuery(ctx, userId, s.groupId, adminservice.ActionUpdate) == nil
+}
+
+func (s forumService) DeleteRight(ctx context.Context, userId uint64) bool {
+	return s.authService.AuthQuery(ctx, userId, s.groupId, adminservice.ActionDelete) == nil
+}
+
+func (s forumService) createThread(userId uint64, title string, message string) (uint64, error) {
+	var threadId uint64
+	err := s.update(func(state *forumState) error {
+		threadId = createThread(state, userId, title, message)
+		return nil
+	})
+	return threadId, err
+}
+
+func (s forumService) convertMessages(ctx context.Context, messages []forumMessage) ([]forumservice.ForumContent, error) {
+	if len(messages) == 0 {
+		return nil, nil
+	}
+
+	// no duplicate check, there is one in GetProfiles
+	userIds := make([]uint64, 0, len(messages))
+	for _, message := range messages {
+		userIds = append(userIds, message.UserId)
+	}
+	users, err := s.profileService.GetProfiles(ctx, userIds)
+	if err != nil {
+		return nil, err
+	}
+
+	contents := make([]forumservice.ForumContent, 0, len(messages))
+	for _, message := range messages {
+		contents = append(contents, forumservice.ForumContent{
+			Id: message.Id, Creator: users[message.UserId],
+			Date: time.Unix(message.CreatedAt, 0), Text: message.Text,
+		})
+	}
+	return contents, nil
+}
+
+// an empty message create a thread without message
+func createThread(state *forumState, userId uint64, title string, message string) uint64 {
+	state.LastId++
+	threadId := state.LastId
+	thread := &forumThread{UserId: userId, Title: title, CreatedAt: time.Now().Unix()}
+	if message != "" {
+		addMessage(state, thread, userId, message)
+	}
+	state.Threads[threadId] = thread
+	return threadId
+}
+
+func addMessage(state *forumState, thread *forumThread, userId uint64, message string) {
+	state.LastId++
+	thread.Messages = append(thread.Messages, forumMessage{
+		Id: state.LastId, UserId: userId, Text: message, CreatedAt: time.Now().Unix(),
+	})
+}
+
+func deleteMessage(thread *forumThread, messageId uint64) error {
+	if thread != nil {
+		for index, message := range thread.Messages {
+			if message.Id == messageId {
+				thread.Messages = append(thread.Messages[:index:index], thread.Messages[index+1:]...)
+				return nil
+			}
+		}
+	}
+	return common.ErrUpdate
+}
+
+// the comments of an object are in the thread with the object title
+func searchCommentThread(state *forumState, elemTitle string) (uint64, *forumThread) {
+	for threadId, thread := range state.Threads {
+		if thread.Title == elemTitle {
+			return threadId, thread
+		}
+	}
+	return 0, nil
+}
+
+func filterMessages(messages []forumMessage, filter string) []forumMessage {
+	filtered := make([]forumMessage, 0, len(messages))
+	for _, message := range messages {
+		if strings.Contains(message.Text, filter) {
+			filtered = append(filtered, message)
+		}
+	}
+	return filtered
+}
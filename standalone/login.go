@@ -0,0 +1,202 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package standalone
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dvaumoron/puzzleweb/common"
+	loginservice "github.com/dvaumoron/puzzleweb/login/service"
+	strengthservice "github.com/dvaumoron/puzzleweb/passwordstrength/service"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const minPasswordLength = 8
+
+type userRecord struct {
+	Login       string
+	Hash        []byte
+	RegistredAt int64
+}
+
+type loginService struct {
+	*persistedRecords[userRecord]
+	strengthService strengthservice.PasswordStrengthService
+}
+
+func newLoginService(storage Storage, strengthService strengthservice.PasswordStrengthService) (loginservice.FullLoginService, error) {
+	p, err := newPersistedRecords[userRecord](storage, "login")
+	if err != nil {
+		return nil, err
+	}
+	return loginService{persistedRecords: p, strengthService: strengthService}, nil
+}
+
+func (s loginService) Verify(ctx context.Context, login string, password string) (uint64, error) {
+	var userId uint64
+	var hash []byte
+	s.read(func(users map[uint64]*userRecord) {
+		userId, hash = findLogin(users, login)
+	})
+	if userId == 0 || bcrypt.CompareHashAndPassword(hash, []byte(password)) != nil {
+		return 0, common.ErrWrongLogin
+	}
+	return userId, nil
+}
+
+func (s loginService) Register(ctx context.Context, login string, password string) (uint64, error) {
+	hash, err := s.hashPassword(ctx, password)
+	if err != nil {
+		return 0, err
+	}
+
+	var userId uint64
+	err = s.update(func(users map[uint64]*userRecord) ([]uint64, error) {
+		if existingId, _ := findLogin(users, login); existingId != 0 {
+			return nil, common.ErrExistingLogin
+		}
+		// the ids of deleted users are not reused
+		var err error
+		if userId, err = s.storage.NextId(s.name); err != nil {
+			return nil, err
+		}
+		users[userId] = &userRecord{Login: login, Hash: hash, RegistredAt: time.Now().Unix()}
+		return []uint64{userId}, nil
+	})
+	return userId, err
+}
+
+func (s loginService) ChangeLogin(ctx context.Context, userId uint64, oldLogin string, newLogin string, password string) error {
+	return s.update(func(users map[uint64]*userRecord) ([]uint64, error) {
+		user := users[userId]
+		if user == nil || user.Login != oldLogin || bcrypt.CompareHashAndPassword(user.Hash, []byte(password)) != nil {
+			return nil, common.ErrUpdate
+		}
+		if existingId, _ := findLogin(users, newLogin); existingId != 0 {
+			return nil, common.ErrExistingLogin
+		}
+		user.Login = newLogin
+		return []uint64{userId}, nil
+	})
+}
+
+func (s loginService) ChangePassword(ctx context.Context, userId uint64, login string, oldPassword string, newPassword string) error {
+	hash, err := s.hashPassword(ctx, newPassword)
+	if err != nil {
+		return err
+	}
+
+	return s.update(func(users map[uint64]*userRecord) ([]uint64, error) {
+		user := users[userId]
+		if user == nil || user.Login != login || bcrypt.CompareHashAndPassword(user.Hash, []byte(oldPassword)) != nil {
+			return nil, common.ErrUpdate
+		}
+		user.Hash = hash
+		return []uint64{userId}, nil
+	})
+}
+
+func (s loginService) GetUsers(ctx context.Context, userIds []uint64) (map[uint64]loginservice.User, error) {
+	users := map[uint64]loginservice.User{}
+	s.read(func(records map[uint64]*userRecord) {
+		for _, userId := range userIds {
+			if user := records[userId]; user != nil {
+				users[userId] = s.convertUser(userId, user)
+			}
+		}
+	})
+	return users, nil
+}
+
+func (s loginService) ListUsers(ctx context.Context, start uint64, end uint64, filter string) (uint64, []loginservice.User, error) {
+	var users []loginservice.User
+	s.read(func(records map[uint64]*userRecord) {
+		for userId, user := range records {
+			if strings.Contains(user.Login, filter) {
+				users = append(users, s.convertUser(userId, user))
+			}
+		}
+	})
+
+	slices.SortFunc(users, func(a loginservice.User, b loginservice.User) int {
+		return cmp.Compare(a.Login, b.Login)
+	})
+	return uint64(len(users)), extractRange(users, start, end), nil
+}
+
+// no right check
+func (s loginService) Delete(ctx context.Context, userId uint64) error {
+	return s.update(func(users map[uint64]*userRecord) ([]uint64, error) {
+		delete(users, userId)
+		return []uint64{userId}, nil
+	})
+}
+
+func (s loginService) hashPassword(ctx context.Context, password string) ([]byte, error) {
+	strong, err := s.strengthService.Validate(ctx, password)
+	if err != nil {
+		return nil, err
+	}
+	if !strong {
+		return nil, common.ErrWeakPassword
+	}
+	return bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+}
+
+func (s loginService) convertUser(userId uint64, user *userRecord) loginservice.User {
+	return loginservice.User{Id: userId, Login: user.Login, RegistredAt: time.Unix(user.RegistredAt, 0)}
+}
+
+func findLogin(users map[uint64]*userRecord, login string) (uint64, []byte) {
+	for userId, user := range users {
+		if user.Login == login {
+			return userId, user.Hash
+		}
+	}
+	return 0, nil
+}
+
+type strengthService struct{}
+
+// Only check the length of the password.
+func newStrengthService() strengthservice.PasswordStrengthService {
+	return strengthService{}
+}
+
+func (strengthService) Validate(ctx context.Context, password string) (bool, error) {
+	return len(password) >= minPasswordLength, nil
+}
+
+func (strengthService) GetRules(ctx context.Context, lang string) (string, error) {
+	return "At least " + strconv.Itoa(minPasswordLength) + " characters", nil
+}
+
+// the slice is limited to [start, end)
+func extractRange[T any](list []T, start uint64, end uint64) []T {
+	size := uint64(len(list))
+	if start >= size {
+		return nil
+	}
+	return list[start:min(end, size)]
+}
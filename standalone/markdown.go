@@ -0,0 +1,45 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package standalone
+
+import (
+	"bytes"
+	"context"
+
+	markdownservice "github.com/dvaumoron/puzzleweb/markdown/service"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+type markdownService struct {
+	engine goldmark.Markdown
+}
+
+// Use goldmark with the GitHub Flavored Markdown extensions (raw HTML is not rendered).
+func newMarkdownService() markdownservice.MarkdownService {
+	return markdownService{engine: goldmark.New(goldmark.WithExtensions(extension.GFM))}
+}
+
+func (s markdownService) Apply(ctx context.Context, text string) (string, error) {
+	var buffer bytes.Buffer
+	if err := s.engine.Convert([]byte(text), &buffer); err != nil {
+		return "", err
+	}
+	return buffer.String(), nil
+}
@@ -0,0 +1,130 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package standalone
+
+import (
+	"context"
+
+	adminservice "github.com/dvaumoron/puzzleweb/admin/service"
+	"github.com/dvaumoron/puzzleweb/common"
+	loginservice "github.com/dvaumoron/puzzleweb/login/service"
+	profileservice "github.com/dvaumoron/puzzleweb/profile/service"
+)
+
+type profileRecord struct {
+	Desc    string
+	Info    map[string]string
+	Picture []byte
+}
+
+type profileState struct {
+	Profiles map[uint64]*profileRecord
+}
+
+type profileService struct {
+	*persisted[profileState]
+	groupId        uint64
+	defaultPicture []byte
+	userService    loginservice.UserService
+	authService    adminservice.AuthService
+}
+
+func newProfileService(storage Storage, groupId uint64, defaultPicture []byte, userService loginservice.UserService, authService adminservice.AuthService) (profileservice.AdvancedProfileService, error) {
+	p, err := newPersisted(storage, "profile", profileState{Profiles: map[uint64]*profileRecord{}})
+	if err != nil {
+		return nil, err
+	}
+	return profileService{
+		persisted: p, groupId: groupId, defaultPicture: defaultPicture, userService: userService, authService: authService,
+	}, nil
+}
+
+func (s profileService) GetProfiles(ctx context.Context, userIds []uint64) (map[uint64]profileservice.UserProfile, error) {
+	// duplicate removal
+	userIds = common.MakeSet(userIds).Slice()
+
+	users, err := s.userService.GetUsers(ctx, userIds)
+	if err != nil {
+		return nil, err
+	}
+
+	profiles := make(map[uint64]profileservice.UserProfile, len(users))
+	s.read(func(state *profileState) {
+		for userId, user := range users {
+			// user who doesn't have profile data yet have an empty one
+			userProfile := profileservice.UserProfile{User: user}
+			if profile := state.Profiles[userId]; profile != nil {
+				userProfile.Desc = profile.Desc
+				userProfile.Info = profile.Info
+			}
+			profiles[userId] = userProfile
+		}
+	})
+	return profiles, nil
+}
+
+func (s profileService) GetPicture(ctx context.Context, userId uint64) []byte {
+	var picture []byte
+	s.read(func(state *profileState) {
+		if profile := state.Profiles[userId]; profile != nil {
+			picture = profile.Picture
+		}
+	})
+	if len(picture) == 0 {
+		return s.defaultPicture
+	}
+	return picture
+}
+
+func (s profileService) UpdateProfile(ctx context.Context, userId uint64, desc string, info map[string]string) error {
+	return s.update(func(state *profileState) error {
+		profile := getOrCreateProfile(state, userId)
+		profile.Desc = desc
+		profile.Info = info
+		return nil
+	})
+}
+
+func (s profileService) UpdatePicture(ctx context.Context, userId uint64, data []byte) error {
+	return s.update(func(state *profileState) error {
+		getOrCreateProfile(state, userId).Picture = data
+		return nil
+	})
+}
+
+// no right check
+func (s profileService) Delete(ctx context.Context, userId uint64) error {
+	return s.update(func(state *profileState) error {
+		delete(state.Profiles, userId)
+		return nil
+	})
+}
+
+func (s profileService) ViewRight(ctx context.Context, userId uint64) error {
+	return s.authService.AuthQuery(ctx, userId, s.groupId, adminservice.ActionAccess)
+}
+
+func getOrCreateProfile(state *profileState, userId uint64) *profileRecord {
+	profile := state.Profiles[userId]
+	if profile == nil {
+		profile = &profileRecord{}
+		state.Profiles[userId] = profile
+	}
+	return profile
+}
@@ -0,0 +1,225 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package standalone
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"strings"
+
+	adminservice "github.com/dvaumoron/puzzleweb/admin/service"
+	"github.com/dvaumoron/puzzleweb/common"
+	"github.com/dvaumoron/puzzleweb/common/log"
+)
+
+type roleRef struct {
+	Name    string
+	GroupId uint64
+}
+
+type rightState struct {
+	Roles     map[string][]string // actions by role (see roleKey)
+	UserRoles map[uint64][]roleRef
+}
+
+type rightService struct {
+	*persisted[rightState]
+	logger        log.Logger // for init phase (have the context)
+	adminId       uint64     // this user has every right (allowing to give roles to others)
+	groupIdToName map[uint64]string
+	nameToGroupId map[string]uint64
+}
+
+func newRightService(storage Storage, logger log.Logger, adminId uint64) (adminservice.RightService, error) {
+	p, err := newPersisted(storage, "right", rightState{Roles: map[string][]string{}, UserRoles: map[uint64][]roleRef{}})
+	if err != nil {
+		return nil, err
+	}
+
+	groupIdToName := map[uint64]string{
+		adminservice.PublicGroupId: adminservice.PublicName, adminservice.AdminGroupId: adminservice.AdminName,
+	}
+	nameToGroupId := map[string]uint64{
+		adminservice.PublicName: adminservice.PublicGroupId, adminservice.AdminName: adminservice.AdminGroupId,
+	}
+	return rightService{
+		persisted: p, logger: logger, adminId: adminId, groupIdToName: groupIdToName, nameToGroupId: nameToGroupId,
+	}, nil
+}
+
+func (s rightService) RegisterGroup(groupId uint64, groupName string) bool {
+	if _, ok := s.groupIdToName[groupId]; ok {
+		s.logger.Error("Register an already existing groupId")
+		return false
+	}
+	s.groupIdToName[groupId] = groupName
+	s.nameToGroupId[groupName] = groupId
+	return true
+}
+
+func (s rightService) AuthQuery(ctx context.Context, userId uint64, groupId uint64, action string) error {
+	if (userId != 0 && userId == s.adminId) || (groupId == adminservice.PublicGroupId && action == adminservice.ActionAccess) {
+		return nil
+	}
+
+	allowed := false
+	s.read(func(state *rightState) {
+		for _, role := range state.UserRoles[userId] {
+			if role.GroupId == groupId && slices.Contains(state.Roles[roleKey(role.Name, groupId)], action) {
+				allowed = true
+				return
+			}
+		}
+	})
+	if !allowed {
+		return common.ErrNotAuthorized
+	}
+	return nil
+}
+
+func (s rightService) GetAllGroups(ctx context.Context, adminId uint64) ([]adminservice.Group, error) {
+	if err := s.AuthQuery(ctx, adminId, adminservice.AdminGroupId, adminservice.ActionAccess); err != nil {
+		return nil, err
+	}
+	return s.getAllGroups(), nil
+}
+
+func (s rightService) GetActions(ctx context.Context, adminId uint64, roleName string, groupName string) ([]string, error) {
+	if err := s.AuthQuery(ctx, adminId, adminservice.AdminGroupId, adminservice.ActionAccess); err != nil {
+		return nil, err
+	}
+
+	var actions []string
+	s.read(func(state *rightState) {
+		actions = slices.Clone(state.Roles[roleKey(roleName, s.nameToGroupId[groupName])])
+	})
+	return actions, nil
+}
+
+func (s rightService) UpdateUser(ctx context.Context, adminId uint64, userId uint64, roles []adminservice.Group) error {
+	if err := s.AuthQuery(ctx, adminId, adminservice.AdminGroupId, adminservice.ActionUpdate); err != nil {
+		return err
+	}
+
+	refs := make([]roleRef, 0, len(roles))
+	for _, group := range roles {
+		groupId := s.nameToGroupId[group.Name]
+		for _, role := range group.Roles {
+			refs = append(refs, roleRef{Name: role.Name, GroupId: groupId})
+		}
+	}
+	return s.update(func(state *rightState) error {
+		state.UserRoles[userId] = refs
+		return nil
+	})
+}
+
+func (s rightService) UpdateRole(ctx context.Context, adminId uint64, roleName string, groupName string, actions []string) error {
+	if err := s.AuthQuery(ctx, adminId, adminservice.AdminGroupId, adminservice.ActionUpdate); err != nil {
+		return err
+	}
+
+	// use Set to remove duplicate
+	actions = common.MakeSet(actions).Slice()
+	return s.update(func(state *rightState) error {
+		state.Roles[roleKey(roleName, s.nameToGroupId[groupName])] = actions
+		return nil
+	})
+}
+
+func (s rightService) GetUserRoles(ctx context.Context, adminId uint64, userId uint64) ([]adminservice.Group, error) {
+	if adminId != userId {
+		if err := s.AuthQuery(ctx, adminId, adminservice.AdminGroupId, adminservice.ActionAccess); err != nil {
+			return nil, err
+		}
+	}
+	return s.getUserRoles(userId), nil
+}
+
+func (s rightService) ViewUserRoles(ctx context.Context, adminId uint64, userId uint64) (bool, []adminservice.Group, error) {
+	updateRight := s.AuthQuery(ctx, adminId, adminservice.AdminGroupId, adminservice.ActionUpdate) == nil
+	userRoles, err := s.GetUserRoles(ctx, adminId, userId)
+	if err != nil {
+		return false, nil, err
+	}
+	return updateRight, userRoles, nil
+}
+
+func (s rightService) EditUserRoles(ctx context.Context, adminId uint64, userId uint64) ([]adminservice.Group, []adminservice.Group, error) {
+	allRoles, err := s.GetAllGroups(ctx, adminId)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.getUserRoles(userId), allRoles, nil
+}
+
+func (s rightService) getAllGroups() []adminservice.Group {
+	var refs []roleRef
+	var actions [][]string
+	s.read(func(state *rightState) {
+		for groupId := range s.groupIdToName {
+			for key, roleActions := range state.Roles {
+				if name, ok := splitRoleKey(key, groupId); ok {
+					refs = append(refs, roleRef{Name: name, GroupId: groupId})
+					actions = append(actions, roleActions)
+				}
+			}
+		}
+	})
+	return s.convertRoles(refs, actions)
+}
+
+func (s rightService) getUserRoles(userId uint64) []adminservice.Group {
+	var refs []roleRef
+	var actions [][]string
+	s.read(func(state *rightState) {
+		refs = state.UserRoles[userId]
+		for _, ref := range refs {
+			actions = append(actions, state.Roles[roleKey(ref.Name, ref.GroupId)])
+		}
+	})
+	return s.convertRoles(refs, actions)
+}
+
+// refs and actions have the same size
+func (s rightService) convertRoles(refs []roleRef, actions [][]string) []adminservice.Group {
+	groupIdToRoles := map[uint64][]adminservice.Role{}
+	for index, ref := range refs {
+		groupId := ref.GroupId
+		groupIdToRoles[groupId] = append(groupIdToRoles[groupId], adminservice.Role{
+			Name: ref.Name, Actions: slices.Clone(actions[index]),
+		})
+	}
+
+	res := make([]adminservice.Group, 0, len(groupIdToRoles))
+	for groupId, roles := range groupIdToRoles {
+		res = append(res, adminservice.Group{Id: groupId, Name: s.groupIdToName[groupId], Roles: roles})
+	}
+	return res
+}
+
+func roleKey(name string, groupId uint64) string {
+	return strconv.FormatUint(groupId, 10) + "/" + name
+}
+
+// extract the role name when the key is in the group
+func splitRoleKey(key string, groupId uint64) (string, bool) {
+	return strings.CutPrefix(key, strconv.FormatUint(groupId, 10)+"/")
+}
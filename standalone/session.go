@@ -0,0 +1,134 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package standalone
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"time"
+
+	"github.com/dvaumoron/puzzleweb/common"
+	sessionservice "github.com/dvaumoron/puzzleweb/session/service"
+)
+
+const purgeInterval = time.Minute
+
+type sessionRecord struct {
+	Info     map[string]string
+	LastUsed int64
+}
+
+type sessionService struct {
+	*persistedRecords[sessionRecord]
+	timeOut   time.Duration
+	lastPurge *time.Time // guarded by the mutex of the records
+}
+
+// Sessions unused for timeOut are removed (they never expire when timeOut is zero).
+func newSessionService(storage Storage, name string, timeOut time.Duration) (sessionservice.SessionService, error) {
+	p, err := newPersistedRecords[sessionRecord](storage, name)
+	if err != nil {
+		return nil, err
+	}
+	return sessionService{persistedRecords: p, timeOut: timeOut, lastPurge: new(time.Time)}, nil
+}
+
+func (s sessionService) Generate(ctx context.Context) (uint64, error) {
+	var id uint64
+	err := s.update(func(sessions map[uint64]*sessionRecord) ([]uint64, error) {
+		for id == 0 || sessions[id] != nil {
+			var err error
+			if id, err = randomId(); err != nil {
+				return nil, err
+			}
+		}
+		now := time.Now()
+		changed := s.purge(sessions, now)
+		sessions[id] = &sessionRecord{Info: map[string]string{}, LastUsed: now.Unix()}
+		return append(changed, id), nil
+	})
+	return id, err
+}
+
+// an unknown session is empty (it will be created on update)
+func (s sessionService) Get(ctx context.Context, id uint64) (map[string]string, error) {
+	// the use time is refreshed without saving (it will be on the next update)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	info := map[string]string{}
+	if session := s.records[id]; session != nil {
+		session.LastUsed = time.Now().Unix()
+		for key, value := range session.Info {
+			info[key] = value
+		}
+	}
+	return info, nil
+}
+
+// empty values are deleted
+func (s sessionService) Update(ctx context.Context, id uint64, info map[string]string) error {
+	if id == 0 {
+		return common.ErrUpdate
+	}
+	return s.update(func(sessions map[uint64]*sessionRecord) ([]uint64, error) {
+		newInfo := make(map[string]string, len(info))
+		for key, value := range info {
+			if value != "" {
+				newInfo[key] = value
+			}
+		}
+		sessions[id] = &sessionRecord{Info: newInfo, LastUsed: time.Now().Unix()}
+		return []uint64{id}, nil
+	})
+}
+
+func (s sessionService) Delete(ctx context.Context, id uint64) error {
+	return s.update(func(sessions map[uint64]*sessionRecord) ([]uint64, error) {
+		delete(sessions, id)
+		return []uint64{id}, nil
+	})
+}
+
+// return the ids of the removed sessions
+func (s sessionService) purge(sessions map[uint64]*sessionRecord, now time.Time) []uint64 {
+	if s.timeOut == 0 || now.Sub(*s.lastPurge) < purgeInterval {
+		return nil
+	}
+	*s.lastPurge = now
+
+	var removed []uint64
+	limit := now.Add(-s.timeOut).Unix()
+	for id, session := range sessions {
+		if session.LastUsed < limit {
+			delete(sessions, id)
+			removed = append(removed, id)
+		}
+	}
+	return removed
+}
+
+func randomId() (uint64, error) {
+	var buffer [8]byte
+	if _, err := rand.Read(buffer[:]); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(buffer[:]), nil
+}
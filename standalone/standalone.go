@@ -0,0 +1,141 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package standalone
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	adminservice "github.com/dvaumoron/puzzleweb/admin/service"
+	blogservice "github.com/dvaumoron/puzzleweb/blog/service"
+	"github.com/dvaumoron/puzzleweb/common/log"
+	forumservice "github.com/dvaumoron/puzzleweb/forum/service"
+	loginservice "github.com/dvaumoron/puzzleweb/login/service"
+	markdownservice "github.com/dvaumoron/puzzleweb/markdown/service"
+	strengthservice "github.com/dvaumoron/puzzleweb/passwordstrength/service"
+	profileservice "github.com/dvaumoron/puzzleweb/profile/service"
+	sessionservice "github.com/dvaumoron/puzzleweb/session/service"
+	localtemplate "github.com/dvaumoron/puzzleweb/templates/local"
+	templateservice "github.com/dvaumoron/puzzleweb/templates/service"
+	wikiservice "github.com/dvaumoron/puzzleweb/wiki/service"
+)
+
+// Embedded implementations of the services, used in place of the remote ones
+// to run a site without deploying them.
+type Services struct {
+	storage      Storage
+	loggerGetter log.LoggerGetter
+
+	mutex  sync.Mutex
+	states map[string]any // widgets with the same object id share their state
+}
+
+// An empty storagePath keeps the data in memory.
+func New(storagePath string, loggerGetter log.LoggerGetter) (*Services, error) {
+	storage := NewMemoryStorage()
+	if storagePath != "" {
+		var err error
+		if storage, err = NewBoltStorage(storagePath); err != nil {
+			return nil, err
+		}
+	}
+	return &Services{storage: storage, loggerGetter: loggerGetter, states: map[string]any{}}, nil
+}
+
+// A zero timeOut disables the expiration.
+func (s *Services) SessionService(name string, timeOut time.Duration) (sessionservice.SessionService, error) {
+	return newSessionService(s.storage, name, timeOut)
+}
+
+func (s *Services) PasswordStrengthService() strengthservice.PasswordStrengthService {
+	return newStrengthService()
+}
+
+func (s *Services) LoginService(strengthService strengthservice.PasswordStrengthService) (loginservice.FullLoginService, error) {
+	return newLoginService(s.storage, strengthService)
+}
+
+// the user with adminId has every right, no one when zero
+func (s *Services) RightService(logger log.Logger, adminId uint64) (adminservice.RightService, error) {
+	return newRightService(s.storage, logger, adminId)
+}
+
+func (s *Services) ProfileService(groupId uint64, defaultPicture []byte, userService loginservice.UserService, authService adminservice.AuthService) (profileservice.AdvancedProfileService, error) {
+	return newProfileService(s.storage, groupId, defaultPicture, userService, authService)
+}
+
+func (s *Services) MarkdownService() markdownservice.MarkdownService {
+	return newMarkdownService()
+}
+
+// Without locales nor reload (the localTemplates configuration allows them).
+func (s *Services) TemplateService(templatesPath string) (templateservice.TemplateService, error) {
+	return localtemplate.New(templatesPath, "", "", false, s.loggerGetter)
+}
+
+func (s *Services) WikiService(wikiId uint64, groupId uint64, authService adminservice.AuthService, profileService profileservice.ProfileService) (wikiservice.WikiService, error) {
+	p, err := getPersisted(s, "wiki/"+strconv.FormatUint(wikiId, 10), wikiState{Pages: map[string][]wikiVersion{}})
+	if err != nil {
+		return nil, err
+	}
+	return wikiService{
+		persisted: p, groupId: groupId, authService: authService,
+		profileService: profileService, loggerGetter: s.loggerGetter,
+	}, nil
+}
+
+// The comments of a blog are stored in the forum with the same id (like with the remote services).
+func (s *Services) ForumService(forumId uint64, groupId uint64, authService adminservice.AuthService, profileService profileservice.ProfileService) (forumservice.FullForumService, error) {
+	p, err := getPersisted(s, "forum/"+strconv.FormatUint(forumId, 10), forumState{Threads: map[uint64]*forumThread{}})
+	if err != nil {
+		return nil, err
+	}
+	return forumService{
+		persisted: p, groupId: groupId, authService: authService, profileService: profileService,
+	}, nil
+}
+
+func (s *Services) BlogService(blogId uint64, groupId uint64, authService adminservice.AuthService, profileService profileservice.ProfileService) (blogservice.BlogService, error) {
+	p, err := getPersisted(s, "blog/"+strconv.FormatUint(blogId, 10), blogState{Posts: map[uint64]blogPost{}})
+	if err != nil {
+		return nil, err
+	}
+	return blogService{
+		persisted: p, groupId: groupId, authService: authService, profileService: profileService,
+	}, nil
+}
+
+func (s *Services) Close() error {
+	return s.storage.Close()
+}
+
+func getPersisted[T any](s *Services, name string, initialState T) (*persisted[T], error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if p, ok := s.states[name].(*persisted[T]); ok {
+		return p, nil
+	}
+	p, err := newPersisted(s.storage, name, initialState)
+	if err == nil {
+		s.states[name] = p
+	}
+	return p, err
+}
@@ -0,0 +1,305 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package standalone
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	adminservice "github.com/dvaumoron/puzzleweb/admin/service"
+	"github.com/dvaumoron/puzzleweb/common"
+	"go.uber.org/zap/zaptest"
+)
+
+type testState struct {
+	Values map[string]int
+}
+
+type testRecord struct {
+	Name string
+}
+
+var errSave = errors.New("save failure")
+
+// failingStorage fails to save when fail is set.
+type failingStorage struct {
+	Storage
+	fail bool
+}
+
+func (s *failingStorage) Save(bucket string, records map[string]any) error {
+	if s.fail {
+		return errSave
+	}
+	return s.Storage.Save(bucket, records)
+}
+
+func newBoltStorage(t *testing.T, path string) Storage {
+	t.Helper()
+	storage, err := NewBoltStorage(path)
+	if err != nil {
+		t.Fatal("Failed to open storage :", err)
+	}
+	t.Cleanup(func() { storage.Close() })
+	return storage
+}
+
+func setValue(key string, value int) func(*testState) error {
+	return func(state *testState) error {
+		state.Values[key] = value
+		return nil
+	}
+}
+
+func setRecord(id uint64, name string) func(map[uint64]*testRecord) ([]uint64, error) {
+	return func(records map[uint64]*testRecord) ([]uint64, error) {
+		if name == "" {
+			delete(records, id)
+		} else {
+			records[id] = &testRecord{Name: name}
+		}
+		return []uint64{id}, nil
+	}
+}
+
+func writeTestData(t *testing.T, storage Storage) {
+	t.Helper()
+	state, err := newPersisted(storage, "state", testState{Values: map[string]int{}})
+	if err != nil {
+		t.Fatal("Failed to load state :", err)
+	}
+	records, err := newPersistedRecords[testRecord](storage, "records")
+	if err != nil {
+		t.Fatal("Failed to load records :", err)
+	}
+
+	for _, err = range []error{
+		state.update(setValue("a", 1)), records.update(setRecord(1, "one")),
+		records.update(setRecord(2, "two")), records.update(setRecord(2, "")),
+	} {
+		if err != nil {
+			t.Fatal("Failed to update :", err)
+		}
+	}
+}
+
+func assertTestData(t *testing.T, storage Storage, expectedValues map[string]int, expectedRecords map[uint64]string) {
+	t.Helper()
+	state, err := newPersisted(storage, "state", testState{Values: map[string]int{}})
+	if err != nil {
+		t.Fatal("Failed to load state :", err)
+	}
+	records, err := newPersistedRecords[testRecord](storage, "records")
+	if err != nil {
+		t.Fatal("Failed to load records :", err)
+	}
+
+	if len(state.state.Values) != len(expectedValues) {
+		t.Errorf("state is %v, expected %v", state.state.Values, expectedValues)
+	}
+	for key, value := range expectedValues {
+		if state.state.Values[key] != value {
+			t.Errorf("state is %v, expected %v", state.state.Values, expectedValues)
+		}
+	}
+	if len(records.records) != len(expectedRecords) {
+		t.Errorf("%d records, expected %d", len(records.records), len(expectedRecords))
+	}
+	for id, name := range expectedRecords {
+		if record := records.records[id]; record == nil || record.Name != name {
+			t.Errorf("record %d is %v, expected %q", id, record, name)
+		}
+	}
+}
+
+func TestMemoryStorage(t *testing.T) {
+	storage := NewMemoryStorage()
+	writeTestData(t, storage)
+	// nothing is kept
+	assertTestData(t, storage, nil, nil)
+
+	for expected := uint64(1); expected < 4; expected++ {
+		if id, _ := storage.NextId("records"); id != expected {
+			t.Errorf("next id is %d, expected %d", id, expected)
+		}
+	}
+	if id, _ := storage.NextId("other"); id != 1 {
+		t.Errorf("next id of another bucket is %d, expected 1", id)
+	}
+}
+
+func TestBoltStorage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	storage := newBoltStorage(t, path)
+	writeTestData(t, storage)
+	if id, _ := storage.NextId("records"); id != 1 {
+		t.Errorf("next id is %d, expected 1", id)
+	}
+	storage.Close()
+
+	storage = newBoltStorage(t, path)
+	assertTestData(t, storage, map[string]int{"a": 1}, map[uint64]string{1: "one"})
+	if id, _ := storage.NextId("records"); id != 2 {
+		t.Errorf("next id after reopening is %d, expected 2", id)
+	}
+}
+
+func TestRestoreOnSaveFailure(t *testing.T) {
+	storage := &failingStorage{Storage: newBoltStorage(t, filepath.Join(t.TempDir(), "test.db"))}
+	writeTestData(t, storage)
+	state, _ := newPersisted(storage, "state", testState{Values: map[string]int{}})
+	records, _ := newPersistedRecords[testRecord](storage, "records")
+
+	storage.fail = true
+	if err := state.update(setValue("a", 2)); !errors.Is(err, errSave) {
+		t.Error("unexpected error :", err)
+	}
+	if err := state.update(func(state *testState) error {
+		state.Values["b"] = 3
+		return common.ErrUpdate
+	}); err != common.ErrUpdate {
+		t.Error("unexpected error :", err)
+	}
+	for _, updater := range []func(map[uint64]*testRecord) ([]uint64, error){setRecord(1, "changed"), setRecord(3, "three")} {
+		if err := records.update(updater); !errors.Is(err, errSave) {
+			t.Error("unexpected error :", err)
+		}
+	}
+
+	if values := state.state.Values; len(values) != 1 || values["a"] != 1 {
+		t.Errorf("state not restored : %v", values)
+	}
+	if len(records.records) != 1 || records.records[1].Name != "one" {
+		t.Errorf("records not reloaded : %v", records.records)
+	}
+}
+
+func TestSessionPurge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	ctx := context.Background()
+	service, err := newSessionService(newBoltStorage(t, path), "session", time.Hour)
+	if err != nil {
+		t.Fatal("Failed to create service :", err)
+	}
+	sessions := service.(sessionService)
+
+	oldId, _ := sessions.Generate(ctx)
+	keptId, _ := sessions.Generate(ctx)
+	if err = sessions.Update(ctx, keptId, map[string]string{"key": "value", "empty": ""}); err != nil {
+		t.Fatal("Failed to update :", err)
+	}
+	sessions.records[oldId].LastUsed = time.Now().Add(-2 * time.Hour).Unix()
+	*sessions.lastPurge = time.Time{}
+	newId, _ := sessions.Generate(ctx)
+
+	if _, ok := sessions.records[oldId]; ok {
+		t.Error("expired session not purged")
+	}
+	sessions.storage.Close()
+	service, _ = newSessionService(newBoltStorage(t, path), "session", time.Hour)
+	sessions = service.(sessionService)
+	if _, ok := sessions.records[oldId]; ok {
+		t.Error("expired session still stored")
+	}
+	if _, ok := sessions.records[newId]; !ok {
+		t.Error("new session not stored")
+	}
+	if info, _ := sessions.Get(ctx, keptId); len(info) != 1 || info["key"] != "value" {
+		t.Errorf("unexpected session content : %v", info)
+	}
+
+	if err = sessions.Delete(ctx, keptId); err != nil {
+		t.Fatal("Failed to delete :", err)
+	}
+	if info, _ := sessions.Get(ctx, keptId); len(info) != 0 {
+		t.Errorf("deleted session not empty : %v", info)
+	}
+}
+
+func TestLoginService(t *testing.T) {
+	ctx := context.Background()
+	service, err := newLoginService(NewMemoryStorage(), newStrengthService())
+	if err != nil {
+		t.Fatal("Failed to create service :", err)
+	}
+
+	if _, err = service.Register(ctx, "alice", "short"); err != common.ErrWeakPassword {
+		t.Error("weak password not refused :", err)
+	}
+	aliceId, err := service.Register(ctx, "alice", "password1")
+	if err != nil {
+		t.Fatal("Failed to register :", err)
+	}
+	if _, err = service.Register(ctx, "alice", "password2"); err != common.ErrExistingLogin {
+		t.Error("existing login not refused :", err)
+	}
+
+	if userId, err := service.Verify(ctx, "alice", "password1"); err != nil || userId != aliceId {
+		t.Errorf("Verify returned %d, %v", userId, err)
+	}
+	if _, err = service.Verify(ctx, "alice", "password2"); err != common.ErrWrongLogin {
+		t.Error("wrong password accepted :", err)
+	}
+
+	if err = service.ChangePassword(ctx, aliceId, "alice", "wrong", "password2"); err != common.ErrUpdate {
+		t.Error("change with a wrong password accepted :", err)
+	}
+	if err = service.ChangePassword(ctx, aliceId, "alice", "password1", "password2"); err != nil {
+		t.Fatal("Failed to change password :", err)
+	}
+	if _, err = service.Verify(ctx, "alice", "password1"); err != common.ErrWrongLogin {
+		t.Error("old password still accepted :", err)
+	}
+	if userId, err := service.Verify(ctx, "alice", "password2"); err != nil || userId != aliceId {
+		t.Errorf("Verify returned %d, %v", userId, err)
+	}
+
+	// the ids are not reused
+	if err = service.Delete(ctx, aliceId); err != nil {
+		t.Fatal("Failed to delete :", err)
+	}
+	if userId, _ := service.Register(ctx, "alice", "password1"); userId == aliceId {
+		t.Error("id of a deleted user reused")
+	}
+}
+
+func TestRightAdmin(t *testing.T) {
+	const adminId = 2
+
+	ctx := context.Background()
+	service, err := newRightService(NewMemoryStorage(), zaptest.NewLogger(t), adminId)
+	if err != nil {
+		t.Fatal("Failed to create service :", err)
+	}
+
+	if err = service.AuthQuery(ctx, adminId, adminservice.AdminGroupId, adminservice.ActionUpdate); err != nil {
+		t.Error("admin not allowed :", err)
+	}
+	for _, userId := range []uint64{0, 1} {
+		if err = service.AuthQuery(ctx, userId, adminservice.AdminGroupId, adminservice.ActionAccess); err != common.ErrNotAuthorized {
+			t.Errorf("user %d allowed : %v", userId, err)
+		}
+	}
+	if err = service.UpdateRole(ctx, 1, "editor", adminservice.AdminName, []string{adminservice.ActionAccess}); err != common.ErrNotAuthorized {
+		t.Error("role updated by another user :", err)
+	}
+}
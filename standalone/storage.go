@@ -0,0 +1,302 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package standalone
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"strconv"
+	"sync"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// bucket of the states saved as a whole (see persisted)
+const stateBucket = "puzzleweb"
+
+// Persist the data of the embedded services as JSON records grouped in buckets.
+type Storage interface {
+	Load(bucket string, decode func(key string, data []byte) error) error // decode is called for each record of the bucket
+	Get(bucket string, key string) ([]byte, error)                        // nil when nothing is stored
+	Save(bucket string, records map[string]any) error                     // a nil record is deleted
+	NextId(bucket string) (uint64, error)                                 // never return the same id twice for a bucket
+	Close() error
+}
+
+type memoryStorage struct {
+	mutex sync.Mutex
+	ids   map[string]uint64
+}
+
+// Nothing is persisted, the data are lost when the process stop.
+func NewMemoryStorage() Storage {
+	return &memoryStorage{ids: map[string]uint64{}}
+}
+
+func (*memoryStorage) Load(string, func(string, []byte) error) error {
+	return nil
+}
+
+func (*memoryStorage) Get(string, string) ([]byte, error) {
+	return nil, nil
+}
+
+func (*memoryStorage) Save(string, map[string]any) error {
+	return nil
+}
+
+func (s *memoryStorage) NextId(bucket string) (uint64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.ids[bucket]++
+	return s.ids[bucket], nil
+}
+
+func (*memoryStorage) Close() error {
+	return nil
+}
+
+type boltStorage struct {
+	db *bolt.DB
+}
+
+// The records are stored as JSON in a BoltDB file, with a bucket by service.
+func NewBoltStorage(path string) (Storage, error) {
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, err
+	}
+	return boltStorage{db: db}, nil
+}
+
+func (s boltStorage) Load(bucket string, decode func(key string, data []byte) error) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(key []byte, data []byte) error {
+			return decode(string(key), data)
+		})
+	})
+}
+
+func (s boltStorage) Get(bucket string, key string) ([]byte, error) {
+	var data []byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte(bucket)); b != nil {
+			// the slice is only valid during the transaction
+			data = slices.Clone(b.Get([]byte(key)))
+		}
+		return nil
+	})
+	return data, err
+}
+
+func (s boltStorage) Save(bucket string, records map[string]any) error {
+	encoded := make(map[string][]byte, len(records))
+	for key, record := range records {
+		if record == nil {
+			encoded[key] = nil
+			continue
+		}
+
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		encoded[key] = data
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+		for key, data := range encoded {
+			if data == nil {
+				err = b.Delete([]byte(key))
+			} else {
+				err = b.Put([]byte(key), data)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s boltStorage) NextId(bucket string) (uint64, error) {
+	var id uint64
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+		id, err = b.NextSequence()
+		return err
+	})
+	return id, err
+}
+
+func (s boltStorage) Close() error {
+	return s.db.Close()
+}
+
+// state of a service, saved as a whole after each update
+type persisted[T any] struct {
+	mutex   sync.RWMutex
+	name    string
+	storage Storage
+	state   T
+	saved   []byte // JSON of the last saved state, restored on failure
+}
+
+func newPersisted[T any](storage Storage, name string, state T) (*persisted[T], error) {
+	p := &persisted[T]{name: name, storage: storage, state: state}
+	err := storage.Load(stateBucket, func(key string, data []byte) error {
+		if key != name {
+			return nil
+		}
+		return json.Unmarshal(data, &p.state)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if p.saved, err = json.Marshal(&p.state); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *persisted[T]) read(reader func(*T)) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	reader(&p.state)
+}
+
+// when updater or the save fails, the state is restored (keeping the memory in line with the storage)
+func (p *persisted[T]) update(updater func(*T) error) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	err := updater(&p.state)
+	var data []byte
+	if err == nil {
+		if data, err = json.Marshal(&p.state); err == nil {
+			err = p.storage.Save(stateBucket, map[string]any{p.name: json.RawMessage(data)})
+		}
+	}
+	if err != nil {
+		var state T
+		if restoreErr := json.Unmarshal(p.saved, &state); restoreErr != nil {
+			return errors.Join(err, restoreErr)
+		}
+		p.state = state
+		return err
+	}
+	p.saved = data
+	return nil
+}
+
+// records of a service, each one saved under its id in the bucket named like the service
+type persistedRecords[V any] struct {
+	mutex   sync.RWMutex
+	name    string
+	storage Storage
+	records map[uint64]*V
+}
+
+func newPersistedRecords[V any](storage Storage, name string) (*persistedRecords[V], error) {
+	p := &persistedRecords[V]{name: name, storage: storage, records: map[uint64]*V{}}
+	err := storage.Load(name, func(key string, data []byte) error {
+		id, err := strconv.ParseUint(key, 10, 64)
+		if err != nil {
+			return err
+		}
+
+		record := new(V)
+		if err = json.Unmarshal(data, record); err != nil {
+			return err
+		}
+		p.records[id] = record
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *persistedRecords[V]) read(reader func(map[uint64]*V)) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	reader(p.records)
+}
+
+// updater returns the ids of the changed records, only those are saved (or deleted when missing),
+// updater must not change anything when it fails, the changed records are reloaded when the save fails
+func (p *persistedRecords[V]) update(updater func(map[uint64]*V) ([]uint64, error)) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	ids, err := updater(p.records)
+	if err != nil || len(ids) == 0 {
+		return err
+	}
+
+	changed := make(map[string]any, len(ids))
+	for _, id := range ids {
+		key := strconv.FormatUint(id, 10)
+		if record := p.records[id]; record == nil {
+			changed[key] = nil // avoid a typed nil
+		} else {
+			changed[key] = record
+		}
+	}
+	if err = p.storage.Save(p.name, changed); err != nil {
+		return errors.Join(err, p.reload(ids))
+	}
+	return nil
+}
+
+func (p *persistedRecords[V]) reload(ids []uint64) error {
+	for _, id := range ids {
+		data, err := p.storage.Get(p.name, strconv.FormatUint(id, 10))
+		if err != nil {
+			return err
+		}
+
+		if data == nil {
+			delete(p.records, id)
+			continue
+		}
+		record := new(V)
+		if err = json.Unmarshal(data, record); err != nil {
+			return err
+		}
+		p.records[id] = record
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package standalone
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	adminservice "github.com/dvaumoron/puzzleweb/admin/service"
+	"github.com/dvaumoron/puzzleweb/common"
+	"github.com/dvaumoron/puzzleweb/common/log"
+	profileservice "github.com/dvaumoron/puzzleweb/profile/service"
+	wikiservice "github.com/dvaumoron/puzzleweb/wiki/service"
+	"go.uber.org/zap"
+)
+
+type wikiVersion struct {
+	Number    uint64
+	UserId    uint64
+	Text      string
+	CreatedAt int64
+}
+
+type wikiState struct {
+	Pages map[string][]wikiVersion // versions in ascending order by page ("lang/title")
+}
+
+type wikiService struct {
+	*persisted[wikiState]
+	groupId        uint64
+	authService    adminservice.AuthService
+	profileService profileservice.ProfileService
+	loggerGetter   log.LoggerGetter
+}
+
+func (s wikiService) LoadContent(ctx context.Context, userId uint64, lang string, title string, versionStr string) (*wikiservice.WikiContent, error) {
+	err := s.authService.AuthQuery(ctx, userId, s.groupId, adminservice.ActionAccess)
+	if err != nil {
+		return nil, err
+	}
+
+	var version uint64
+	if versionStr != "" {
+		version, err = strconv.ParseUint(versionStr, 10, 64)
+		if err != nil {
+			s.loggerGetter.Logger(ctx).Info("Failed to parse wiki version, falling to last", zap.Error(err))
+		}
+	}
+
+	var content *wikiservice.WikiContent
+	s.read(func(state *wikiState) {
+		versions := state.Pages[buildWikiRef(lang, title)]
+		if last := len(versions) - 1; last >= 0 {
+			if version == 0 {
+				version = versions[last].Number
+			}
+			if index, found := searchWikiVersion(versions, version); found {
+				content = &wikiservice.WikiContent{Version: version, Markdown: versions[index].Text}
+			}
+		}
+	})
+	// no stored wiki page give a nil content
+	return content, nil
+}
+
+func (s wikiService) StoreContent(ctx context.Context, userId uint64, lang string, title string, lastStr string, markdown string) error {
+	err := s.authService.AuthQuery(ctx, userId, s.groupId, adminservice.ActionCreate)
+	if err != nil {
+		return err
+	}
+
+	last, err := strconv.ParseUint(lastStr, 10, 64)
+	if err != nil {
+		s.loggerGetter.Logger(ctx).Warn("Failed to parse wiki last version", zap.Error(err))
+		return common.ErrTechnical
+	}
+
+	wikiRef := buildWikiRef(lang, title)
+	return s.update(func(state *wikiState) error {
+		versions := state.Pages[wikiRef]
+		var current uint64
+		if size := len(versions); size != 0 {
+			current = versions[size-1].Number
+		}
+		if last != current {
+			return common.ErrBaseVersion
+		}
+
+		state.Pages[wikiRef] = append(versions, wikiVersion{
+			Number: current + 1, UserId: userId, Text: markdown, CreatedAt: time.Now().Unix(),
+		})
+		return nil
+	})
+}
+
+func (s wikiService) GetVersions(ctx context.Context, userId uint64, lang string, title string) ([]wikiservice.Version, error) {
+	err := s.authService.AuthQuery(ctx, userId, s.groupId, adminservice.ActionAccess)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []wikiVersion
+	s.read(func(state *wikiState) {
+		versions = append(versions, state.Pages[buildWikiRef(lang, title)]...)
+	})
+	if len(versions) == 0 {
+		return nil, nil
+	}
+
+	// no duplicate check, there is one in GetProfiles
+	userIds := make([]uint64, 0, len(versions))
+	for _, version := range versions {
+		userIds = append(userIds, version.UserId)
+	}
+	profiles, err := s.profileService.GetProfiles(ctx, userIds)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]wikiservice.Version, 0, len(versions))
+	for _, version := range versions {
+		res = append(res, wikiservice.Version{
+			Number: version.Number, Creator: profiles[version.UserId],
+			Date: time.Unix(version.CreatedAt, 0),
+		})
+	}
+	return res, nil
+}
+
+func (s wikiService) DeleteContent(ctx context.Context, userId uint64, lang string, title string, versionStr string) error {
+	err := s.authService.AuthQuery(ctx, userId, s.groupId, adminservice.ActionDelete)
+	if err != nil {
+		return err
+	}
+
+	version, err := strconv.ParseUint(versionStr, 10, 64)
+	if err != nil {
+		s.loggerGetter.Logger(ctx).Warn("Failed to parse wiki version to delete", zap.Error(err))
+		return common.ErrTechnical
+	}
+
+	wikiRef := buildWikiRef(lang, title)
+	return s.update(func(state *wikiState) error {
+		versions := state.Pages[wikiRef]
+		index, found := searchWikiVersion(versions, version)
+		if !found {
+			return common.ErrUpdate
+		}
+
+		if len(versions) == 1 {
+			delete(state.Pages, wikiRef)
+		} else {
+			state.Pages[wikiRef] = append(versions[:index:index], versions[index+1:]...)
+		}
+		return nil
+	})
+}
+
+func (s wikiService) DeleteRight(ctx context.Context, userId uint64) bool {
+	return s.authService.AuthQuery(ctx, userId, s.groupId, adminservice.ActionDelete) == nil
+}
+
+func buildWikiRef(lang string, title string) string {
+	return lang + "/" + title
+}
+
+func searchWikiVersion(versions []wikiVersion, number uint64) (int, bool) {
+	for index, version := range versions {
+		if version.Number == number {
+			return index, true
+		}
+	}
+	return 0, false
+}
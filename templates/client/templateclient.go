@@ -26,6 +26,7 @@ import (
 	pb "github.com/dvaumoron/puzzletemplateservice"
 	"github.com/dvaumoron/puzzleweb/common"
 	"github.com/dvaumoron/puzzleweb/common/log"
+	"github.com/dvaumoron/puzzleweb/locale"
 	templateservice "github.com/dvaumoron/puzzleweb/templates/service"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
@@ -41,7 +42,8 @@ func New(serviceAddr string, dialOptions []grpc.DialOption, loggerGetter log.Log
 }
 
 func (client templateClient) Render(ctx context.Context, templateName string, data any) ([]byte, error) {
-	dataBytes, err := json.Marshal(data)
+	// the service can not call the methods of the date formatter
+	dataBytes, err := json.Marshal(locale.FormatDates(data))
 	if err != nil {
 		client.loggerGetter.Logger(ctx).Error("Failed to marshal data", zap.Error(err))
 		return nil, common.ErrTechnical
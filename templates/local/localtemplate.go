@@ -0,0 +1,345 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package localtemplate
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dvaumoron/puzzleweb/common"
+	"github.com/dvaumoron/puzzleweb/common/log"
+	"github.com/dvaumoron/puzzleweb/locale"
+	templateservice "github.com/dvaumoron/puzzleweb/templates/service"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	templateExt    = ".html"
+	partialPrefix  = "_"
+	messagesPrefix = "messages_"
+	messagesExt    = ".properties"
+	messagesName   = "Messages"
+	pagePartSep    = "#"
+
+	checkInterval = time.Second
+)
+
+var errMessageLine = errors.New("line without '='")
+
+// the contents rendered by the markdown service (MarkdownContent, WikiContent,
+// the blog posts) are sanitized strings, they are inserted with {{safeHTML .WikiContent}}
+var funcMap = template.FuncMap{
+	"safeHTML": func(content string) template.HTML {
+		return template.HTML(content)
+	},
+}
+
+type loadedTemplates struct {
+	pages    map[string]*template.Template
+	messages map[string]map[string]string // by lang
+}
+
+type localTemplateService struct {
+	templatesPath string
+	localesPath   string
+	defaultLang   string
+	watch         bool
+	loggerGetter  log.LoggerGetter
+
+	mutex     sync.RWMutex
+	loaded    loadedTemplates
+	signature string
+	lastCheck time.Time
+}
+
+// Parse the files with the .html extension in templatesPath, a template is named with the path
+// of its file relative to templatesPath and without extension ("profile/view", or "fr/about" for
+// a localized static page). Files with a name starting with "_" are partials added to every page,
+// so a page can use their definitions and its own without conflicting with other pages.
+// The already rendered html (like the markdown contents) is inserted with the safeHTML function.
+//
+// The messages files in localesPath (like "messages_fr.properties") are given to the templates under
+// the "Messages" key, for the lang in the data and completed with the ones of defaultLang.
+//
+// With watch, the files are checked on render (at most once per second) and reloaded when changed.
+func New(templatesPath string, localesPath string, defaultLang string, watch bool, loggerGetter log.LoggerGetter) (templateservice.TemplateService, error) {
+	s := &localTemplateService{
+		templatesPath: templatesPath, localesPath: localesPath, defaultLang: defaultLang, watch: watch, loggerGetter: loggerGetter,
+	}
+
+	var err error
+	if s.signature, err = s.computeSignature(); err != nil {
+		return nil, err
+	}
+	if s.loaded, err = s.load(); err != nil {
+		return nil, err
+	}
+	s.lastCheck = time.Now()
+	return s, nil
+}
+
+// The template name can ask for a part of the page ("blog/list#posts"),
+// a missing localized page ("fr/about") falls back to the default one ("about").
+func (s *localTemplateService) Render(ctx context.Context, templateName string, data any) ([]byte, error) {
+	logger := s.loggerGetter.Logger(ctx)
+	if s.watch {
+		s.reloadIfChanged(logger)
+	}
+
+	pageName, pagePart, _ := strings.Cut(templateName, pagePartSep)
+
+	s.mutex.RLock()
+	loaded := s.loaded
+	s.mutex.RUnlock()
+
+	lang := s.addMessages(data, loaded.messages)
+	page, ok := loaded.pages[pageName]
+	if !ok && lang != "" {
+		page, ok = loaded.pages[strings.TrimPrefix(pageName, lang+"/")]
+	}
+	if !ok {
+		logger.Error("Unknown template", zap.String("templateName", templateName))
+		return nil, common.ErrTechnical
+	}
+
+	var buffer bytes.Buffer
+	var err error
+	if pagePart == "" {
+		err = page.Execute(&buffer, data)
+	} else {
+		err = page.ExecuteTemplate(&buffer, pagePart, data)
+	}
+	if err != nil {
+		logger.Error("Failed to render template", zap.String("templateName", templateName), zap.Error(err))
+		return nil, common.ErrTechnical
+	}
+	return buffer.Bytes(), nil
+}
+
+func (s *localTemplateService) HasTemplate(templateName string) bool {
+	s.mutex.RLock()
+	_, ok := s.loaded.pages[templateName]
+	s.mutex.RUnlock()
+	return ok
+}
+
+// a failed reload keeps the previous templates
+func (s *localTemplateService) reloadIfChanged(logger log.Logger) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.lastCheck) < checkInterval {
+		return
+	}
+	s.lastCheck = now
+
+	signature, err := s.computeSignature()
+	if err != nil {
+		logger.Error("Failed to check templates", zap.Error(err))
+		return
+	}
+	if signature == s.signature {
+		return
+	}
+
+	loaded, err := s.load()
+	if err != nil {
+		logger.Error("Failed to reload templates", zap.Error(err))
+		return
+	}
+	s.loaded = loaded
+	s.signature = signature
+	logger.Info("Templates reloaded")
+}
+
+func (s *localTemplateService) load() (loadedTemplates, error) {
+	partials := template.New("").Funcs(funcMap)
+	sources := map[string]string{}
+	err := walkFiles(s.templatesPath, templateExt, func(path string, relPath string, _ fs.FileInfo) error {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		name := strings.TrimSuffix(relPath, templateExt)
+		if strings.HasPrefix(filepath.Base(name), partialPrefix) {
+			_, err = partials.New(name).Parse(string(content))
+			return err
+		}
+		sources[name] = string(content)
+		return nil
+	})
+	if err != nil {
+		return loadedTemplates{}, err
+	}
+
+	pages := make(map[string]*template.Template, len(sources))
+	for name, source := range sources {
+		page, err := partials.Clone()
+		if err != nil {
+			return loadedTemplates{}, err
+		}
+		if page, err = page.New(name).Parse(source); err != nil {
+			return loadedTemplates{}, err
+		}
+		pages[name] = page
+	}
+
+	messages, err := s.loadMessages()
+	if err != nil {
+		return loadedTemplates{}, err
+	}
+	return loadedTemplates{pages: pages, messages: messages}, nil
+}
+
+func (s *localTemplateService) loadMessages() (map[string]map[string]string, error) {
+	messages := map[string]map[string]string{}
+	if s.localesPath == "" {
+		return messages, nil
+	}
+
+	err := walkFiles(s.localesPath, messagesExt, func(path string, relPath string, _ fs.FileInfo) error {
+		fileName := filepath.Base(relPath)
+		if !strings.HasPrefix(fileName, messagesPrefix) {
+			return nil
+		}
+
+		langMessages, err := readMessages(path)
+		if err != nil {
+			return err
+		}
+		messages[strings.TrimSuffix(strings.TrimPrefix(fileName, messagesPrefix), messagesExt)] = langMessages
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// missing messages are taken from the default lang
+	if defaultMessages := messages[s.defaultLang]; len(defaultMessages) != 0 {
+		for lang, langMessages := range messages {
+			if lang == s.defaultLang {
+				continue
+			}
+			for key, value := range defaultMessages {
+				if _, ok := langMessages[key]; !ok {
+					langMessages[key] = value
+				}
+			}
+		}
+	}
+	return messages, nil
+}
+
+// the signature changes when a file is added, removed or modified
+func (s *localTemplateService) computeSignature() (string, error) {
+	var builder strings.Builder
+	addToSignature := func(path string, _ string, info fs.FileInfo) error {
+		builder.WriteString(path)
+		builder.WriteByte(' ')
+		builder.WriteString(info.ModTime().UTC().Format(time.RFC3339Nano))
+		builder.WriteByte('\n')
+		return nil
+	}
+
+	if err := walkFiles(s.templatesPath, templateExt, addToSignature); err != nil {
+		return "", err
+	}
+	if s.localesPath != "" {
+		if err := walkFiles(s.localesPath, messagesExt, addToSignature); err != nil {
+			return "", err
+		}
+	}
+	return builder.String(), nil
+}
+
+// call fileHandler on each file with the extension, relPath use slash as separator
+func walkFiles(rootPath string, ext string, fileHandler func(path string, relPath string, info fs.FileInfo) error) error {
+	return filepath.WalkDir(rootPath, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || !strings.HasSuffix(path, ext) {
+			return err
+		}
+
+		relPath, err := filepath.Rel(rootPath, path)
+		if err != nil {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		return fileHandler(path, filepath.ToSlash(relPath), info)
+	})
+}
+
+// lines like "key = value", empty ones and the ones starting with "#" are ignored
+func readMessages(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	messages := map[string]string{}
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNumber, errMessageLine)
+		}
+		messages[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return messages, scanner.Err()
+}
+
+// return the lang of the data (empty when there is none),
+// the messages are the ones of the first lang with a file in its fallback chain
+func (s *localTemplateService) addMessages(data any, messages map[string]map[string]string) string {
+	dataMap, ok := data.(gin.H)
+	if !ok {
+		return ""
+	}
+
+	lang, _ := dataMap[locale.LangName].(string)
+	fallbacks, _ := dataMap[locale.FallbacksName].([]string)
+	for _, candidate := range append(append([]string{lang}, fallbacks...), s.defaultLang) {
+		if langMessages, ok := messages[candidate]; ok {
+			dataMap[messagesName] = langMessages
+			break
+		}
+	}
+	return lang
+}
@@ -0,0 +1,147 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package localtemplate
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dvaumoron/puzzleweb/common/log"
+	"github.com/dvaumoron/puzzleweb/locale"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+)
+
+type testLoggerGetter struct {
+	logger *zap.Logger
+}
+
+func (lg testLoggerGetter) Logger(ctx context.Context) log.Logger {
+	return lg.logger
+}
+
+func writeFiles(t *testing.T, rootPath string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(rootPath, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func newTestService(t *testing.T, watch bool) (*localTemplateService, string) {
+	t.Helper()
+	rootPath := t.TempDir()
+	writeFiles(t, filepath.Join(rootPath, "templates"), map[string]string{
+		"_layout.html":   `{{define "header"}}<h1>{{.Messages.Title}}</h1>{{end}}`,
+		"about.html":     `{{template "header" .}}<p>{{.Messages.About}}</p>`,
+		"fr/about.html":  `{{template "header" .}}<p>à propos</p>`,
+		"blog/list.html": `<ul>{{block "posts" .}}{{range .Posts}}<li>{{.}}</li>{{end}}{{end}}</ul>`,
+		"wiki/view.html": `<div>{{safeHTML .WikiContent}}</div><p>{{.Raw}}</p>`,
+	})
+	writeFiles(t, filepath.Join(rootPath, "locales"), map[string]string{
+		"messages_en.properties": "# comment\nTitle = Site\nAbout = About us\n",
+		"messages_fr.properties": "Title = Le site\n",
+	})
+
+	service, err := New(
+		filepath.Join(rootPath, "templates"), filepath.Join(rootPath, "locales"), "en", watch,
+		testLoggerGetter{logger: zaptest.NewLogger(t)},
+	)
+	if err != nil {
+		t.Fatal("Failed to load templates :", err)
+	}
+	return service.(*localTemplateService), rootPath
+}
+
+func assertRender(t *testing.T, service *localTemplateService, templateName string, data gin.H, expected string) {
+	t.Helper()
+	content, err := service.Render(context.Background(), templateName, data)
+	if err != nil {
+		t.Fatalf("Failed to render %s : %v", templateName, err)
+	}
+	if string(content) != expected {
+		t.Errorf("%s rendered as %q, expected %q", templateName, content, expected)
+	}
+}
+
+func TestRenderWithPartialsAndMessages(t *testing.T) {
+	service, _ := newTestService(t, false)
+
+	assertRender(t, service, "about", gin.H{locale.LangName: "en"}, "<h1>Site</h1><p>About us</p>")
+	// the missing messages are the ones of the default lang
+	assertRender(t, service, "about", gin.H{locale.LangName: "fr"}, "<h1>Le site</h1><p>About us</p>")
+	// the messages follow the fallback chain
+	data := gin.H{locale.LangName: "fr-CA", locale.FallbacksName: []string{"fr", "en"}}
+	assertRender(t, service, "fr/about", data, "<h1>Le site</h1><p>à propos</p>")
+	// a missing localized page falls back to the default one
+	assertRender(t, service, "de/about", gin.H{locale.LangName: "de"}, "<h1>Site</h1><p>About us</p>")
+
+	if _, err := service.Render(context.Background(), "unknown", gin.H{}); err == nil {
+		t.Error("unknown template rendered")
+	}
+	if !service.HasTemplate("fr/about") || service.HasTemplate("de/about") || service.HasTemplate("_layout") {
+		t.Error("wrong templates reported as existing")
+	}
+}
+
+func TestRenderPagePartAndHtml(t *testing.T) {
+	service, _ := newTestService(t, false)
+
+	data := gin.H{"Posts": []string{"a", "b"}}
+	assertRender(t, service, "blog/list", data, "<ul><li>a</li><li>b</li></ul>")
+	assertRender(t, service, "blog/list#posts", data, "<li>a</li><li>b</li>")
+
+	data = gin.H{"WikiContent": "<em>sanitized</em>", "Raw": "<em>raw</em>"}
+	assertRender(t, service, "wiki/view", data, "<div><em>sanitized</em></div><p>&lt;em&gt;raw&lt;/em&gt;</p>")
+}
+
+func TestReloadOnChange(t *testing.T) {
+	service, rootPath := newTestService(t, true)
+	assertRender(t, service, "about", gin.H{locale.LangName: "en"}, "<h1>Site</h1><p>About us</p>")
+
+	writeFiles(t, filepath.Join(rootPath, "templates"), map[string]string{"about.html": `<p>{{.Messages.About}}</p>`})
+	writeFiles(t, filepath.Join(rootPath, "locales"), map[string]string{"messages_en.properties": "About = Changed\n"})
+	// the modification time could be the same as the one of the first version
+	later := time.Now().Add(time.Minute)
+	for _, path := range []string{"templates/about.html", "locales/messages_en.properties"} {
+		if err := os.Chtimes(filepath.Join(rootPath, path), later, later); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// within the check interval the previous templates are kept
+	assertRender(t, service, "about", gin.H{locale.LangName: "en"}, "<h1>Site</h1><p>About us</p>")
+
+	service.lastCheck = time.Time{}
+	assertRender(t, service, "about", gin.H{locale.LangName: "en"}, "<p>Changed</p>")
+
+	// a failed reload keeps the previous templates
+	writeFiles(t, filepath.Join(rootPath, "templates"), map[string]string{"broken.html": `{{end}}`})
+	service.lastCheck = time.Time{}
+	assertRender(t, service, "about", gin.H{locale.LangName: "en"}, "<p>Changed</p>")
+}
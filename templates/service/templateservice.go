@@ -23,3 +23,8 @@ import "context"
 type TemplateService interface {
 	Render(ctx context.Context, templateName string, data any) ([]byte, error)
 }
+
+// Optional interface of the services able to tell if a template exists (used to choose a localized page).
+type TemplateChecker interface {
+	HasTemplate(templateName string) bool
+}
@@ -21,10 +21,14 @@ package templates
 import (
 	"context"
 	"net/http"
+	"time"
 
 	"github.com/dvaumoron/puzzleweb/common/config"
+	"github.com/dvaumoron/puzzleweb/common/metrics"
 	templateservice "github.com/dvaumoron/puzzleweb/templates/service"
 	"github.com/gin-gonic/gin/render"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
 )
 
 const contentTypeName = "Content-Type"
@@ -32,21 +36,30 @@ const contentTypeName = "Content-Type"
 var htmlContentType = []string{"text/html; charset=utf-8"}
 
 type ContextAndData struct {
-	Ctx  context.Context
-	Data any
+	Ctx       context.Context
+	Data      any
+	Fallbacks []string // templates tried in order when the rendering fails
 }
 
 // match Render interface from gin.
 type remoteHTML struct {
 	Service      templateservice.TemplateService
+	renderTime   metric.Float64Histogram
 	ctx          context.Context
 	templateName string
+	fallbacks    []string
 	data         any
 }
 
 func (r remoteHTML) Render(w http.ResponseWriter) error {
 	r.WriteContentType(w)
-	content, err := r.Service.Render(r.ctx, r.templateName, r.data)
+	content, err := r.render(r.templateName)
+	for _, fallback := range r.fallbacks {
+		if err == nil {
+			break
+		}
+		content, err = r.render(fallback)
+	}
 	if err != nil {
 		return err
 	}
@@ -54,6 +67,15 @@ func (r remoteHTML) Render(w http.ResponseWriter) error {
 	return err
 }
 
+func (r remoteHTML) render(templateName string) ([]byte, error) {
+	start := time.Now()
+	content, err := r.Service.Render(r.ctx, templateName, r.data)
+	r.renderTime.Record(r.ctx, metrics.SinceInMs(start), metric.WithAttributes(
+		attribute.String("template", templateName), attribute.Bool("error", err != nil),
+	))
+	return content, err
+}
+
 // Writes HTML ContentType.
 func (r remoteHTML) WriteContentType(w http.ResponseWriter) {
 	header := w.Header()
@@ -64,14 +86,21 @@ func (r remoteHTML) WriteContentType(w http.ResponseWriter) {
 
 // match HTMLRender interface from gin.
 type remoteHTMLRender struct {
-	Service templateservice.TemplateService
+	Service    templateservice.TemplateService
+	renderTime metric.Float64Histogram
 }
 
 func (r remoteHTMLRender) Instance(name string, dataWithCtx any) render.Render {
 	ctxData := dataWithCtx.(ContextAndData)
-	return remoteHTML{Service: r.Service, ctx: ctxData.Ctx, templateName: name, data: ctxData.Data}
+	return remoteHTML{
+		Service: r.Service, renderTime: r.renderTime, ctx: ctxData.Ctx, templateName: name, fallbacks: ctxData.Fallbacks,
+		data: ctxData.Data,
+	}
 }
 
 func NewServiceRender(templateConfig config.TemplateConfig) render.HTMLRender {
-	return remoteHTMLRender{Service: templateConfig.Service}
+	return remoteHTMLRender{
+		Service:    templateConfig.Service,
+		renderTime: metrics.DurationHistogram("puzzleweb.template.render.duration", "Time spent rendering templates"),
+	}
 }
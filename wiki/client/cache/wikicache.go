@@ -19,15 +19,22 @@
 package wikicache
 
 import (
+	"context"
 	"sync"
 
 	"github.com/dvaumoron/puzzleweb/common/log"
+	"github.com/dvaumoron/puzzleweb/common/metrics"
 	wikiservice "github.com/dvaumoron/puzzleweb/wiki/service"
 	"go.uber.org/zap"
 )
 
 const wikiRefName = "wikiRef"
 
+var (
+	hitCounter  = metrics.Int64Counter("puzzleweb.wikicache.hit", "Number of wiki cache hits")
+	missCounter = metrics.Int64Counter("puzzleweb.wikicache.miss", "Number of wiki cache misses")
+)
+
 type WikiCache struct {
 	mutex sync.RWMutex
 	cache map[string]*wikiservice.WikiContent
@@ -37,11 +44,14 @@ func NewCache() *WikiCache {
 	return &WikiCache{cache: map[string]*wikiservice.WikiContent{}}
 }
 
-func (wiki *WikiCache) Load(logger log.Logger, wikiRef string) *wikiservice.WikiContent {
+func (wiki *WikiCache) Load(ctx context.Context, logger log.Logger, wikiRef string) *wikiservice.WikiContent {
 	wiki.mutex.RLock()
 	content, ok := wiki.cache[wikiRef]
 	wiki.mutex.RUnlock()
-	if !ok {
+	if ok {
+		hitCounter.Add(ctx, 1)
+	} else {
+		missCounter.Add(ctx, 1)
 		logger.Debug("wikiCache miss", zap.String(wikiRefName, wikiRef))
 	}
 	return content
@@ -40,16 +40,15 @@ type wikiClient struct {
 	cache          *wikicache.WikiCache
 	wikiId         uint64
 	groupId        uint64
-	dateFormat     string
 	authService    adminservice.AuthService
 	profileService profileservice.ProfileService
 	loggerGetter   log.LoggerGetter
 }
 
-func New(serviceAddr string, dialOptions []grpc.DialOption, wikiId uint64, groupId uint64, dateFormat string, authService adminservice.AuthService, profileService profileservice.ProfileService, loggerGetter log.LoggerGetter) wikiservice.WikiService {
+func New(serviceAddr string, dialOptions []grpc.DialOption, wikiId uint64, groupId uint64, authService adminservice.AuthService, profileService profileservice.ProfileService, loggerGetter log.LoggerGetter) wikiservice.WikiService {
 	return wikiClient{
 		Client: grpcclient.Make(serviceAddr, dialOptions...), cache: wikicache.NewCache(), wikiId: wikiId, groupId: groupId,
-		dateFormat: dateFormat, authService: authService, profileService: profileService, loggerGetter: loggerGetter,
+		authService: authService, profileService: profileService, loggerGetter: loggerGetter,
 	}
 }
 
@@ -91,7 +90,7 @@ func (client wikiClient) LoadContent(ctx context.Context, userId uint64, lang st
 	}
 
 	if lastVersion := maxVersion(versions.List); lastVersion != nil {
-		content := client.cache.Load(logger, wikiRef)
+		content := client.cache.Load(ctx, logger, wikiRef)
 		if content != nil && lastVersion.Number == content.Version {
 			return content, nil
 		}
@@ -189,7 +188,7 @@ func (client wikiClient) DeleteContent(ctx context.Context, userId uint64, lang
 		err = common.ErrUpdate
 	}
 
-	content := client.cache.Load(logger, wikiRef)
+	content := client.cache.Load(ctx, logger, wikiRef)
 	if content != nil && version == content.Version {
 		client.cache.Delete(logger, wikiRef)
 	}
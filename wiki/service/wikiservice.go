@@ -21,6 +21,7 @@ package wikiservice
 import (
 	"context"
 	"sync"
+	"time"
 
 	markdownservice "github.com/dvaumoron/puzzleweb/markdown/service"
 	profileservice "github.com/dvaumoron/puzzleweb/profile/service"
@@ -64,7 +65,7 @@ func (content *WikiContent) GetBody(ctx context.Context, markdownService markdow
 type Version struct {
 	Number  uint64
 	Creator profileservice.UserProfile
-	Date    string
+	Date    time.Time
 }
 
 type WikiService interface {
@@ -19,6 +19,7 @@
 package wiki
 
 import (
+	"context"
 	"strconv"
 	"strings"
 
@@ -26,37 +27,74 @@ import (
 	"github.com/dvaumoron/puzzleweb/common/config"
 	puzzleweb "github.com/dvaumoron/puzzleweb/core"
 	"github.com/dvaumoron/puzzleweb/locale"
+	"github.com/dvaumoron/puzzleweb/ratelimit"
+	wikiservice "github.com/dvaumoron/puzzleweb/wiki/service"
 	"github.com/gin-gonic/gin"
 )
 
 const (
-	versionName     = "version"
-	versionsName    = "Versions"
-	viewMode        = "/view/"
-	editMode        = "/edit/"
-	listMode        = "/list/"
-	titleName       = "title"
-	wikiTitleName   = "WikiTitle"
-	wikiVersionName = "WikiVersion"
-	wikiContentName = "WikiContent"
+	versionName      = "version"
+	versionsName     = "Versions"
+	viewMode         = "/view/"
+	editMode         = "/edit/"
+	listMode         = "/list/"
+	titleName        = "title"
+	wikiTitleName    = "WikiTitle"
+	wikiVersionName  = "WikiVersion"
+	wikiContentName  = "WikiContent"
+	wikiFallbackName = "WikiFallbackLang"
 )
 
 type wikiWidget struct {
+	wikiService    wikiservice.WikiService
+	defaultPage    string
+	langInUrl      bool
 	defaultHandler gin.HandlerFunc
 	viewHandler    gin.HandlerFunc
 	editHandler    gin.HandlerFunc
 	saveHandler    gin.HandlerFunc
 	listHandler    gin.HandlerFunc
+	confirmDelete  gin.HandlerFunc
 	deleteHandler  gin.HandlerFunc
 }
 
 func (w wikiWidget) LoadInto(router gin.IRouter) {
+	langRoute := "/:lang"
+	if w.langInUrl {
+		langRoute = ""
+	}
 	router.GET("/", w.defaultHandler)
-	router.GET("/:lang/view/:title", w.viewHandler)
-	router.GET("/:lang/edit/:title", w.editHandler)
-	router.POST("/:lang/save/:title", w.saveHandler)
-	router.GET("/:lang/list/:title", w.listHandler)
-	router.GET("/:lang/delete/:title", w.deleteHandler)
+	router.GET(langRoute+"/view/:title", puzzleweb.CachePage(config.WikiPageKind), w.viewHandler)
+	router.GET(langRoute+"/edit/:title", w.editHandler)
+	router.POST(langRoute+"/save/:title", puzzleweb.RateLimit(ratelimit.ContentClass), w.saveHandler)
+	router.GET(langRoute+"/list/:title", w.listHandler)
+	puzzleweb.AddDeleteRoutes(router, langRoute+"/delete/:title", w.confirmDelete, w.deleteHandler)
+}
+
+// there is no listing of wiki pages, so only the default page in each lang is referenced
+func (w wikiWidget) SitemapEntries(ctx context.Context, baseUrl string, allLang []string) ([]puzzleweb.SitemapEntry, error) {
+	alternates := make(map[string]string, len(allLang))
+	for _, lang := range allLang {
+		content, err := w.wikiService.LoadContent(ctx, 0, lang, w.defaultPage, "")
+		if err != nil {
+			return nil, err
+		}
+		if content != nil {
+			if w.langInUrl {
+				alternates[lang] = wikiUrlBuilder(puzzleweb.ReplaceLangPrefix(baseUrl, lang), "", viewMode, w.defaultPage).String()
+			} else {
+				alternates[lang] = wikiUrlBuilder(baseUrl, lang, viewMode, w.defaultPage).String()
+			}
+		}
+	}
+
+	entries := make([]puzzleweb.SitemapEntry, 0, len(alternates))
+	for _, lang := range allLang {
+		if pageUrl, ok := alternates[lang]; ok {
+			entries = append(entries, puzzleweb.SitemapEntry{Url: pageUrl, Alternates: alternates})
+		}
+	}
+	return entries, nil
 }
 
 func MakeWikiPage(wikiName string, wikiConfig config.WikiConfig) puzzleweb.Page {
@@ -93,20 +131,42 @@ func MakeWikiPage(wikiName string, wikiConfig config.WikiConfig) puzzleweb.Page
 	case 0:
 	}
 
+	// in lang prefix mode, the lang comes from the site prefix instead of a wiki segment
+	langInUrl := wikiConfig.LangInUrl
+	langLevel := uint8(1)
+	if langInUrl {
+		langLevel = 0
+	}
+	extractLang := func(c *gin.Context) (string, string) {
+		localesManager := puzzleweb.GetLocalesManager(c)
+		if langInUrl {
+			lang := localesManager.GetLang(c)
+			return lang, lang
+		}
+		askedLang := c.Param(locale.LangName)
+		return localesManager.CheckLang(askedLang, c), askedLang
+	}
+	langSegment := func(lang string) string {
+		if langInUrl {
+			return ""
+		}
+		return lang
+	}
+
 	p := puzzleweb.MakePage(wikiName)
 	p.Widget = wikiWidget{
+		wikiService: wikiService, defaultPage: defaultPage, langInUrl: langInUrl,
 		defaultHandler: common.CreateRedirect(func(c *gin.Context) string {
 			lang := puzzleweb.GetLocalesManager(c).GetLang(c)
-			return wikiUrlBuilder(common.GetCurrentUrl(c), lang, viewMode, defaultPage).String()
+			return wikiUrlBuilder(common.GetCurrentUrl(c), langSegment(lang), viewMode, defaultPage).String()
 		}),
 		viewHandler: puzzleweb.CreateTemplate(func(data gin.H, c *gin.Context) (string, string) {
 			logger := puzzleweb.GetLogger(c)
-			askedLang := c.Param(locale.LangName)
+			lang, askedLang := extractLang(c)
 			title := c.Param(titleName)
-			lang := puzzleweb.GetLocalesManager(c).CheckLang(askedLang, c)
 
 			if lang != askedLang {
-				targetBuilder := wikiUrlBuilder(common.GetBaseUrl(3, c), lang, viewMode, title)
+				targetBuilder := wikiUrlBuilder(common.GetBaseUrl(2+langLevel, c), langSegment(lang), viewMode, title)
 				common.WriteError(targetBuilder, logger, common.WrongLangKey)
 				return "", targetBuilder.String()
 			}
@@ -116,20 +176,33 @@ func MakeWikiPage(wikiName string, wikiConfig config.WikiConfig) puzzleweb.Page
 			ctx := c.Request.Context()
 			content, err := wikiService.LoadContent(ctx, userId, lang, title, version)
 			if err != nil {
-				return "", common.DefaultErrorRedirect(logger, err.Error())
+				return common.ErrorPage(c, err)
+			}
+
+			if content == nil && version == "" {
+				// show a translation from the fallback chain (with a notice) instead of the edition
+				for _, fallback := range puzzleweb.GetLocalesManager(c).GetFallbacks(lang) {
+					if content, err = wikiService.LoadContent(ctx, userId, fallback, title, ""); err != nil {
+						return common.ErrorPage(c, err)
+					}
+					if content != nil {
+						data[wikiFallbackName] = fallback
+						break
+					}
+				}
 			}
 
 			if content == nil {
-				base := common.GetBaseUrl(3, c)
+				base := common.GetBaseUrl(2+langLevel, c)
 				if version == "" {
-					return "", wikiUrlBuilder(base, lang, editMode, title).String()
+					return "", wikiUrlBuilder(base, langSegment(lang), editMode, title).String()
 				}
-				return "", wikiUrlBuilder(base, lang, viewMode, title).String()
+				return "", wikiUrlBuilder(base, langSegment(lang), viewMode, title).String()
 			}
 
 			body, err := content.GetBody(ctx, markdownService)
 			if err != nil {
-				return "", common.DefaultErrorRedirect(logger, err.Error())
+				return common.ErrorPage(c, err)
 			}
 
 			data[wikiTitleName] = title
@@ -142,12 +215,11 @@ func MakeWikiPage(wikiName string, wikiConfig config.WikiConfig) puzzleweb.Page
 		}),
 		editHandler: puzzleweb.CreateTemplate(func(data gin.H, c *gin.Context) (string, string) {
 			logger := puzzleweb.GetLogger(c)
-			askedLang := c.Param(locale.LangName)
+			lang, askedLang := extractLang(c)
 			title := c.Param(titleName)
-			lang := puzzleweb.GetLocalesManager(c).CheckLang(askedLang, c)
 
 			if lang != askedLang {
-				targetBuilder := wikiUrlBuilder(common.GetBaseUrl(3, c), lang, viewMode, title)
+				targetBuilder := wikiUrlBuilder(common.GetBaseUrl(2+langLevel, c), langSegment(lang), viewMode, title)
 				common.WriteError(targetBuilder, logger, common.WrongLangKey)
 				return "", targetBuilder.String()
 			}
@@ -155,7 +227,7 @@ func MakeWikiPage(wikiName string, wikiConfig config.WikiConfig) puzzleweb.Page
 			userId, _ := data[common.UserIdName].(uint64)
 			content, err := wikiService.LoadContent(c.Request.Context(), userId, lang, title, "")
 			if err != nil {
-				return "", common.DefaultErrorRedirect(logger, err.Error())
+				return common.ErrorPage(c, err)
 			}
 
 			data[wikiTitleName] = title
@@ -170,11 +242,10 @@ func MakeWikiPage(wikiName string, wikiConfig config.WikiConfig) puzzleweb.Page
 		}),
 		saveHandler: common.CreateRedirect(func(c *gin.Context) string {
 			logger := puzzleweb.GetLogger(c)
-			askedLang := c.Param(locale.LangName)
-			lang := puzzleweb.GetLocalesManager(c).CheckLang(askedLang, c)
+			lang, askedLang := extractLang(c)
 			title := c.Param(titleName)
 
-			targetBuilder := wikiUrlBuilder(common.GetBaseUrl(3, c), lang, viewMode, title)
+			targetBuilder := wikiUrlBuilder(common.GetBaseUrl(2+langLevel, c), langSegment(lang), viewMode, title)
 			if lang != askedLang {
 				common.WriteError(targetBuilder, logger, common.WrongLangKey)
 				return targetBuilder.String()
@@ -185,18 +256,19 @@ func MakeWikiPage(wikiName string, wikiConfig config.WikiConfig) puzzleweb.Page
 			content := c.PostForm("content")
 
 			err := wikiService.StoreContent(c.Request.Context(), userId, lang, title, last, content)
-			if err != nil {
+			if err == nil {
+				puzzleweb.InvalidatePages(c, common.GetBaseUrl(2+langLevel, c))
+			} else {
 				common.WriteError(targetBuilder, logger, err.Error())
 			}
 			return targetBuilder.String()
 		}),
 		listHandler: puzzleweb.CreateTemplate(func(data gin.H, c *gin.Context) (string, string) {
 			logger := puzzleweb.GetLogger(c)
-			askedLang := c.Param(locale.LangName)
-			lang := puzzleweb.GetLocalesManager(c).CheckLang(askedLang, c)
+			lang, askedLang := extractLang(c)
 			title := c.Param(titleName)
 
-			targetBuilder := wikiUrlBuilder(common.GetBaseUrl(3, c), lang, listMode, title)
+			targetBuilder := wikiUrlBuilder(common.GetBaseUrl(2+langLevel, c), langSegment(lang), listMode, title)
 			if lang != askedLang {
 				common.WriteError(targetBuilder, logger, common.WrongLangKey)
 				return "", targetBuilder.String()
@@ -206,8 +278,7 @@ func MakeWikiPage(wikiName string, wikiConfig config.WikiConfig) puzzleweb.Page
 			ctx := c.Request.Context()
 			versions, err := wikiService.GetVersions(ctx, userId, lang, title)
 			if err != nil {
-				common.WriteError(targetBuilder, logger, err.Error())
-				return "", targetBuilder.String()
+				return common.ErrorPage(c, err)
 			}
 
 			data[wikiTitleName] = title
@@ -217,13 +288,15 @@ func MakeWikiPage(wikiName string, wikiConfig config.WikiConfig) puzzleweb.Page
 			puzzleweb.InitNoELementMsg(data, len(versions), c)
 			return listTmpl, ""
 		}),
+		confirmDelete: puzzleweb.CreateConfirmTemplate("ConfirmDeleteWikiVersion", func(c *gin.Context) string {
+			return wikiUrlBuilder(common.GetBaseUrl(2+langLevel, c), c.Param(locale.LangName), listMode, c.Param(titleName)).String()
+		}),
 		deleteHandler: common.CreateRedirect(func(c *gin.Context) string {
 			logger := puzzleweb.GetLogger(c)
-			askedLang := c.Param(locale.LangName)
-			lang := puzzleweb.GetLocalesManager(c).CheckLang(askedLang, c)
+			lang, askedLang := extractLang(c)
 			title := c.Param(titleName)
 
-			targetBuilder := wikiUrlBuilder(common.GetBaseUrl(3, c), lang, listMode, title)
+			targetBuilder := wikiUrlBuilder(common.GetBaseUrl(2+langLevel, c), langSegment(lang), listMode, title)
 			if lang != askedLang {
 				common.WriteError(targetBuilder, logger, common.WrongLangKey)
 				return targetBuilder.String()
@@ -232,7 +305,9 @@ func MakeWikiPage(wikiName string, wikiConfig config.WikiConfig) puzzleweb.Page
 			userId := puzzleweb.GetSessionUserId(c)
 			version := c.Query(versionName)
 			err := wikiService.DeleteContent(c.Request.Context(), userId, lang, title, version)
-			if err != nil {
+			if err == nil {
+				puzzleweb.InvalidatePages(c, common.GetBaseUrl(2+langLevel, c))
+			} else {
 				common.WriteError(targetBuilder, logger, err.Error())
 			}
 			return targetBuilder.String()
@@ -241,7 +316,11 @@ func MakeWikiPage(wikiName string, wikiConfig config.WikiConfig) puzzleweb.Page
 	return p
 }
 
+// the lang segment is omitted when lang is empty (lang prefix mode)
 func wikiUrlBuilder(base string, lang string, mode string, title string) *strings.Builder {
+	if lang == "" {
+		base = base[:len(base)-1]
+	}
 	targetBuilder := new(strings.Builder)
 	targetBuilder.WriteString(base)
 	targetBuilder.WriteString(lang)
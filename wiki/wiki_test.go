@@ -0,0 +1,145 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package wiki_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/dvaumoron/puzzleweb/common"
+	"github.com/dvaumoron/puzzleweb/puzzlewebtest"
+	wikiservice "github.com/dvaumoron/puzzleweb/wiki/service"
+)
+
+const wikiGroupId = 12
+
+func TestDefaultPage(t *testing.T) {
+	builder := puzzlewebtest.NewSiteBuilder(t)
+	builder.AddWiki("wiki", 1, wikiGroupId)
+	site := builder.Build()
+	client := site.NewClient()
+
+	client.Get("/wiki/").AssertRedirect(t, "/wiki/en/view/Welcome")
+	// without content, the view redirect to the edition
+	client.Get("/wiki/en/view/Welcome").AssertRedirect(t, "/wiki/en/edit/Welcome")
+
+	response := client.Get("/wiki/en/edit/Welcome")
+	response.AssertTemplate(t, "wiki/edit")
+	response.AssertData(t, "WikiVersion", "0")
+}
+
+func TestSaveAndView(t *testing.T) {
+	builder := puzzlewebtest.NewSiteBuilder(t)
+	wiki := builder.AddWiki("wiki", 1, wikiGroupId)
+	site := builder.Build()
+	client := site.NewLoggedClient("alice")
+
+	form := url.Values{"version": {"0"}, "content": {"# Hello"}}
+	client.PostForm("/wiki/en/save/Welcome", form).AssertRedirect(t, "/wiki/en/view/Welcome")
+
+	response := client.Get("/wiki/en/view/Welcome")
+	response.AssertStatus(t, http.StatusOK)
+	response.AssertTemplate(t, "wiki/view")
+	response.AssertData(t, "WikiTitle", "Welcome")
+	response.AssertData(t, "WikiContent", "<h1>Hello</h1>\n")
+
+	response = client.Get("/wiki/en/list/Welcome")
+	response.AssertTemplate(t, "wiki/list")
+	if versions, _ := response.Data()["Versions"].([]wikiservice.Version); len(versions) != 1 || versions[0].Creator.Login != "alice" {
+		t.Errorf("unexpected versions : %+v", versions)
+	}
+
+	if calls := wiki.CallsTo("StoreContent"); len(calls) != 1 {
+		t.Errorf("StoreContent called %d times, expected 1", len(calls))
+	}
+}
+
+func TestConcurrentEdition(t *testing.T) {
+	builder := puzzlewebtest.NewSiteBuilder(t)
+	builder.AddWiki("wiki", 1, wikiGroupId)
+	site := builder.Build()
+	client := site.NewLoggedClient("alice")
+
+	form := url.Values{"version": {"0"}, "content": {"First"}}
+	client.PostForm("/wiki/en/save/Page", form)
+	form.Set("content", "Second")
+	client.PostForm("/wiki/en/save/Page", form).AssertRedirect(t, "/wiki/en/view/Page?error="+common.ErrorBaseVersionKey)
+}
+
+func TestWrongLang(t *testing.T) {
+	builder := puzzlewebtest.NewSiteBuilder(t)
+	builder.AddWiki("wiki", 1, wikiGroupId)
+	site := builder.Build()
+
+	site.NewClient().Get("/wiki/de/view/Page").AssertRedirect(t, "/wiki/en/view/Page?error="+common.WrongLangKey)
+}
+
+func TestReadOnly(t *testing.T) {
+	builder := puzzlewebtest.NewSiteBuilder(t)
+	wiki := builder.AddWiki("wiki", 1, wikiGroupId)
+	builder.Rights.Authorize = puzzlewebtest.ReadOnlyExcept()
+	site := builder.Build()
+
+	form := url.Values{"version": {"0"}, "content": {"Text"}}
+	site.NewLoggedClient("bob").PostForm("/wiki/en/save/Page", form).AssertRedirect(t, "/wiki/en/view/Page?error="+common.ErrorNotAuthorizedKey)
+	if content, _ := wiki.Store.LoadContent(context.Background(), 0, "en", "Page", ""); content != nil {
+		t.Errorf("unexpected content : %+v", content)
+	}
+}
+
+func TestLangInUrl(t *testing.T) {
+	builder := puzzlewebtest.NewSiteBuilder(t)
+	builder.AllLang = []string{"en", "fr"}
+	builder.LangInUrl = true
+	wiki := builder.AddWiki("wiki", 1, wikiGroupId)
+	site := builder.Build()
+	client := site.NewLoggedClient("alice")
+
+	client.Get("/fr/wiki/").AssertRedirect(t, "/fr/wiki/view/Welcome")
+	form := url.Values{"version": {"0"}, "content": {"Bonjour"}}
+	client.PostForm("/fr/wiki/save/Welcome", form).AssertRedirect(t, "/fr/wiki/view/Welcome")
+
+	response := client.Get("/fr/wiki/view/Welcome")
+	response.AssertTemplate(t, "wiki/view")
+	response.AssertData(t, "BaseUrl", "/fr/wiki/")
+	if calls := wiki.CallsTo("StoreContent"); len(calls) != 1 || calls[0].Args[1] != "fr" {
+		t.Errorf("unexpected StoreContent calls : %+v", calls)
+	}
+}
+
+func TestFallbackLang(t *testing.T) {
+	builder := puzzlewebtest.NewSiteBuilder(t)
+	builder.AllLang = []string{"en", "fr", "fr-CA"}
+	builder.AddWiki("wiki", 1, wikiGroupId)
+	site := builder.Build()
+	client := site.NewLoggedClient("alice")
+
+	form := url.Values{"version": {"0"}, "content": {"Bonjour"}}
+	client.PostForm("/wiki/fr/save/Welcome", form)
+
+	response := client.Get("/wiki/fr-CA/view/Welcome")
+	response.AssertTemplate(t, "wiki/view")
+	response.AssertData(t, "WikiFallbackLang", "fr")
+	response.AssertData(t, "WikiContent", "<p>Bonjour</p>\n")
+
+	// without translation in the chain, the view still redirect to the edition
+	client.Get("/wiki/fr-CA/view/Other").AssertRedirect(t, "/wiki/fr-CA/edit/Other")
+}